@@ -0,0 +1,57 @@
+package main
+
+import (
+  "os"
+  "path/filepath"
+)
+
+// artifactStore is a directory of files an earlier build downloaded, keyed by their unique paths,
+// such as the output directory of a previous CI job. A nil store holds nothing
+type artifactStore struct {
+  dir  string
+  lock *lockFile // the lockfile as it was before this run, if any
+}
+
+// newArtifactStore returns the store for artifact-dir, pinned by the lockfile at lockPath when it exists
+func newArtifactStore(dir, lockPath string) (*artifactStore, error) {
+  s := &artifactStore{dir: dir}
+  if lockPath == "" {
+    return s, nil
+  }
+  if _, err := os.Stat(lockPath); err != nil {
+    return s, nil
+  }
+  lock, err := readLockFile(lockPath)
+  if err != nil {
+    return nil, err
+  }
+  s.lock = lock
+  return s, nil
+}
+
+// lookup returns the stored content of a remote asset. It is only used when it verifies against the
+// checksum of the file or, without one, the digest recorded in the lockfile, so an unpinned file is always downloaded
+func (s *artifactStore) lookup(cfg *EmbedConfig, a asset) (sourceFile, bool) {
+  if s == nil {
+    return sourceFile{}, false
+  }
+  data, err := os.ReadFile(filepath.Join(s.dir, filepath.FromSlash(a.uniquePath)))
+  if err != nil {
+    return sourceFile{}, false
+  }
+  f := sourceFile{data: data, resolved: a.expandedURL}
+  if a.entry.checksum != nil {
+    return f, a.entry.checksum.verify(data) == nil
+  }
+  if s.lock == nil {
+    return sourceFile{}, false
+  }
+  locked, ok := s.lock.lookup(a.expandedURL, cfg.lockTransform(a.entry))
+  if !ok || locked.SHA256 != sha256Hex(data) {
+    return sourceFile{}, false
+  }
+  if locked.Resolved != "" {
+    f.resolved = locked.Resolved
+  }
+  return f, true
+}
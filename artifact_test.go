@@ -0,0 +1,105 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestArtifactDir(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("remote " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	readAsset := func(t *testing.T, dir, name string) string {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(dir, "assets", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("checksum", func(t *testing.T) {
+		requests = 0
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"ci-cache/config.xml": "remote /prod/config.xml",
+			"ci-cache/users.json": "stale",
+			"embed.yaml": `output: assets
+go-mod: main
+artifact-dir: ci-cache
+files:
+  - source: ` + server.URL + `/prod/config.xml
+    checksum: sha256:` + sha256Hex([]byte("remote /prod/config.xml")) + `
+  - source: ` + server.URL + `/users.json
+    checksum: sha256:` + sha256Hex([]byte("remote /users.json")) + `
+`,
+		})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		// The artifact whose checksum matches is copied; the stale one is downloaded again
+		if requests != 1 {
+			t.Errorf("requests = %d, want only users.json downloaded", requests)
+		}
+		if got := readAsset(t, tmpDir, "config.xml"); got != "remote /prod/config.xml" {
+			t.Errorf("config.xml = %q", got)
+		}
+		if got := readAsset(t, tmpDir, "users.json"); got != "remote /users.json" {
+			t.Errorf("users.json = %q, want the downloaded content", got)
+		}
+	})
+
+	t.Run("lockfile", func(t *testing.T) {
+		requests = 0
+		tmpDir := t.TempDir()
+		config := "output: assets\ngo-mod: main\nlockfile: embed.lock\nartifact-dir: previous/assets\nfiles:\n  - " + server.URL + "/a.txt\n  - " + server.URL + "/b.txt\n"
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if requests != 2 {
+			t.Fatalf("requests = %d, want both files downloaded without artifacts", requests)
+		}
+
+		// A later job gets the assets of the first one
+		if err := os.MkdirAll(filepath.Join(tmpDir, "previous"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(filepath.Join(tmpDir, "assets"), filepath.Join(tmpDir, "previous", "assets")); err != nil {
+			t.Fatal(err)
+		}
+		requests = 0
+		if err := run(tmpDir, options{frozen: true}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if requests != 0 {
+			t.Errorf("requests = %d, want the locked artifacts used without network calls", requests)
+		}
+		if got := readAsset(t, tmpDir, "b.txt"); got != "remote /b.txt" {
+			t.Errorf("b.txt = %q", got)
+		}
+	})
+
+	t.Run("unpinned", func(t *testing.T) {
+		requests = 0
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"ci-cache/a.txt": "cached",
+			"embed.yaml":     "output: assets\ngo-mod: main\nartifact-dir: ci-cache\nfiles:\n  - " + server.URL + "/a.txt\n",
+		})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if requests != 1 || readAsset(t, tmpDir, "a.txt") != "remote /a.txt" {
+			t.Errorf("requests = %d, a.txt = %q; want a file without checksum or lockfile entry downloaded", requests, readAsset(t, tmpDir, "a.txt"))
+		}
+	})
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+  "fmt"
+  "net/http"
+  "net/url"
+  "os"
+  "strings"
+)
+
+// resolveToken expands environment variables in a configured token. A file: prefix names a file holding
+// the token instead, such as a mounted secret; the path is relative to baseDir and surrounding whitespace is trimmed
+func resolveToken(baseDir, value string) (string, error) {
+  value = expandEnvVars(value)
+  path, ok := strings.CutPrefix(value, "file:")
+  if !ok {
+    return value, nil
+  }
+  data, err := os.ReadFile(resolvePath(baseDir, path))
+  if err != nil {
+    return "", fmt.Errorf("failed to read token file %s: %v", path, err)
+  }
+  return strings.TrimSpace(string(data)), nil
+}
+
+// authTokens are the tokens sent to code hosting services, each only to the hosts of its service
+type authTokens struct {
+  github    string
+  gitlab    string
+  bitbucket string
+  hosts     map[string]string // tokens for further hosts by lowercase host name
+}
+
+// authProvider is a code hosting service: the hosts it serves files from and how it expects a token
+type authProvider struct {
+  name    string
+  matches func(host string) bool
+  token   func(t authTokens) string
+  apply   func(req *http.Request, token string)
+}
+
+// bearerAuth sends token as an OAuth bearer token, which most services accept
+func bearerAuth(req *http.Request, token string) {
+  req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// authProviders are checked in order; a host belongs to the first provider matching it
+var authProviders = []authProvider{
+  {
+    name: "github",
+    matches: func(host string) bool {
+      return hostIn(host, "github.com", "githubusercontent.com") || host == apiHost()
+    },
+    token: func(t authTokens) string { return t.github },
+    apply: bearerAuth,
+  },
+  {
+    name:    "gitlab",
+    matches: func(host string) bool { return hostIn(host, "gitlab.com") },
+    token:   func(t authTokens) string { return t.gitlab },
+    apply: func(req *http.Request, token string) {
+      req.Header.Set("PRIVATE-TOKEN", token)
+    },
+  },
+  {
+    name:    "bitbucket",
+    matches: func(host string) bool { return hostIn(host, "bitbucket.org") },
+    token:   func(t authTokens) string { return t.bitbucket },
+    apply:   bearerAuth,
+  },
+}
+
+// authHeaders are the headers providers send tokens in
+var authHeaders = []string{"Authorization", "PRIVATE-TOKEN"}
+
+// authScope names who a token sent to host is meant for: the provider serving it, or the host itself
+func authScope(host string) string {
+  host = strings.ToLower(host)
+  for _, p := range authProviders {
+    if p.matches(host) {
+      return p.name
+    }
+  }
+  return "host " + host
+}
+
+// authTokensKey is the context key of the tokens a request was authorized with
+type authTokensKey struct{}
+
+// reauthorize is called for every redirect of a request. net/http copies the headers of the first request to
+// each redirect and only drops Authorization for other hosts, so a token in another header, such as GitLab's
+// PRIVATE-TOKEN, would follow the redirect anywhere. When the redirect leaves the provider of the first request
+// its token headers are removed, and the new host gets its own token, if any
+func reauthorize(req *http.Request, via []*http.Request) {
+  t, ok := req.Context().Value(authTokensKey{}).(authTokens)
+  if !ok || req.URL.Host == via[0].URL.Host {
+    return
+  }
+  if authScope(req.URL.Hostname()) != authScope(via[0].URL.Hostname()) {
+    for _, h := range authHeaders {
+      req.Header.Del(h)
+    }
+  }
+  t.authorize(req)
+}
+
+// hostIn reports whether host is one of domains or a subdomain of one
+func hostIn(host string, domains ...string) bool {
+  for _, d := range domains {
+    if host == d || strings.HasSuffix(host, "."+d) {
+      return true
+    }
+  }
+  return false
+}
+
+// apiHost returns the host of the GitHub API, which differs from github.com for GitHub Enterprise
+func apiHost() string {
+  u, err := url.Parse(githubAPIURL)
+  if err != nil {
+    return ""
+  }
+  return strings.ToLower(u.Hostname())
+}
+
+// authorize adds the token for the host of req, if any. A token from the hosts map takes precedence
+// and uses the scheme of the provider serving that host, or a bearer token for other hosts
+func (t authTokens) authorize(req *http.Request) {
+  host := strings.ToLower(req.URL.Hostname())
+  token, explicit := t.hosts[host]
+  for _, p := range authProviders {
+    if !p.matches(host) {
+      continue
+    }
+    if !explicit {
+      token = p.token(t)
+    }
+    if token != "" {
+      p.apply(req, token)
+    }
+    return
+  }
+  if token != "" {
+    bearerAuth(req, token)
+  }
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAuthorizeByProvider(t *testing.T) {
+	tokens := authTokens{
+		github:    "gh",
+		gitlab:    "gl",
+		bitbucket: "bb",
+		hosts: map[string]string{
+			"gitlab.example.com": "self-hosted",
+			"gitlab.com":         "override",
+		},
+	}
+	tests := []struct {
+		url           string
+		authorization string
+		privateToken  string
+	}{
+		{"https://raw.githubusercontent.com/o/r/HEAD/a.json", "Bearer gh", ""},
+		{"https://api.github.com/repos/o/r/contents/a.json", "Bearer gh", ""},
+		{"https://github.com/o/r/raw/main/a.json", "Bearer gh", ""},
+		{"https://gitlab.com/api/v4/projects/1/repository/files/a.json/raw", "", "override"},
+		{"https://api.bitbucket.org/2.0/repositories/o/r/src/main/a.json", "Bearer bb", ""},
+		{"https://bitbucket.org/o/r/raw/main/a.json", "Bearer bb", ""},
+		{"https://gitlab.example.com/o/r/-/raw/main/a.json", "Bearer self-hosted", ""},
+		{"https://notgithub.com/a.json", "", ""},
+		{"https://example.com/github.com/a.json", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req, err := newRequest("GET", tt.url, fetchOptions{auth: tokens})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := req.Header.Get("Authorization"); got != tt.authorization {
+				t.Errorf("Authorization = %q, want %q", got, tt.authorization)
+			}
+			if got := req.Header.Get("PRIVATE-TOKEN"); got != tt.privateToken {
+				t.Errorf("PRIVATE-TOKEN = %q, want %q", got, tt.privateToken)
+			}
+		})
+	}
+
+	// Without a hosts entry, GitLab gets the gitlab-token
+	req, _ := newRequest("GET", "https://gitlab.com/a.json", fetchOptions{auth: authTokens{gitlab: "gl"}})
+	if got := req.Header.Get("PRIVATE-TOKEN"); got != "gl" {
+		t.Errorf("PRIVATE-TOKEN = %q, want gl", got)
+	}
+}
+
+func TestRedirectKeepsTokenWithProvider(t *testing.T) {
+	var got []http.Header
+	files := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+		w.Write([]byte("ok"))
+	}))
+	defer files.Close()
+	gitlab := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Clone())
+		switch r.URL.Path {
+		case "/same":
+			http.Redirect(w, r, "http://www.gitlab.com/a.json", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, "http://files.example.net/a.json", http.StatusFound)
+		default:
+			w.Write([]byte("ok"))
+		}
+	}))
+	defer gitlab.Close()
+
+	client := newHTTPClient(10, nil, 0)
+	// Every gitlab.com host is served by the gitlab server, any other host by the files server
+	client.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			host, _, _ := net.SplitHostPort(addr)
+			target := files.Listener.Addr().String()
+			if hostIn(host, "gitlab.com") {
+				target = gitlab.Listener.Addr().String()
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, target)
+		},
+	}
+
+	tests := []struct {
+		name          string
+		url           string
+		hosts         map[string]string
+		privateToken  string
+		authorization string
+	}{
+		{"same provider", "http://gitlab.com/same", nil, "gl", ""},
+		{"other host", "http://gitlab.com/away", nil, "", ""},
+		{"other host with its own token", "http://gitlab.com/away", map[string]string{"files.example.net": "files"}, "", "Bearer files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = nil
+			if _, err := fetchURL(client, tt.url, fetchOptions{auth: authTokens{gitlab: "gl", hosts: tt.hosts}}); err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 2 {
+				t.Fatalf("got %d requests, want 2", len(got))
+			}
+			if v := got[0].Get("PRIVATE-TOKEN"); v != "gl" {
+				t.Errorf("first request PRIVATE-TOKEN = %q, want gl", v)
+			}
+			if v := got[1].Get("PRIVATE-TOKEN"); v != tt.privateToken {
+				t.Errorf("redirected PRIVATE-TOKEN = %q, want %q", v, tt.privateToken)
+			}
+			if v := got[1].Get("Authorization"); v != tt.authorization {
+				t.Errorf("redirected Authorization = %q, want %q", v, tt.authorization)
+			}
+		})
+	}
+}
+
+func TestTokenFromFile(t *testing.T) {
+	secrets := t.TempDir()
+	writeTestFiles(t, secrets, map[string]string{"gh-token": "ghp_secret\n"})
+	t.Setenv("SECRETS_DIR", secrets)
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"tokens/gitlab": "  glpat-secret  \n",
+		"embed.yaml": `github-token: file:${SECRETS_DIR}/gh-token
+gitlab-token: file:tokens/gitlab
+tokens:
+  gitlab.example.com: file:tokens/gitlab
+files:
+  - https://example.com/a.json
+`,
+	})
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if cfg.GithubToken != "ghp_secret" || cfg.GitLabToken != "glpat-secret" || cfg.Tokens["gitlab.example.com"] != "glpat-secret" {
+		t.Errorf("tokens = %q, %q, %v; want the trimmed file contents", cfg.GithubToken, cfg.GitLabToken, cfg.Tokens)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "github-token: file:missing\nfiles:\n  - https://example.com/a.json\n"})
+	if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "failed to read token file missing") {
+		t.Errorf("loadConfig() error = %v, want a missing token file to be reported", err)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+)
+
+// contentStore is a content-addressed store of downloaded files keyed by their SHA-256,
+// so identical content fetched from different URLs is stored once
+type contentStore struct {
+  dir string
+}
+
+// blobPath returns where content with the given hex digest is stored
+func (s *contentStore) blobPath(digest string) string {
+  return filepath.Join(s.dir, "sha256", digest[:2], digest)
+}
+
+// put stores data unless a blob with the same digest already exists.
+// It returns the blob path and whether a new blob was written.
+// The store may be shared by processes running in parallel: blobs are renamed into place once
+// completely written, so a blob that exists is never partial, and racing writers of the same
+// content just replace it with identical bytes.
+func (s *contentStore) put(data []byte) (string, bool, error) {
+  digest := sha256Hex(data)
+  blob := s.blobPath(digest)
+  // A blob that no longer matches its digest was cut short, e.g. when the disk filled up, or edited
+  // through an output file hard-linked to it by an earlier version, and is written again
+  if existing, err := os.ReadFile(blob); err == nil && sha256Hex(existing) == digest {
+    return blob, false, nil
+  }
+  if err := os.MkdirAll(filepath.Dir(blob), 0755); err != nil {
+    return "", false, fmt.Errorf("failed to create cache dir %s: %v", filepath.Dir(blob), err)
+  }
+  if err := writeFileAtomic(blob, data); err != nil {
+    return "", false, fmt.Errorf("failed to write cache entry: %v", err)
+  }
+  return blob, true, nil
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestContentStoreDedupes(t *testing.T) {
+	store := &contentStore{dir: t.TempDir()}
+
+	first, created, err := store.put([]byte("same content"))
+	if err != nil || !created {
+		t.Fatalf("first put = (%q, %v, %v), want a new blob", first, created, err)
+	}
+	second, created, err := store.put([]byte("same content"))
+	if err != nil || created {
+		t.Fatalf("second put = (%q, %v, %v), want the existing blob", second, created, err)
+	}
+	if first != second {
+		t.Errorf("identical content stored at %q and %q", first, second)
+	}
+	other, created, err := store.put([]byte("other content"))
+	if err != nil || !created || other == first {
+		t.Errorf("different content put = (%q, %v, %v), want a new blob", other, created, err)
+	}
+}
+
+func TestRunCacheDirIdenticalContent(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"shared": true}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+cache-dir: .cache
+files:
+  - ` + server.URL + `/mirror-a/users.json
+  - ` + server.URL + `/mirror-b/orders.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if requests != 2 {
+		t.Errorf("requests = %d, want 2", requests)
+	}
+
+	var blobs []string
+	filepath.WalkDir(filepath.Join(tmpDir, ".cache"), func(p string, d os.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			blobs = append(blobs, p)
+		}
+		return nil
+	})
+	if len(blobs) != 1 {
+		t.Fatalf("cache blobs = %v, want exactly one for identical content", blobs)
+	}
+
+	for _, name := range []string{"users.json", "orders.json"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, "assets", name))
+		if err != nil || string(data) != `{"shared": true}` {
+			t.Errorf("assets/%s = (%q, %v), want the cached content", name, data, err)
+		}
+	}
+}
+
+func TestContentStoreConcurrentWriters(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789abcdef"), 1<<17) // 2MB, written in several syscalls
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(payload)
+	}))
+	defer server.Close()
+
+	for iteration := range 10 {
+		// Two invocations sharing the cache, each copying the blob into its own package
+		store := &contentStore{dir: t.TempDir()}
+		dstDir := t.TempDir()
+		client := newHTTPClient(defaultMaxRedirects, nil, 0)
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		errs := make([]error, 2)
+		for i := range 2 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				data, err := fetchURL(client, server.URL+"/shared.bin", fetchOptions{})
+				if err != nil {
+					errs[i] = err
+					return
+				}
+				<-start
+				blob, _, err := store.put(data)
+				if err == nil {
+					// Each invocation reads the blob back, which must never be partial
+					var blobData []byte
+					if blobData, err = os.ReadFile(blob); err == nil {
+						err = os.WriteFile(filepath.Join(dstDir, fmt.Sprintf("copy%d.bin", i)), blobData, 0644)
+					}
+				}
+				errs[i] = err
+			}()
+		}
+		close(start)
+		wg.Wait()
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("iteration %d: writer %d: %v", iteration, i, err)
+			}
+			got, err := os.ReadFile(filepath.Join(dstDir, fmt.Sprintf("copy%d.bin", i)))
+			if err != nil || !bytes.Equal(got, payload) {
+				t.Fatalf("iteration %d: copy%d.bin has %d bytes (%v), want the complete %d bytes", iteration, i, len(got), err, len(payload))
+			}
+		}
+		// Only the blob itself is left: no partial temporary files
+		var files []string
+		filepath.WalkDir(store.dir, func(p string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				files = append(files, p)
+			}
+			return nil
+		})
+		if len(files) != 1 || files[0] != store.blobPath(sha256Hex(payload)) {
+			t.Fatalf("iteration %d: cache holds %v, want only the blob", iteration, files)
+		}
+	}
+}
+
+func TestContentStoreReplacesTruncatedBlob(t *testing.T) {
+	store := &contentStore{dir: t.TempDir()}
+	data := []byte("complete content")
+	blob := store.blobPath(sha256Hex(data))
+	writeTestFiles(t, filepath.Dir(blob), map[string]string{filepath.Base(blob): "compl"})
+
+	if _, created, err := store.put(data); err != nil || !created {
+		t.Fatalf("put() = %v, %v, want the truncated blob to be rewritten", created, err)
+	}
+	if got, _ := os.ReadFile(blob); !bytes.Equal(got, data) {
+		t.Errorf("blob = %q, want %q", got, data)
+	}
+}
+
+func TestContentStoreReplacesModifiedBlob(t *testing.T) {
+	store := &contentStore{dir: t.TempDir()}
+	data := []byte("complete content")
+	blob := store.blobPath(sha256Hex(data))
+	// Same size, different bytes: edited through an output file that was hard-linked to it
+	writeTestFiles(t, filepath.Dir(blob), map[string]string{filepath.Base(blob): "edited! content"})
+
+	if _, created, err := store.put(data); err != nil || !created {
+		t.Fatalf("put() = %v, %v, want the modified blob to be rewritten", created, err)
+	}
+	if got, _ := os.ReadFile(blob); !bytes.Equal(got, data) {
+		t.Errorf("blob = %q, want %q", got, data)
+	}
+}
+
+func TestRunCacheDirOutputIsCopy(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json": `{"users": []}`,
+		"embed.yaml": `output: assets
+go-mod: main
+cache-dir: .cache
+files:
+  - src/users.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	store := &contentStore{dir: filepath.Join(tmpDir, ".cache")}
+	blob := store.blobPath(sha256Hex([]byte(`{"users": []}`)))
+	output := filepath.Join(tmpDir, "assets", "users.json")
+	if err := os.WriteFile(output, []byte(`{"users": [1]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := os.ReadFile(blob); err != nil || string(got) != `{"users": []}` {
+		t.Errorf("blob = (%q, %v), want it unchanged by editing the output", got, err)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+  "bufio"
+  "crypto/sha256"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "sort"
+  "strconv"
+  "strings"
+)
+
+// changeReport tracks which destination files differ from their previous on-disk versions
+type changeReport struct {
+  added   []string
+  changed []string
+  removed []string
+}
+
+// track compares data with the current content of localFile, which must be called before it is overwritten.
+// name is the path reported to the user.
+func (r *changeReport) track(name, localFile string, data []byte) {
+  current, err := os.ReadFile(localFile)
+  if err != nil {
+    r.added = append(r.added, name)
+    return
+  }
+  if sha256.Sum256(current) != sha256.Sum256(data) {
+    r.changed = append(r.changed, name)
+  }
+}
+
+// trackRemoved records files embedded by the previous Go files that are no longer part of the config
+func (r *changeReport) trackRemoved(baseDir string, embedGoPaths []string, assets []asset) {
+  current := make(map[string]bool)
+  for _, a := range assets {
+    if a.entry.Literal {
+      continue
+    }
+    current[a.localFile] = true
+  }
+  for _, embedGoPath := range embedGoPaths {
+    for _, p := range previousEmbedPaths(embedGoPath) {
+      localFile := filepath.Join(filepath.Dir(embedGoPath), filepath.FromSlash(p))
+      if current[localFile] {
+        continue
+      }
+      name, err := filepath.Rel(baseDir, localFile)
+      if err != nil {
+        name = localFile
+      }
+      r.removed = append(r.removed, filepath.ToSlash(name))
+    }
+  }
+  sort.Strings(r.removed)
+}
+
+// print writes the report, one line per file, or a single line when nothing changed
+func (r *changeReport) print(w io.Writer) {
+  if len(r.added)+len(r.changed)+len(r.removed) == 0 {
+    fmt.Fprintln(w, "no files changed")
+    return
+  }
+  for _, name := range r.changed {
+    fmt.Fprintf(w, "changed: %s\n", name)
+  }
+  for _, name := range r.added {
+    fmt.Fprintf(w, "new:     %s\n", name)
+  }
+  for _, name := range r.removed {
+    fmt.Fprintf(w, "removed: %s\n", name)
+  }
+}
+
+// previousEmbedPaths returns the //go:embed paths of an existing generated file
+func previousEmbedPaths(embedGoPath string) []string {
+  f, err := os.Open(embedGoPath)
+  if err != nil {
+    return nil
+  }
+  defer f.Close()
+  var paths []string
+  scanner := bufio.NewScanner(f)
+  for scanner.Scan() {
+    line := strings.TrimSpace(scanner.Text())
+    if !strings.HasPrefix(line, "//go:embed ") {
+      continue
+    }
+    p := strings.TrimSpace(strings.TrimPrefix(line, "//go:embed "))
+    if unquoted, err := strconv.Unquote(p); err == nil {
+      p = unquoted
+    }
+    paths = append(paths, p)
+  }
+  return paths
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+  "crypto/sha256"
+  "crypto/sha512"
+  "encoding/hex"
+  "fmt"
+  "hash"
+  "maps"
+  "slices"
+  "strings"
+
+  "golang.org/x/crypto/blake2b"
+)
+
+// checksumAlgorithms are the digests a checksum can use, by the prefix that names them
+var checksumAlgorithms = map[string]func() hash.Hash{
+  "sha256": sha256.New,
+  "sha512": sha512.New,
+  // BLAKE2b-512, the digest b2sum prints
+  "blake2b": func() hash.Hash {
+    h, _ := blake2b.New512(nil)
+    return h
+  },
+}
+
+// checksum is an expected content digest written as "<algorithm>:<hex>" (a bare hex digest means sha256)
+type checksum struct {
+  algorithm string
+  digest    string
+}
+
+// parseChecksum validates and splits a checksum string
+func parseChecksum(s string) (checksum, error) {
+  algorithm, digest, found := strings.Cut(s, ":")
+  if !found {
+    algorithm, digest = "sha256", s
+  }
+  digest = strings.ToLower(digest)
+  newHash, ok := checksumAlgorithms[algorithm]
+  if !ok {
+    names := slices.Sorted(maps.Keys(checksumAlgorithms))
+    return checksum{}, fmt.Errorf("unsupported checksum algorithm %q: must be one of %s", algorithm, strings.Join(names, ", "))
+  }
+  size := newHash().Size()
+  if b, err := hex.DecodeString(digest); err != nil || len(b) != size {
+    return checksum{}, fmt.Errorf("invalid %s checksum %q: want %d hex digits", algorithm, s, 2*size)
+  }
+  return checksum{algorithm: algorithm, digest: digest}, nil
+}
+
+// verify returns an error when data does not match the checksum
+func (c checksum) verify(data []byte) error {
+  h := checksumAlgorithms[c.algorithm]()
+  h.Write(data)
+  if got := hex.EncodeToString(h.Sum(nil)); got != c.digest {
+    return fmt.Errorf("checksum mismatch: want %s:%s, got %s:%s", c.algorithm, c.digest, c.algorithm, got)
+  }
+  return nil
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseChecksum(t *testing.T) {
+	digest := sha256Hex([]byte("data"))
+	tests := []struct {
+		input   string
+		wantErr string
+	}{
+		{"sha256:" + digest, ""},
+		{digest, ""},
+		{"sha256:" + strings.ToUpper(digest), ""},
+		{"md5:" + digest, "unsupported checksum algorithm"},
+		{"sha256:abc", "want 64 hex digits"},
+		{"sha256:" + strings.Repeat("z", 64), "want 64 hex digits"},
+		// sha512sum data
+		{"sha512:77c7ce9a5d86bb386d443bb96390faa120633158699c8844c30b13ab0bf92760b7e4416aea397db91b4ac0e5dd56b8ef7e4b066162ab1fdc088319ce6defc876", ""},
+		// b2sum data
+		{"blake2b:8e009c642a5e5ba8916a637ad745fb9269245d75cdbd92571a852230c6defc156d066607bf9baf9b6ab07faabadbf49b59d4d3f3be39d17ed48711ad1447996b", ""},
+		{"sha512:" + digest, "want 128 hex digits"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			sum, err := parseChecksum(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("parseChecksum() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseChecksum() error: %v", err)
+			}
+			if err := sum.verify([]byte("data")); err != nil {
+				t.Errorf("verify() error: %v", err)
+			}
+			if err := sum.verify([]byte("tampered")); err == nil {
+				t.Error("verify() should fail for other content")
+			}
+		})
+	}
+}
+
+func TestChecksumMismatchNamesAlgorithm(t *testing.T) {
+	sum, err := parseChecksum("sha512:" + strings.Repeat("0", 128))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = sum.verify([]byte("data"))
+	if err == nil || !strings.Contains(err.Error(), "want sha512:000") || !strings.Contains(err.Error(), "got sha512:77c7ce9a") {
+		t.Errorf("verify() error = %v, want a sha512 mismatch", err)
+	}
+}
+
+func TestMirrorsSkipChecksumMismatch(t *testing.T) {
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests[r.URL.Path]++
+		switch r.URL.Path {
+		case "/primary/lib.js":
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		case "/tampered/lib.js":
+			w.Write([]byte("evil()"))
+		case "/good/lib.js":
+			w.Write([]byte("lib()"))
+		}
+	}))
+	defer server.Close()
+
+	config := func(mirrors ...string) string {
+		c := "output: assets\ngo-mod: main\nlockfile: embed.lock\nfiles:\n  - source: " + server.URL + "/primary/lib.js\n" +
+			"    checksum: sha256:" + sha256Hex([]byte("lib()")) + "\n    mirrors:\n"
+		for _, m := range mirrors {
+			c += "      - " + server.URL + m + "\n"
+		}
+		return c
+	}
+
+	t.Run("later mirror matches", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config("/tampered/lib.js", "/good/lib.js", "/unused/lib.js")})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "lib.js")); string(data) != "lib()" {
+			t.Errorf("lib.js = %q, want the verified content", data)
+		}
+		if requests["/unused/lib.js"] != 0 {
+			t.Error("mirrors after the first verified one should not be requested")
+		}
+		// The lockfile pins the mirror that served the verified content
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, "embed.lock")); !strings.Contains(string(data), "resolved: "+server.URL+"/good/lib.js") {
+			t.Errorf("lockfile does not pin the mirror:\n%s", data)
+		}
+	})
+
+	t.Run("all mirrors fail", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config("/tampered/lib.js")})
+		err := run(tmpDir, options{}, io.Discard)
+		if err == nil {
+			t.Fatal("run() should fail when no mirror matches the checksum")
+		}
+		for _, want := range []string{
+			"from any of 2 sources",
+			"/primary/lib.js: 503 Service Unavailable",
+			"/tampered/lib.js: checksum mismatch",
+		} {
+			if !strings.Contains(err.Error(), want) {
+				t.Errorf("run() error = %v, want it to contain %q", err, want)
+			}
+		}
+		if _, err := os.Stat(filepath.Join(tmpDir, "assets", "lib.js")); !os.IsNotExist(err) {
+			t.Error("tampered content was written")
+		}
+	})
+}
+
+func TestMixedPinnedAndMutableFiles(t *testing.T) {
+	latest := `{"version": 1}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1.0.0/lib.js":
+			w.Write([]byte("lib()"))
+		case "/latest.json":
+			w.Write([]byte(latest))
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": `output: assets
+go-mod: main
+lockfile: embed.lock
+files:
+  - source: ` + server.URL + `/v1.0.0/lib.js
+    checksum: sha256:` + sha256Hex([]byte("lib()")) + `
+  - source: ` + server.URL + `/latest.json
+    checksum: none
+`})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	lock, _ := os.ReadFile(filepath.Join(tmpDir, "embed.lock"))
+	if !strings.Contains(string(lock), "/v1.0.0/lib.js") || strings.Contains(string(lock), "/latest.json") {
+		t.Errorf("lockfile should pin only the checksummed file:\n%s", lock)
+	}
+
+	// The mutable file may change without breaking -frozen
+	latest = `{"version": 2}`
+	if err := run(tmpDir, options{frozen: true}, io.Discard); err != nil {
+		t.Fatalf("run(-frozen) error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "latest.json")); string(data) != latest {
+		t.Errorf("latest.json = %q, want %q", data, latest)
+	}
+}
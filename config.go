@@ -0,0 +1,929 @@
+package main
+
+import (
+  "crypto/tls"
+  "fmt"
+  "go/token"
+  "maps"
+  "os"
+  "path"
+  "path/filepath"
+  "regexp"
+  "slices"
+  "strconv"
+  "strings"
+  "text/template"
+  "time"
+
+  "gopkg.in/yaml.v3"
+)
+
+type EmbedConfig struct {
+  // Includes are configs merged before this one, relative to its directory: later configs override settings and append to files
+  Includes    []string    `yaml:"includes"`
+  GoOutput    string      `yaml:"go-output"`
+  Output      string      `yaml:"output"`
+  Files       []FileEntry `yaml:"files"`
+  GoMod       string      `yaml:"go-mod"`
+  GithubToken string      `yaml:"github-token"`
+  // GitLabToken and BitbucketToken are sent to gitlab.com and bitbucket.org hosts, in the scheme each expects
+  GitLabToken    string `yaml:"gitlab-token"`
+  BitbucketToken string `yaml:"bitbucket-token"`
+  // Tokens maps further host names (e.g. a self-hosted GitLab) to the token sent to them
+  Tokens map[string]string `yaml:"tokens"`
+  VarNaming   string      `yaml:"var-naming"` // "pascal" (default) or "snake"
+  // VarType is the Go type of the generated variables: "string" (default), "bytes" for []byte,
+  // or "auto" to pick []byte for binary content
+  VarType string `yaml:"var-type"`
+  // TypeName declares a named type with the underlying var-type that the generated variables have instead
+  TypeName string `yaml:"type-name"`
+  // CollisionStrategy disambiguates files with the same name: "subdir" (default) keeps parent directories,
+  // "suffix" keeps every file in output and numbers the later ones
+  CollisionStrategy string `yaml:"collision-strategy"`
+  // Aliases maps sources, as written in files or after expansion, to the variable name to use instead of the derived one
+  Aliases map[string]string `yaml:"aliases"`
+  // MaxRedirects caps the number of redirect hops per download (default 10, 0 disables redirects)
+  MaxRedirects *int `yaml:"max-redirects"`
+  // StrictEnv fails the run when an environment variable referenced in the config is unset or empty
+  StrictEnv bool `yaml:"strict-env"`
+  // TLSMinVersion is the lowest TLS version accepted from servers: "1.0", "1.1", "1.2" or "1.3" (default: the Go default)
+  TLSMinVersion string `yaml:"tls-min-version"`
+  // Concurrency is the number of files fetched (downloaded or read) at the same time (default 1)
+  Concurrency int `yaml:"concurrency"`
+  GitHub       *GitHubSource `yaml:"github"`
+  // LineEndings and EnsureTrailingNewline normalize text content of every file unless overridden per file
+  LineEndings           string `yaml:"line-endings"` // "keep" (default), "lf" or "crlf"
+  EnsureTrailingNewline bool   `yaml:"ensure-trailing-newline"`
+  // StripBOM removes a leading UTF-8 or UTF-16 byte order mark from every file unless overridden per file
+  StripBOM bool `yaml:"strip-bom"`
+  // Preflight checks every remote URL with a HEAD request before anything is downloaded
+  Preflight bool `yaml:"preflight"`
+  // HeadCheck skips downloading a file whose HEAD Content-Length equals the size of the existing output
+  HeadCheck bool `yaml:"head-check"`
+  // FollowSourcemaps also embeds the source map a .js file references with sourceMappingURL
+  FollowSourcemaps bool `yaml:"follow-sourcemaps"`
+  // CacheDir is a content-addressed store that downloaded files are deduplicated into and linked from
+  CacheDir string `yaml:"cache-dir"`
+  // ArtifactDir holds files an earlier build downloaded, by unique path; pinned files matching their digest are copied from it
+  ArtifactDir string `yaml:"artifact-dir"`
+  // FSFunc names a generated function returning all embedded strings as an fs.FS
+  FSFunc string `yaml:"fs-func"`
+  // Registry names a generated slice of {Name, Data} pairs covering every embedded file
+  Registry string `yaml:"registry"`
+  // LookupFunc names a generated function returning an embedded file by its unique path through a switch
+  LookupFunc string `yaml:"lookup-func"`
+  // Register generates an init function passing every embedded file to a registration function
+  Register *RegisterHook `yaml:"register"`
+  // SourceURLs names a generated map from every variable name to the source it was embedded from
+  SourceURLs string `yaml:"source-urls"`
+  // RedactSourceURLs hides credentials in the SourceURLs values
+  RedactSourceURLs bool `yaml:"redact-source-urls"`
+  // ModTimes generates a <Var>ModTime variable per file from Last-Modified or the local mtime
+  ModTimes bool `yaml:"mod-times"`
+  // MaxVarsPerFile splits go-output into numbered files declaring at most this many asset variables each
+  MaxVarsPerFile int `yaml:"max-vars-per-file"`
+  // ManifestGo is the name of a Go file, written next to go-output, describing the generation
+  ManifestGo string `yaml:"manifest-go"`
+  // ValidateOnInit generates an init function that panics when an asset is empty or does not parse as its format
+  ValidateOnInit bool `yaml:"validate-on-init"`
+  // Sizes generates a <Var>Size constant per file with the length of the embedded content
+  Sizes bool `yaml:"sizes"`
+  // GitHubCommits generates a <Var>Commit constant per GitHub file with the SHA of the last commit that changed it
+  GitHubCommits bool `yaml:"github-commits"`
+  // HotReload generates a Set<Var> function per variable, so that development builds can swap in live content
+  HotReload bool `yaml:"hot-reload"`
+  // Mode is "embed" (default) or "path-only", which generates a <Var>Path constant per file instead of embedding it
+  Mode string `yaml:"mode"`
+  // Banner replaces the comment above the generated assets; environment variables are expanded
+  Banner string `yaml:"banner"`
+  // AllowedHosts, when set, restricts downloads (and redirects) to these hosts; "*.example.com" matches subdomains
+  AllowedHosts []string `yaml:"allowed-hosts"`
+  // Timeout bounds each download, including reading the body; zero means no limit
+  Timeout time.Duration `yaml:"timeout"`
+  // StallTimeout fails a download when no bytes arrive for this long, however much of timeout is left; zero means no limit
+  StallTimeout time.Duration `yaml:"stall-timeout"`
+  // MaxSize is the largest download accepted, in bytes after decompression; zero means no limit
+  MaxSize int64 `yaml:"max-size"`
+  // OverallTimeout bounds the whole run; in-flight downloads are cancelled when it expires
+  OverallTimeout time.Duration `yaml:"overall-timeout"`
+  // Lockfile records the resolved URL and checksum of every remote file, relative to the config directory
+  Lockfile string `yaml:"lockfile"`
+  // StrictPackage fails generation instead of falling back to a guessed package name
+  StrictPackage bool `yaml:"strict-package"`
+  // PackageSuffix is appended to the package name; "_test" puts the embeds in the external test package
+  PackageSuffix string `yaml:"package-suffix"`
+
+  envRefs []string // environment variables referenced by the values the config expands, for strict-env
+  included []string // absolute paths of the configs merged through includes, in the order they were read
+  warn warner // reports the warnings of the current run, set by run
+}
+
+// FileEntry is a single item of files: either a plain URL/path string or a mapping with per-file options
+type FileEntry struct {
+  Source                string `yaml:"source"`
+  // Name is the file name of a data: source, which has none of its own
+  Name string `yaml:"name"`
+  // Type forces how Source is read: "url" downloads it and "local" reads a file. Without it the prefix decides
+  Type string `yaml:"type"`
+  // List reads Source, a single variable reference, as a comma- or newline-separated list of sources
+  List bool `yaml:"list"`
+  // GitHub is an alternative to Source naming a repository file as owner/repo@ref:path
+  GitHub string `yaml:"github"`
+  // API downloads the GitHub file through the REST API instead of raw.githubusercontent.com
+  API bool `yaml:"api"`
+  LineEndings           string `yaml:"line-endings"`
+  EnsureTrailingNewline *bool  `yaml:"ensure-trailing-newline"`
+  StripBOM              *bool  `yaml:"strip-bom"`
+  // Encoding is the character encoding of the source (e.g. ISO-8859-1), transcoded to UTF-8 before embedding
+  Encoding string `yaml:"encoding"`
+  // Index is an alternative to Source: a remote directory listing whose linked files matching Pattern are embedded
+  Index   string `yaml:"index"`
+  Pattern string `yaml:"pattern"`
+  // Merge is an alternative to Source: JSON files deep-merged into a single embedded document
+  Merge *MergeEntry `yaml:"merge"`
+  // Recursive embeds every file below a local directory, skipping paths matching Ignore
+  Recursive bool     `yaml:"recursive"`
+  Ignore    []string `yaml:"ignore"`
+  // Doc is emitted as the Go doc comment of the generated variable
+  Doc string `yaml:"doc"`
+  // Headers are extra request headers whose values are templates over the file's metadata
+  Headers map[string]string `yaml:"headers"`
+  // EmbedEncoding stores binary content as "hex" or "base64" text with a decoding accessor; "raw" (default) embeds it as-is
+  EmbedEncoding string `yaml:"embed-encoding"`
+  // VarType overrides the top-level var-type for this file
+  VarType string `yaml:"var-type"`
+  // TypeName overrides the top-level type-name for this file
+  TypeName string `yaml:"type-name"`
+  // Format is the content format ("json", "xml" or "yaml") checked by the validate-on-init function
+  Format string `yaml:"format"`
+  // Validate is the format ("json", "yaml", "xml" or "sql") the content must parse as when it is generated
+  Validate string `yaml:"validate"`
+  // Checksum is the expected digest of the embedded content ("sha256:<hex>"), or "none" to leave a mutable file unpinned
+  Checksum string `yaml:"checksum"`
+  // PublicKey is a minisign public key the downloaded content must have a valid detached signature of
+  PublicKey string `yaml:"public-key"`
+  // SignatureURL is where the minisign signature is downloaded from; it defaults to the file URL with .minisig appended
+  SignatureURL string `yaml:"signature-url"`
+  // Mirrors are alternative URLs tried in order when the source fails to download or match Checksum
+  Mirrors []string `yaml:"mirrors"`
+  // Schema is a JSON Schema (URL or path relative to the config) the content must validate against
+  Schema string `yaml:"schema"`
+  // Pipe is a shell command that receives the downloaded content on stdin; its stdout is embedded instead
+  Pipe string `yaml:"pipe"`
+  // RewriteURLs maps URL prefixes to replacements in src, href and url() references of HTML and CSS content
+  RewriteURLs map[string]string `yaml:"rewrite-urls"`
+  // Range requests only a segment of a remote file ("bytes=0-1023"); the server has to answer 206 Partial Content
+  Range string `yaml:"range"`
+  // Timeout overrides the top-level timeout for the downloads of this file
+  Timeout time.Duration `yaml:"timeout"`
+  // StallTimeout overrides the top-level stall-timeout for the downloads of this file
+  StallTimeout time.Duration `yaml:"stall-timeout"`
+  // MaxSize overrides the top-level max-size for the downloads of this file
+  MaxSize int64 `yaml:"max-size"`
+  // ExpectContentType is a prefix the Content-Type of every response for this file must start with
+  ExpectContentType string `yaml:"expect-content-type"`
+  // Literal embeds the content as a compressed string literal instead of writing it to the output dir
+  Literal bool `yaml:"literal"`
+
+  headerTemplates map[string]*template.Template
+  checksum        *checksum
+  publicKey       *minisignKey
+  unpinned        bool // checksum: none keeps the file out of the lockfile
+  github          *githubFile
+  indexed         []indexLink // files linked from Index, set by listIndexes
+}
+
+// UnmarshalYAML accepts both the string and the mapping form of a file entry
+func (e *FileEntry) UnmarshalYAML(value *yaml.Node) error {
+  if value.Kind == yaml.ScalarNode {
+    return value.Decode(&e.Source)
+  }
+  type plain FileEntry
+  return value.Decode((*plain)(e))
+}
+
+// readConfig decodes configPath over cfg after the configs it includes, in order. Settings a later config sets
+// replace earlier ones, and mappings such as github or tokens are merged key by key, while files are appended.
+// stack holds the configs being read, to reject include cycles. A config included more than once, such as a
+// base shared by two includes, is only read the first time, so its files are not added twice
+func readConfig(configPath string, cfg *EmbedConfig, stack []string) error {
+  if slices.Contains(stack, configPath) {
+    return fmt.Errorf("%s includes itself", configPath)
+  }
+  if slices.Contains(cfg.included, configPath) {
+    return nil
+  }
+  data, err := os.ReadFile(configPath)
+  if err != nil {
+    return fmt.Errorf("failed to read %s: %v", configPath, err)
+  }
+  var includes struct {
+    Includes []string `yaml:"includes"`
+  }
+  if err := yaml.Unmarshal(data, &includes); err != nil {
+    return fmt.Errorf("failed to parse %s: %v", configPath, err)
+  }
+  for _, include := range includes.Includes {
+    if err := readConfig(resolvePath(filepath.Dir(configPath), include), cfg, append(stack, configPath)); err != nil {
+      return err
+    }
+  }
+  files := cfg.Files
+  cfg.Files = nil
+  if err := yaml.Unmarshal(data, cfg); err != nil {
+    return fmt.Errorf("failed to parse %s: %v", configPath, err)
+  }
+  cfg.Files = append(files, cfg.Files...)
+  cfg.included = append(cfg.included, configPath)
+  return nil
+}
+
+// rangePattern matches a single byte range: first-last, first- (to the end) or -n (the last n bytes).
+// Several ranges would come back as a multipart body
+var rangePattern = regexp.MustCompile(`^bytes=(?:([0-9]+)-([0-9]*)|-[0-9]+)$`)
+
+// Source types a file entry can force with type
+const (
+  sourceTypeURL   = "url"
+  sourceTypeLocal = "local"
+)
+
+// isRemote reports whether source, the expanded source of f, is downloaded. Without a type it is when it is an http(s) URL
+func (f *FileEntry) isRemote(source string) bool {
+  if f.Type != "" {
+    return f.Type == sourceTypeURL
+  }
+  return isRemoteURL(source)
+}
+
+// isDataURI reports whether source, the source of f, is an inline data: URI rather than the path of a local file
+func (f *FileEntry) isDataURI(source string) bool {
+  return f.Type == "" && isDataURI(source)
+}
+
+// validateRange checks the range of an entry and that its file is downloaded with a plain GET
+func validateRange(f FileEntry) error {
+  m := rangePattern.FindStringSubmatch(f.Range)
+  if m == nil {
+    return fmt.Errorf("invalid range %q: must be bytes=first-last, bytes=first- or bytes=-n", f.Range)
+  }
+  if m[1] != "" && m[2] != "" {
+    first, err1 := strconv.ParseInt(m[1], 10, 64)
+    last, err2 := strconv.ParseInt(m[2], 10, 64)
+    if err1 != nil || err2 != nil {
+      return fmt.Errorf("invalid range %q", f.Range)
+    }
+    if last < first {
+      return fmt.Errorf("invalid range %q: the last byte is before the first", f.Range)
+    }
+  }
+  switch {
+  case f.API:
+    return fmt.Errorf("range is not supported with api")
+  case f.Source != "" && !f.isRemote(expandEnvVars(f.Source)):
+    return fmt.Errorf("range is only supported for remote files")
+  }
+  return nil
+}
+
+// validateSignature checks that a signature is only configured for files that are downloaded whole
+func validateSignature(f FileEntry) error {
+  switch {
+  case f.PublicKey == "" && f.SignatureURL != "":
+    return fmt.Errorf("signature-url requires public-key")
+  case f.PublicKey == "":
+    return nil
+  case f.Merge != nil:
+    return fmt.Errorf("public-key is not supported for merge entries")
+  case f.Index != "" && f.SignatureURL != "":
+    return fmt.Errorf("signature-url is not supported for index entries: each file is verified against its URL with %s appended", minisignSuffix)
+  case f.Range != "":
+    return fmt.Errorf("public-key cannot be combined with range: the signature covers the whole file")
+  case f.Source != "" && !f.isRemote(expandEnvVars(f.Source)):
+    return fmt.Errorf("public-key is only supported for remote files")
+  }
+  return nil
+}
+
+// validateIndexEntry checks the options of an index entry. Options naming a single file cannot apply to all linked files
+func validateIndexEntry(f FileEntry) error {
+  if !isRemoteURL(expandEnvVars(f.Index)) {
+    return fmt.Errorf("index %q must be an http(s) URL", f.Index)
+  }
+  if _, err := path.Match(f.Pattern, ""); err != nil {
+    return fmt.Errorf("invalid pattern %q: %v", f.Pattern, err)
+  }
+  switch {
+  case f.Recursive:
+    return fmt.Errorf("recursive is only supported for local directories")
+  case f.API:
+    return fmt.Errorf("api requires a github file")
+  case len(f.Mirrors) > 0:
+    return fmt.Errorf("mirrors are not supported for index entries")
+  case f.Checksum != "" && f.Checksum != "none":
+    return fmt.Errorf("checksum is not supported for index entries: pin the files with a lockfile instead")
+  }
+  return nil
+}
+
+// GitHubSource holds defaults for files listed as paths relative to a GitHub repository
+type GitHubSource struct {
+  Owner string `yaml:"owner"`
+  Repo  string `yaml:"repo"`
+  Ref   string `yaml:"ref"` // branch, tag or commit; defaults to HEAD
+}
+
+// RegisterHook names the function the generated init calls as Func("unique/path", Var)
+type RegisterHook struct {
+  Import string `yaml:"import"` // package providing Func; empty for a function in the generated package
+  Func   string `yaml:"func"`   // pkg.Register, or Register without an import
+}
+
+// validate checks that Func is a function name qualified by a package exactly when Import is set
+func (h *RegisterHook) validate() error {
+  if h.Func == "" {
+    return fmt.Errorf("register: func is required")
+  }
+  qualifier, name, qualified := strings.Cut(h.Func, ".")
+  if !qualified {
+    qualifier, name = "", h.Func
+  }
+  if !token.IsIdentifier(name) || (qualified && !token.IsIdentifier(qualifier)) {
+    return fmt.Errorf("invalid register func %q: must be a Go identifier, qualified by the package name when import is set", h.Func)
+  }
+  if h.Import == "" && qualified {
+    return fmt.Errorf("register: func %s needs the import path of package %s", h.Func, qualifier)
+  }
+  if h.Import != "" && !qualified {
+    return fmt.Errorf("register: func %s must be qualified by the name of package %s, e.g. %s.%s", h.Func, h.Import, path.Base(h.Import), h.Func)
+  }
+  if h.Import != "" && (strings.HasPrefix(h.Import, "/") || strings.HasSuffix(h.Import, "/") || strings.ContainsAny(h.Import, "\\\" `\t\n")) {
+    return fmt.Errorf("invalid register import %q: must be a Go import path", h.Import)
+  }
+  return nil
+}
+
+// defaultMaxRedirects matches the net/http default redirect policy
+const defaultMaxRedirects = 10
+
+// loadConfig reads and validates the config at configPath, applying defaults and env expansion
+func loadConfig(configPath string) (*EmbedConfig, error) {
+  if _, err := os.Stat(configPath); os.IsNotExist(err) {
+    return nil, fmt.Errorf("%s not found", configPath)
+  }
+  var cfg EmbedConfig
+  err := readConfig(configPath, &cfg, nil)
+  if err != nil {
+    return nil, err
+  }
+  cfg.included = slices.DeleteFunc(cfg.included, func(p string) bool { return p == configPath })
+  if cfg.PackageSuffix != "" && cfg.PackageSuffix != "_test" {
+    return nil, fmt.Errorf("invalid package-suffix %q: must be _test", cfg.PackageSuffix)
+  }
+  // Test packages can only be declared by _test.go files
+  defaultGoOutput := "embed.go"
+  if cfg.testPackage() {
+    defaultGoOutput = "embed_test.go"
+  }
+  if cfg.GoOutput == "" {
+    cfg.GoOutput = defaultGoOutput
+  }
+  switch {
+  case cfg.assetsOnly():
+    if cfg.ManifestGo != "" {
+      return nil, fmt.Errorf("manifest-go is written next to go-output and cannot be used with go-output: none")
+    }
+  // A directory target (trailing slash or an existing directory) gets embed.go inside it
+  case strings.HasSuffix(cfg.GoOutput, "/") || strings.HasSuffix(cfg.GoOutput, `\`):
+    cfg.GoOutput = filepath.Join(cfg.GoOutput, defaultGoOutput)
+  default:
+    if info, err := os.Stat(resolvePath(filepath.Dir(configPath), cfg.GoOutput)); err == nil && info.IsDir() {
+      cfg.GoOutput = filepath.Join(cfg.GoOutput, defaultGoOutput)
+    }
+  }
+  if cfg.testPackage() && !cfg.assetsOnly() {
+    if !strings.HasSuffix(cfg.GoOutput, "_test.go") {
+      return nil, fmt.Errorf("go-output %s must end in _test.go to declare a test package", cfg.GoOutput)
+    }
+    if cfg.ManifestGo != "" && !strings.HasSuffix(cfg.ManifestGo, "_test.go") {
+      return nil, fmt.Errorf("manifest-go %s must end in _test.go to declare a test package", cfg.ManifestGo)
+    }
+  }
+  baseDir := filepath.Dir(configPath)
+  if err := cfg.checkRequiredEnv(); err != nil {
+    return nil, err
+  }
+  cfg.envRefs = cfg.envReferences()
+  for _, token := range []*string{&cfg.GithubToken, &cfg.GitLabToken, &cfg.BitbucketToken} {
+    if *token, err = resolveToken(baseDir, *token); err != nil {
+      return nil, err
+    }
+  }
+  for host, token := range cfg.Tokens {
+    if host == "" || strings.ContainsAny(host, "/ ") {
+      return nil, fmt.Errorf("invalid tokens host %q: must be a host name such as gitlab.example.com", host)
+    }
+    if cfg.Tokens[host], err = resolveToken(baseDir, token); err != nil {
+      return nil, err
+    }
+  }
+  cfg.CacheDir = expandEnvVars(cfg.CacheDir)
+  cfg.ArtifactDir = expandEnvVars(cfg.ArtifactDir)
+  cfg.Banner = expandEnvVars(cfg.Banner)
+  if len(cfg.Files) == 0 {
+    return nil, fmt.Errorf("No files specified in %s", filepath.Base(configPath))
+  }
+  for i, f := range cfg.Files {
+    switch {
+    case f.Source != "" && f.GitHub != "":
+      return nil, fmt.Errorf("files[%d]: source and github are mutually exclusive", i)
+    case f.Index != "" && (f.Source != "" || f.GitHub != ""):
+      return nil, fmt.Errorf("files[%d]: index cannot be combined with source or github", i)
+    case f.Merge != nil && (f.Source != "" || f.GitHub != "" || f.Index != ""):
+      return nil, fmt.Errorf("files[%d]: merge cannot be combined with source, github or index", i)
+    case f.Merge != nil:
+      if err := validateMergeEntry(f); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    case f.Index != "":
+      if err := validateIndexEntry(f); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    case f.Pattern != "":
+      return nil, fmt.Errorf("files[%d]: pattern is only supported for index entries", i)
+    case f.GitHub != "":
+      if cfg.Files[i].github, err = parseGitHubFile(expandEnvVars(f.GitHub)); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    case f.Source == "":
+      return nil, fmt.Errorf("files[%d]: source is required", i)
+    }
+    switch f.Type {
+    case "", sourceTypeURL, sourceTypeLocal:
+    default:
+      return nil, fmt.Errorf("files[%d]: invalid type %q: must be url or local", i, f.Type)
+    }
+    if f.Type != "" && f.Source == "" {
+      return nil, fmt.Errorf("files[%d]: type is only supported with source", i)
+    }
+    if f.List && !envListPattern.MatchString(strings.TrimSpace(f.Source)) {
+      return nil, fmt.Errorf("files[%d]: list requires source to be a single $VAR or ${VAR} reference", i)
+    }
+    if err := validateLineEndings(f.LineEndings); err != nil {
+      return nil, fmt.Errorf("files[%d]: %v", i, err)
+    }
+    switch {
+    case f.isDataURI(f.Source) && len(pathSegments(f.Name)) == 0:
+      return nil, fmt.Errorf("files[%d]: name is required for data: sources", i)
+    case !f.isDataURI(f.Source) && f.Name != "":
+      return nil, fmt.Errorf("files[%d]: name is only supported for data: sources", i)
+    }
+    if f.Encoding != "" {
+      if _, err := lookupEncoding(f.Encoding); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    }
+    if f.Checksum == "none" {
+      cfg.Files[i].unpinned = true
+    } else if f.Checksum != "" {
+      sum, err := parseChecksum(f.Checksum)
+      if err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+      cfg.Files[i].checksum = &sum
+    }
+    if err := validateSignature(f); err != nil {
+      return nil, fmt.Errorf("files[%d]: %v", i, err)
+    }
+    if f.PublicKey != "" {
+      if cfg.Files[i].publicKey, err = parseMinisignKey(expandEnvVars(f.PublicKey)); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    }
+    for prefix := range f.RewriteURLs {
+      if prefix == "" {
+        return nil, fmt.Errorf("files[%d]: rewrite-urls prefixes must not be empty", i)
+      }
+    }
+    if f.Range != "" {
+      if err := validateRange(f); err != nil {
+        return nil, fmt.Errorf("files[%d]: %v", i, err)
+      }
+    }
+    if f.Timeout < 0 {
+      return nil, fmt.Errorf("files[%d]: invalid timeout %s: must not be negative", i, f.Timeout)
+    }
+    if f.StallTimeout < 0 {
+      return nil, fmt.Errorf("files[%d]: invalid stall-timeout %s: must not be negative", i, f.StallTimeout)
+    }
+    if f.MaxSize < 0 {
+      return nil, fmt.Errorf("files[%d]: invalid max-size %d: must not be negative", i, f.MaxSize)
+    }
+    if f.API && f.ExpectContentType != "" {
+      return nil, fmt.Errorf("files[%d]: expect-content-type is not supported with api, which always answers with JSON", i)
+    }
+    for _, m := range f.Mirrors {
+      if !isRemoteURL(m) {
+        return nil, fmt.Errorf("files[%d]: mirror %q must be an http(s) URL", i, m)
+      }
+    }
+    if err := validateVarType(f.VarType); err != nil {
+      return nil, fmt.Errorf("files[%d]: %v", i, err)
+    }
+    if err := validateTypeName(f.TypeName); err != nil {
+      return nil, fmt.Errorf("files[%d]: %v", i, err)
+    }
+    if f.VarType == "bytes" && (f.EmbedEncoding == "hex" || f.EmbedEncoding == "base64") {
+      return nil, fmt.Errorf("files[%d]: var-type bytes cannot be used with embed-encoding %s, whose variable holds text", i, f.EmbedEncoding)
+    }
+    switch f.Format {
+    case "", "json", "xml", "yaml":
+    default:
+      return nil, fmt.Errorf("files[%d]: invalid format %q: must be json, xml or yaml", i, f.Format)
+    }
+    switch f.Validate {
+    case "", "json", "yaml", "xml", "sql":
+    default:
+      return nil, fmt.Errorf("files[%d]: invalid validate %q: must be json, yaml, xml or sql", i, f.Validate)
+    }
+    switch f.EmbedEncoding {
+    case "", "raw", "hex", "base64":
+    default:
+      return nil, fmt.Errorf("files[%d]: invalid embed-encoding %q: must be raw, hex or base64", i, f.EmbedEncoding)
+    }
+    for _, p := range f.Ignore {
+      if _, err := path.Match(p, ""); err != nil {
+        return nil, fmt.Errorf("files[%d]: invalid ignore pattern %q: %v", i, p, err)
+      }
+    }
+    if cfg.Files[i].headerTemplates, err = parseHeaderTemplates(f.Headers); err != nil {
+      return nil, fmt.Errorf("files[%d]: %v", i, err)
+    }
+  }
+  if err := validateVarType(cfg.VarType); err != nil {
+    return nil, err
+  }
+  if err := validateTypeName(cfg.TypeName); err != nil {
+    return nil, err
+  }
+  switch cfg.CollisionStrategy {
+  case "", "subdir", "suffix":
+  default:
+    return nil, fmt.Errorf("invalid collision-strategy %q: must be subdir or suffix", cfg.CollisionStrategy)
+  }
+  if err := validateLineEndings(cfg.LineEndings); err != nil {
+    return nil, err
+  }
+  if err := cfg.validateMode(); err != nil {
+    return nil, err
+  }
+  if cfg.GitHub != nil {
+    cfg.GitHub.Owner = expandEnvVars(cfg.GitHub.Owner)
+    cfg.GitHub.Repo = expandEnvVars(cfg.GitHub.Repo)
+    cfg.GitHub.Ref = expandEnvVars(cfg.GitHub.Ref)
+    if cfg.GitHub.Owner == "" || cfg.GitHub.Repo == "" {
+      return nil, fmt.Errorf("github: owner and repo are required")
+    }
+  }
+  if cfg.ManifestGo != "" {
+    if cfg.ManifestGo != filepath.Base(cfg.ManifestGo) || !strings.HasSuffix(cfg.ManifestGo, ".go") || cfg.ManifestGo == filepath.Base(cfg.GoOutput) {
+      return nil, fmt.Errorf("invalid manifest-go %q: must be a .go file name other than go-output; it is written next to go-output", cfg.ManifestGo)
+    }
+  }
+  for source, name := range cfg.Aliases {
+    if !token.IsIdentifier(name) {
+      return nil, fmt.Errorf("invalid alias %q for %s: must be a Go identifier", name, source)
+    }
+  }
+  if cfg.FSFunc != "" && !token.IsIdentifier(cfg.FSFunc) {
+    return nil, fmt.Errorf("invalid fs-func %q: must be a Go identifier", cfg.FSFunc)
+  }
+  if cfg.Registry != "" && !token.IsIdentifier(cfg.Registry) {
+    return nil, fmt.Errorf("invalid registry %q: must be a Go identifier", cfg.Registry)
+  }
+  if cfg.LookupFunc != "" && !token.IsIdentifier(cfg.LookupFunc) {
+    return nil, fmt.Errorf("invalid lookup-func %q: must be a Go identifier", cfg.LookupFunc)
+  }
+  if cfg.Register != nil {
+    if err := cfg.Register.validate(); err != nil {
+      return nil, err
+    }
+  }
+  if cfg.SourceURLs != "" && !token.IsIdentifier(cfg.SourceURLs) {
+    return nil, fmt.Errorf("invalid source-urls %q: must be a Go identifier", cfg.SourceURLs)
+  }
+  for _, h := range cfg.AllowedHosts {
+    if h == "" || strings.ContainsAny(h, "/ ") {
+      return nil, fmt.Errorf("invalid allowed-hosts entry %q: must be a host name such as example.com or *.example.com", h)
+    }
+  }
+  if cfg.MaxVarsPerFile < 0 {
+    return nil, fmt.Errorf("invalid max-vars-per-file %d: must not be negative", cfg.MaxVarsPerFile)
+  }
+  if cfg.Timeout < 0 {
+    return nil, fmt.Errorf("invalid timeout %s: must not be negative", cfg.Timeout)
+  }
+  if cfg.StallTimeout < 0 {
+    return nil, fmt.Errorf("invalid stall-timeout %s: must not be negative", cfg.StallTimeout)
+  }
+  if cfg.MaxSize < 0 {
+    return nil, fmt.Errorf("invalid max-size %d: must not be negative", cfg.MaxSize)
+  }
+  if cfg.OverallTimeout < 0 {
+    return nil, fmt.Errorf("invalid overall-timeout %s: must not be negative", cfg.OverallTimeout)
+  }
+  if cfg.Concurrency < 0 {
+    return nil, fmt.Errorf("invalid concurrency %d: must not be negative", cfg.Concurrency)
+  }
+  if _, ok := tlsVersions[cfg.TLSMinVersion]; !ok && cfg.TLSMinVersion != "" {
+    return nil, fmt.Errorf("invalid tls-min-version %q: must be 1.0, 1.1, 1.2 or 1.3", cfg.TLSMinVersion)
+  }
+  if cfg.MaxRedirects != nil && *cfg.MaxRedirects < 0 {
+    return nil, fmt.Errorf("invalid max-redirects %d: must not be negative", *cfg.MaxRedirects)
+  }
+  return &cfg, nil
+}
+
+// validateLineEndings checks a line-endings value
+func validateLineEndings(v string) error {
+  switch v {
+  case "", "keep", "lf", "crlf":
+    return nil
+  }
+  return fmt.Errorf("invalid line-endings %q: must be lf, crlf or keep", v)
+}
+
+// authTokens returns the configured tokens for newRequest
+func (cfg *EmbedConfig) authTokens() authTokens {
+  t := authTokens{github: cfg.GithubToken, gitlab: cfg.GitLabToken, bitbucket: cfg.BitbucketToken}
+  if len(cfg.Tokens) > 0 {
+    t.hosts = make(map[string]string, len(cfg.Tokens))
+    for host, token := range cfg.Tokens {
+      t.hosts[strings.ToLower(host)] = token
+    }
+  }
+  return t
+}
+
+// validateVarType checks a var-type value
+func validateVarType(v string) error {
+  switch v {
+  case "", "string", "bytes", "auto":
+    return nil
+  }
+  return fmt.Errorf("invalid var-type %q: must be string, bytes or auto", v)
+}
+
+// validateTypeName checks a type-name value, which is declared in the generated package
+func validateTypeName(name string) error {
+  if name != "" && !token.IsIdentifier(name) {
+    return fmt.Errorf("invalid type-name %q: must be a Go identifier", name)
+  }
+  return nil
+}
+
+// typeName returns the type-name of a file: its own or the top-level one
+func (cfg *EmbedConfig) typeName(entry *FileEntry) string {
+  if entry.TypeName != "" {
+    return entry.TypeName
+  }
+  return cfg.TypeName
+}
+
+// varType returns the var-type of a file: its own, the top-level one or "string".
+// Encoded content is text that its accessor decodes, so it always stays a string
+func (cfg *EmbedConfig) varType(entry *FileEntry) string {
+  t := cfg.VarType
+  if entry.VarType != "" {
+    t = entry.VarType
+  }
+  if t == "" || entry.EmbedEncoding == "hex" || entry.EmbedEncoding == "base64" {
+    return "string"
+  }
+  return t
+}
+
+// expandedValues returns the config values that environment variables are expanded in, before expansion
+func (cfg *EmbedConfig) expandedValues() []string {
+  values := []string{cfg.GithubToken, cfg.GitLabToken, cfg.BitbucketToken, cfg.CacheDir, cfg.ArtifactDir, cfg.Banner}
+  for _, host := range slices.Sorted(maps.Keys(cfg.Tokens)) {
+    values = append(values, cfg.Tokens[host])
+  }
+  if cfg.GitHub != nil {
+    values = append(values, cfg.GitHub.Owner, cfg.GitHub.Repo, cfg.GitHub.Ref)
+  }
+  for _, f := range cfg.Files {
+    // The payload of a data: URI is used as written
+    if !f.isDataURI(f.Source) {
+      values = append(values, f.Source)
+    }
+    values = append(values, f.GitHub, f.Index, f.PublicKey, f.SignatureURL)
+    values = append(values, f.Mirrors...)
+    if f.Merge != nil {
+      values = append(values, f.Merge.Files...)
+    }
+  }
+  return values
+}
+
+// envReferences returns the names of the environment variables referenced by the config values
+// that are expanded, in order of first use. Variables with a ${VAR:-default} are optional and left out.
+// It has to run before the values are expanded
+func (cfg *EmbedConfig) envReferences() []string {
+  var names []string
+  for _, v := range cfg.expandedValues() {
+    expandRefs(v, func(ref string) string {
+      name, op, _ := parseEnvRef(ref)
+      if op != ":-" && !slices.Contains(names, name) {
+        names = append(names, name)
+      }
+      return ""
+    })
+  }
+  return names
+}
+
+// checkRequiredEnv fails with the message of the first ${VAR:?message} whose variable is unset or empty
+func (cfg *EmbedConfig) checkRequiredEnv() error {
+  var err error
+  for _, v := range cfg.expandedValues() {
+    expandRefs(v, func(ref string) string {
+      name, op, message := parseEnvRef(ref)
+      if err == nil && op == ":?" && getEnv(name) == "" {
+        if message == "" {
+          message = "not set"
+        }
+        err = fmt.Errorf("%s: %s", name, expandEnvVars(message))
+      }
+      return ""
+    })
+  }
+  return err
+}
+
+// unsetEnvVars returns the referenced environment variables that are unset or empty, in .env or the environment
+func (cfg *EmbedConfig) unsetEnvVars() []string {
+  var unset []string
+  for _, name := range cfg.envRefs {
+    if getEnv(name) == "" {
+      unset = append(unset, name)
+    }
+  }
+  return unset
+}
+
+// tlsVersions maps the values of tls-min-version to crypto/tls versions
+var tlsVersions = map[string]uint16{
+  "1.0": tls.VersionTLS10,
+  "1.1": tls.VersionTLS11,
+  "1.2": tls.VersionTLS12,
+  "1.3": tls.VersionTLS13,
+}
+
+// tlsMinVersion returns the configured minimum TLS version, or zero for the crypto/tls default
+func (cfg *EmbedConfig) tlsMinVersion() uint16 {
+  return tlsVersions[cfg.TLSMinVersion]
+}
+
+// maxRedirects returns the configured redirect cap or the default
+func (cfg *EmbedConfig) maxRedirects() int {
+  if cfg.MaxRedirects == nil {
+    return defaultMaxRedirects
+  }
+  return *cfg.MaxRedirects
+}
+
+// modePathOnly as mode generates the paths of the assets instead of embedding them
+const modePathOnly = "path-only"
+
+// pathOnly reports whether mode is path-only
+func (cfg *EmbedConfig) pathOnly() bool {
+  return cfg.Mode == modePathOnly
+}
+
+// validateMode checks mode and, for path-only, that no option needs the content of the assets in Go code
+func (cfg *EmbedConfig) validateMode() error {
+  switch cfg.Mode {
+  case "", "embed":
+    return nil
+  case modePathOnly:
+  default:
+    return fmt.Errorf("invalid mode %q: must be embed or path-only", cfg.Mode)
+  }
+  options := []struct {
+    name string
+    set  bool
+  }{
+    {"registry", cfg.Registry != ""},
+    {"lookup-func", cfg.LookupFunc != ""},
+    {"fs-func", cfg.FSFunc != ""},
+    {"register", cfg.Register != nil},
+    {"validate-on-init", cfg.ValidateOnInit},
+    {"hot-reload", cfg.HotReload},
+  }
+  for _, o := range options {
+    if o.set {
+      return fmt.Errorf("%s cannot be used with mode: path-only, which embeds no content", o.name)
+    }
+  }
+  for i, f := range cfg.Files {
+    if f.Literal {
+      return fmt.Errorf("files[%d]: literal cannot be used with mode: path-only, which embeds no content", i)
+    }
+  }
+  return nil
+}
+
+// goOutputNone as go-output only fetches the assets, without generating Go code
+const goOutputNone = "none"
+
+// assetsOnly reports whether go-output is none
+func (cfg *EmbedConfig) assetsOnly() bool {
+  return cfg.GoOutput == goOutputNone
+}
+
+// generatedFiles returns the absolute paths of the files the tool generates besides the assets
+func (cfg *EmbedConfig) generatedFiles(baseDir string) []string {
+  var files []string
+  if !cfg.assetsOnly() {
+    goOutput := resolvePath(baseDir, cfg.GoOutput)
+    files = append(files, goOutput)
+    if cfg.ManifestGo != "" {
+      files = append(files, filepath.Join(filepath.Dir(goOutput), cfg.ManifestGo))
+    }
+  }
+  if cfg.Lockfile != "" {
+    files = append(files, resolvePath(baseDir, cfg.Lockfile))
+  }
+  return files
+}
+
+// goOutputFiles returns the absolute paths of the Go files generated for n assets:
+// go-output, or with max-vars-per-file its numbered parts
+func (cfg *EmbedConfig) goOutputFiles(baseDir string, n int) []string {
+  goOutput := resolvePath(baseDir, cfg.GoOutput)
+  if cfg.MaxVarsPerFile == 0 {
+    return []string{goOutput}
+  }
+  files := []string{goOutputPart(goOutput, 1)}
+  for i := 2; (i-1)*cfg.MaxVarsPerFile < n; i++ {
+    files = append(files, goOutputPart(goOutput, i))
+  }
+  return files
+}
+
+// isGenerated reports whether the absolute path p is written by the tool besides the assets.
+// Any numbered part of go-output counts, since their number depends on the assets
+func (cfg *EmbedConfig) isGenerated(baseDir, p string) bool {
+  if slices.Contains(cfg.generatedFiles(baseDir), p) {
+    return true
+  }
+  if cfg.MaxVarsPerFile == 0 || cfg.assetsOnly() {
+    return false
+  }
+  goOutput := resolvePath(baseDir, cfg.GoOutput)
+  ext := goOutputExt(goOutput)
+  suffix, ok := strings.CutPrefix(p, strings.TrimSuffix(goOutput, ext))
+  return ok && strings.HasSuffix(suffix, ext) && goOutputPartPattern.MatchString(strings.TrimSuffix(suffix, ext))
+}
+
+// testPackage reports whether the generated code goes into an external test package
+func (cfg *EmbedConfig) testPackage() bool {
+  return cfg.PackageSuffix == "_test" || strings.HasSuffix(strings.TrimSpace(cfg.GoMod), "_test")
+}
+
+// concurrency returns the number of parallel fetches
+func (cfg *EmbedConfig) concurrency() int {
+  if cfg.Concurrency == 0 {
+    return defaultConcurrency
+  }
+  return cfg.Concurrency
+}
+
+// normalizeOptions returns the effective text normalization for a file, per-file settings taking precedence
+func (cfg *EmbedConfig) normalizeOptions(entry *FileEntry) (lineEndings string, trailingNewline bool) {
+  lineEndings = cfg.LineEndings
+  if entry.LineEndings != "" {
+    lineEndings = entry.LineEndings
+  }
+  trailingNewline = cfg.EnsureTrailingNewline
+  if entry.EnsureTrailingNewline != nil {
+    trailingNewline = *entry.EnsureTrailingNewline
+  }
+  return lineEndings, trailingNewline
+}
+
+// stripBOM reports whether the byte order mark of a file is removed: its own strip-bom or the top-level one
+func (cfg *EmbedConfig) stripBOM(entry *FileEntry) bool {
+  if entry.StripBOM != nil {
+    return *entry.StripBOM
+  }
+  return cfg.StripBOM
+}
+
+// rewritesContent reports whether the embedded content of entry can differ from the downloaded bytes
+func (cfg *EmbedConfig) rewritesContent(entry *FileEntry) bool {
+  lineEndings, trailingNewline := cfg.normalizeOptions(entry)
+  return entry.Encoding != "" || entry.Pipe != "" || cfg.stripBOM(entry) || len(entry.RewriteURLs) > 0 || (lineEndings != "" && lineEndings != "keep") || trailingNewline ||
+    entry.EmbedEncoding == "hex" || entry.EmbedEncoding == "base64"
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestFileEntryUnmarshal(t *testing.T) {
+	configContent := `line-endings: lf
+files:
+  - https://example.com/plain.txt
+  - source: https://example.com/windows.txt
+    line-endings: crlf
+    ensure-trailing-newline: true
+`
+	var cfg EmbedConfig
+	if err := yaml.Unmarshal([]byte(configContent), &cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	if len(cfg.Files) != 2 {
+		t.Fatalf("len(Files) = %d, want 2", len(cfg.Files))
+	}
+	if cfg.Files[0].Source != "https://example.com/plain.txt" {
+		t.Errorf("Files[0].Source = %q", cfg.Files[0].Source)
+	}
+	if cfg.Files[1].Source != "https://example.com/windows.txt" {
+		t.Errorf("Files[1].Source = %q", cfg.Files[1].Source)
+	}
+
+	lineEndings, trailingNewline := cfg.normalizeOptions(&cfg.Files[0])
+	if lineEndings != "lf" || trailingNewline {
+		t.Errorf("Files[0] normalize options = (%q, %v), want (\"lf\", false)", lineEndings, trailingNewline)
+	}
+	lineEndings, trailingNewline = cfg.normalizeOptions(&cfg.Files[1])
+	if lineEndings != "crlf" || !trailingNewline {
+		t.Errorf("Files[1] normalize options = (%q, %v), want (\"crlf\", true)", lineEndings, trailingNewline)
+	}
+}
+
+func TestLoadConfigRejectsInvalidLineEndings(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `files:
+  - source: a.txt
+    line-endings: cr
+`,
+	})
+	if _, err := loadConfig(tmpDir + "/embed.yaml"); err == nil {
+		t.Fatal("expected an error for an invalid line-endings value")
+	}
+}
+
+func TestLoadConfigRegister(t *testing.T) {
+	tests := []struct {
+		name    string
+		hook    string
+		wantErr string
+	}{
+		{"qualified", "  import: example.com/app/catalog\n  func: catalog.Register\n", ""},
+		{"local", "  func: register\n", ""},
+		{"missing func", "  import: example.com/app/catalog\n", "func is required"},
+		{"unqualified with import", "  import: example.com/app/catalog\n  func: Register\n", "must be qualified"},
+		{"qualified without import", "  func: catalog.Register\n", "needs the import path"},
+		{"invalid func", "  import: example.com/app/catalog\n  func: catalog.Re-gister\n", "invalid register func"},
+		{"invalid import", "  import: example.com/app catalog\n  func: catalog.Register\n", "invalid register import"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "register:\n" + tt.hook + "files:\n  - a.txt\n"})
+			_, err := loadConfig(tmpDir + "/embed.yaml")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("loadConfig() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGoOutputDirectory(t *testing.T) {
+	tests := []struct {
+		name     string
+		goOutput string
+		expected string
+	}{
+		{"trailing slash", "internal/assets/", "internal/assets/embed.go"},
+		{"existing directory", "web", "web/embed.go"},
+		{"file", "web/assets.go", "web/assets.go"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"src/greeting.txt": "hello",
+				"web/doc.go":       "package web\n",
+				"embed.yaml":       "output: " + filepath.Dir(tt.expected) + "/files\ngo-output: " + tt.goOutput + "\nfiles:\n  - src/greeting.txt\n",
+			})
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			data, err := os.ReadFile(filepath.Join(tmpDir, filepath.FromSlash(tt.expected)))
+			if err != nil {
+				t.Fatalf("generated file not found: %v", err)
+			}
+			wantPkg := "package " + filepath.Base(filepath.Dir(tt.expected)) + "\n"
+			if !strings.Contains(string(data), wantPkg) {
+				t.Errorf("%s does not contain %q:\n%s", tt.expected, wantPkg, data)
+			}
+		})
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/prod/config.xml":    "prod",
+		"src/staging/config.xml": "staging",
+		"src/shared.txt":         "shared",
+		"base/common.yaml": `output: assets
+go-mod: main
+max-size: 1024
+tokens:
+  git.example.com: base-token
+github:
+  owner: myorg
+  repo: schemas
+  ref: v1
+files:
+  - src/shared.txt
+`,
+		"base/prod.yaml": `includes:
+  - common.yaml
+output: prod-assets
+files:
+  - src/prod/config.xml
+`,
+		"embed.yaml": `includes:
+  - base/prod.yaml
+output: staging-assets
+tokens:
+  cdn.example.com: cdn-token
+github:
+  ref: v2
+files:
+  - src/staging/config.xml
+`,
+	})
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+
+	// Scalars set later override, settings only set earlier are kept
+	if cfg.Output != "staging-assets" || cfg.GoMod != "main" || cfg.MaxSize != 1024 {
+		t.Errorf("output = %q, go-mod = %q, max-size = %d; want the overlay's output and the base's other settings", cfg.Output, cfg.GoMod, cfg.MaxSize)
+	}
+	// Mappings are merged key by key
+	if cfg.GitHub.Owner != "myorg" || cfg.GitHub.Repo != "schemas" || cfg.GitHub.Ref != "v2" {
+		t.Errorf("github = %+v, want the base repository at ref v2", *cfg.GitHub)
+	}
+	if len(cfg.Tokens) != 2 || cfg.Tokens["git.example.com"] != "base-token" || cfg.Tokens["cdn.example.com"] != "cdn-token" {
+		t.Errorf("tokens = %v, want both hosts", cfg.Tokens)
+	}
+	// Files are appended in include order
+	var sources []string
+	for _, f := range cfg.Files {
+		sources = append(sources, f.Source)
+	}
+	if want := "src/shared.txt src/prod/config.xml src/staging/config.xml"; strings.Join(sources, " ") != want {
+		t.Errorf("files = %v, want %s", sources, want)
+	}
+	want := []string{filepath.Join(tmpDir, "base", "common.yaml"), filepath.Join(tmpDir, "base", "prod.yaml")}
+	if strings.Join(cfg.included, " ") != strings.Join(want, " ") {
+		t.Errorf("included = %v, want %v", cfg.included, want)
+	}
+
+	// Unique names are resolved over the merged files
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "includes:\n  - base/prod.yaml\nfiles:\n  - ./src/staging/config.xml\n"})
+	if err := os.WriteFile(filepath.Join(tmpDir, "base", "common.yaml"), []byte("output: assets\ngo-mod: main\nfiles:\n  - src/shared.txt\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	for name, content := range map[string]string{"shared.txt": "shared", "prod/config.xml": "prod", "staging/config.xml": "staging"} {
+		if data, err := os.ReadFile(filepath.Join(tmpDir, "prod-assets", name)); err != nil || string(data) != content {
+			t.Errorf("prod-assets/%s = %q, %v; want %q", name, data, err, content)
+		}
+	}
+}
+
+func TestIncludeDiamond(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"base.yaml":  "output: assets\nfiles:\n  - a.txt\n",
+		"b.yaml":     "includes:\n  - base.yaml\nfiles:\n  - b.txt\n",
+		"c.yaml":     "includes:\n  - ./base.yaml\nfiles:\n  - c.txt\n",
+		"embed.yaml": "includes:\n  - b.yaml\n  - c.yaml\n",
+	})
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	var sources []string
+	for _, f := range cfg.Files {
+		sources = append(sources, f.Source)
+	}
+	if want := []string{"a.txt", "b.txt", "c.txt"}; !slices.Equal(sources, want) {
+		t.Errorf("files = %v, want %v", sources, want)
+	}
+	if len(cfg.included) != 3 {
+		t.Errorf("included = %v, want base.yaml, b.yaml and c.yaml once each", cfg.included)
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "includes:\n  - other.yaml\nfiles:\n  - a.txt\n",
+		"other.yaml": "includes:\n  - embed.yaml\n",
+	})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "embed.yaml includes itself") {
+		t.Errorf("loadConfig() error = %v, want the cycle reported", err)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+  "encoding/base64"
+  "fmt"
+  "net/url"
+  "strings"
+)
+
+// isDataURI reports whether source is an inline data: URI
+func isDataURI(source string) bool {
+  return len(source) >= 5 && strings.EqualFold(source[:5], "data:")
+}
+
+// decodeDataURI returns the payload of a data:[<mediatype>][;base64],<data> URI.
+// Percent-encoded octets are decoded in both forms; base64 may be padded or not
+func decodeDataURI(uri string) ([]byte, error) {
+  header, payload, found := strings.Cut(uri[len("data:"):], ",")
+  if !found {
+    return nil, fmt.Errorf("invalid data URI: missing ','")
+  }
+  decoded, err := url.PathUnescape(payload)
+  if err != nil {
+    return nil, fmt.Errorf("invalid data URI: %v", err)
+  }
+  if !strings.HasSuffix(strings.ToLower(header), ";base64") {
+    return []byte(decoded), nil
+  }
+  decoded = strings.TrimRight(strings.Join(strings.Fields(decoded), ""), "=")
+  data, err := base64.RawStdEncoding.DecodeString(decoded)
+  if err != nil {
+    return nil, fmt.Errorf("invalid data URI: %v", err)
+  }
+  return data, nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeDataURI(t *testing.T) {
+	tests := []struct {
+		uri      string
+		expected string
+		wantErr  bool
+	}{
+		{"data:text/plain;base64,aGVsbG8gd29ybGQ=", "hello world", false},
+		{"data:;base64,aGVsbG8", "hello", false},
+		{"data:text/plain;charset=utf-8,hello%20world%21", "hello world!", false},
+		{"data:,a+b", "a+b", false},
+		{"DATA:application/json;BASE64,e30=", "{}", false},
+		{"data:text/plain;base64", "", true},
+		{"data:;base64,not base64!", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.uri, func(t *testing.T) {
+			got, err := decodeDataURI(tt.uri)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("decodeDataURI() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeDataURI() error: %v", err)
+			}
+			if string(got) != tt.expected {
+				t.Errorf("decodeDataURI() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRunDataURI(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: data:application/json;base64,eyJvayI6IHRydWV9
+    name: fixtures/status.json
+`})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "status.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `{"ok": true}` {
+		t.Errorf("status.json = %q", data)
+	}
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if !strings.Contains(string(embedGo), "//go:embed assets/status.json\nvar Status string") {
+		t.Errorf("embed.go does not embed status.json:\n%s", embedGo)
+	}
+}
+
+func TestDataURIRequiresName(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "files:\n  - data:,hello\n"})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "name is required for data: sources") {
+		t.Errorf("loadConfig() error = %v, want a missing name error", err)
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+  "fmt"
+  "strings"
+)
+
+// diffContext is the number of unchanged lines shown around each change
+const diffContext = 3
+
+// diffOp is a single line of an edit script: ' ' kept, '-' removed, '+' added
+type diffOp struct {
+  kind byte
+  line string
+}
+
+// unifiedDiff returns a unified diff turning oldText into newText, or "" when they are equal
+func unifiedDiff(oldName, newName, oldText, newText string) string {
+  if oldText == newText {
+    return ""
+  }
+  ops := diffLines(splitLines(oldText), splitLines(newText))
+
+  var b strings.Builder
+  fmt.Fprintf(&b, "--- %s\n+++ %s\n", oldName, newName)
+
+  // Walk the edit script and group changes with their surrounding context into hunks
+  for i := 0; i < len(ops); {
+    if ops[i].kind == ' ' {
+      i++
+      continue
+    }
+    start := i - diffContext
+    if start < 0 {
+      start = 0
+    }
+    // Extend the hunk while the next change is close enough to share context
+    end := i
+    for end < len(ops) {
+      if ops[end].kind != ' ' {
+        end++
+        continue
+      }
+      next := end
+      for next < len(ops) && ops[next].kind == ' ' {
+        next++
+      }
+      if next == len(ops) || next-end > 2*diffContext {
+        end += diffContext
+        if end > len(ops) {
+          end = len(ops)
+        }
+        break
+      }
+      end = next
+    }
+
+    oldStart, newStart := 1, 1
+    for _, op := range ops[:start] {
+      if op.kind != '+' {
+        oldStart++
+      }
+      if op.kind != '-' {
+        newStart++
+      }
+    }
+    oldCount, newCount := 0, 0
+    for _, op := range ops[start:end] {
+      if op.kind != '+' {
+        oldCount++
+      }
+      if op.kind != '-' {
+        newCount++
+      }
+    }
+    if oldCount == 0 {
+      oldStart--
+    }
+    if newCount == 0 {
+      newStart--
+    }
+    fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+    for _, op := range ops[start:end] {
+      b.WriteByte(op.kind)
+      b.WriteString(op.line)
+      b.WriteByte('\n')
+    }
+    i = end
+  }
+  return b.String()
+}
+
+// splitLines splits text into lines without their trailing newline
+func splitLines(text string) []string {
+  if text == "" {
+    return nil
+  }
+  return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines computes a minimal line edit script using the longest common subsequence
+func diffLines(a, b []string) []diffOp {
+  // lcs[i][j] is the LCS length of a[i:] and b[j:]
+  lcs := make([][]int, len(a)+1)
+  for i := range lcs {
+    lcs[i] = make([]int, len(b)+1)
+  }
+  for i := len(a) - 1; i >= 0; i-- {
+    for j := len(b) - 1; j >= 0; j-- {
+      if a[i] == b[j] {
+        lcs[i][j] = lcs[i+1][j+1] + 1
+      } else if lcs[i+1][j] >= lcs[i][j+1] {
+        lcs[i][j] = lcs[i+1][j]
+      } else {
+        lcs[i][j] = lcs[i][j+1]
+      }
+    }
+  }
+
+  var ops []diffOp
+  i, j := 0, 0
+  for i < len(a) && j < len(b) {
+    switch {
+    case a[i] == b[j]:
+      ops = append(ops, diffOp{' ', a[i]})
+      i++
+      j++
+    case lcs[i+1][j] >= lcs[i][j+1]:
+      ops = append(ops, diffOp{'-', a[i]})
+      i++
+    default:
+      ops = append(ops, diffOp{'+', b[j]})
+      j++
+    }
+  }
+  for ; i < len(a); i++ {
+    ops = append(ops, diffOp{'-', a[i]})
+  }
+  for ; j < len(b); j++ {
+    ops = append(ops, diffOp{'+', b[j]})
+  }
+  return ops
+}
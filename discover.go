@@ -0,0 +1,65 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "io/fs"
+  "os"
+  "path/filepath"
+  "strings"
+)
+
+// discoverConfigs returns every embed.yaml below root that belongs to the same module,
+// skipping hidden directories, vendor, testdata, node_modules and nested modules
+func discoverConfigs(root string) ([]string, error) {
+  var configs []string
+  err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    if d.IsDir() {
+      if p == root {
+        return nil
+      }
+      name := d.Name()
+      if strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "vendor" || name == "testdata" || name == "node_modules" {
+        return filepath.SkipDir
+      }
+      // A directory with its own go.mod is a different module, which go generate ./... skips too
+      if _, err := os.Stat(filepath.Join(p, "go.mod")); err == nil {
+        return filepath.SkipDir
+      }
+      return nil
+    }
+    if d.Name() == "embed.yaml" && d.Type().IsRegular() {
+      configs = append(configs, p)
+    }
+    return nil
+  })
+  if err != nil {
+    return nil, fmt.Errorf("failed to discover configs in %s: %v", root, err)
+  }
+  return configs, nil
+}
+
+// runAll generates every config discovered below root in place, each relative to its own directory
+func runAll(root string, opts options, stdout io.Writer) error {
+  configs, err := discoverConfigs(root)
+  if err != nil {
+    return err
+  }
+  if len(configs) == 0 {
+    return fmt.Errorf("no embed.yaml found in %s", root)
+  }
+  for _, configPath := range configs {
+    name, _ := filepath.Rel(root, configPath)
+    if !opts.diff && !opts.list {
+      fmt.Fprintf(stdout, "generating %s\n", filepath.ToSlash(name))
+    }
+    opts.config = configPath
+    if err := run(root, opts, stdout); err != nil {
+      return fmt.Errorf("%s: %w", filepath.ToSlash(name), err)
+    }
+  }
+  return nil
+}
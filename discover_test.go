@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunAll(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"go.mod": "module example.com/app\n",
+		"web/embed.yaml": `output: assets
+files:
+  - src/index.html
+`,
+		"web/src/index.html": "<html/>",
+		"web/main.go":        "package web\n",
+		"internal/schemas/embed.yaml": `go-output: schemas.go
+files:
+  - ../../shared/user.json
+`,
+		"internal/schemas/doc.go": "package schemas\n",
+		"shared/user.json":        "{}",
+		// Not part of the module or not meant to be generated
+		"tools/go.mod":              "module example.com/tools\n",
+		"tools/embed.yaml":          "files: [missing.txt]\n",
+		".cache/embed.yaml":         "files: [missing.txt]\n",
+		"testdata/bad/embed.yaml":   "files: [missing.txt]\n",
+		"vendor/x/embed.yaml":       "files: [missing.txt]\n",
+		"node_modules/y/embed.yaml": "files: [missing.txt]\n",
+	})
+
+	var out bytes.Buffer
+	if err := runAll(tmpDir, options{}, &out); err != nil {
+		t.Fatalf("runAll() error: %v", err)
+	}
+	expected := "generating internal/schemas/embed.yaml\ngenerating web/embed.yaml\n"
+	if out.String() != expected {
+		t.Errorf("output = %q, want %q", out.String(), expected)
+	}
+
+	// Each config is generated relative to its own directory
+	for file, want := range map[string]string{
+		"web/assets/index.html":       "<html/>",
+		"internal/schemas/user.json":  "{}",
+		"web/embed.go":                "package web",
+		"internal/schemas/schemas.go": "package schemas",
+	} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, file))
+		if err != nil {
+			t.Errorf("missing %s: %v", file, err)
+			continue
+		}
+		if !strings.Contains(string(data), want) {
+			t.Errorf("%s = %q, want it to contain %q", file, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "tools", "embed.go")); !os.IsNotExist(err) {
+		t.Errorf("config in a nested module was generated (stat error: %v)", err)
+	}
+}
+
+func TestRunAllReportsFailingConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"a/embed.yaml": "files: [missing.txt]\n",
+	})
+	err := runAll(tmpDir, options{}, &bytes.Buffer{})
+	if err == nil || !strings.HasPrefix(err.Error(), "a/embed.yaml: ") {
+		t.Errorf("runAll() error = %v, want it prefixed with the config path", err)
+	}
+
+	if err := runAll(t.TempDir(), options{}, &bytes.Buffer{}); err == nil {
+		t.Error("runAll() should fail when no config is found")
+	}
+}
@@ -0,0 +1,438 @@
+package main
+
+import (
+  "compress/gzip"
+  "context"
+  "crypto/tls"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "os"
+  "strings"
+  "sync"
+  "time"
+)
+
+// newHTTPClient returns the client used for remote downloads.
+// Redirects are followed up to maxRedirects hops and redirect loops are rejected,
+// as are redirects to hosts outside allowedHosts when it is not empty. Tokens never follow a redirect to another provider.
+// A non-zero tlsMinVersion refuses servers that only offer older TLS versions.
+func newHTTPClient(maxRedirects int, allowedHosts []string, tlsMinVersion uint16) *http.Client {
+  var transport http.RoundTripper
+  if tlsMinVersion != 0 {
+    t := http.DefaultTransport.(*http.Transport).Clone()
+    t.TLSClientConfig = &tls.Config{MinVersion: tlsMinVersion}
+    transport = t
+  }
+  return &http.Client{
+    Transport: transport,
+    CheckRedirect: func(req *http.Request, via []*http.Request) error {
+      if !hostAllowed(req.URL, allowedHosts) {
+        return fmt.Errorf("redirect to %s refused: host %s is not in allowed-hosts", req.URL, req.URL.Hostname())
+      }
+      for _, prev := range via {
+        if prev.URL.String() == req.URL.String() {
+          return fmt.Errorf("redirect loop detected at %s", req.URL)
+        }
+      }
+      if len(via) > maxRedirects {
+        if maxRedirects == 0 {
+          return fmt.Errorf("redirect to %s not followed: redirects are disabled (max-redirects: 0)", req.URL)
+        }
+        return fmt.Errorf("stopped after %d redirects (max-redirects: %d)", maxRedirects, maxRedirects)
+      }
+      reauthorize(req, via)
+      return nil
+    },
+  }
+}
+
+// deadlineTransport attaches ctx to every request, so cancelling it aborts in-flight downloads
+type deadlineTransport struct {
+  ctx  context.Context
+  base http.RoundTripper
+}
+
+// RoundTrip sends req bound to both its own context (e.g. a per-file timeout) and the transport's context
+func (t deadlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+  // The derived context outlives RoundTrip while the body is read; it is released when the body is closed
+  ctx, cancel := context.WithCancel(req.Context())
+  stop := context.AfterFunc(t.ctx, cancel)
+  release := func() {
+    stop()
+    cancel()
+  }
+  resp, err := t.base.RoundTrip(req.WithContext(ctx))
+  if err != nil {
+    release()
+    return nil, err
+  }
+  resp.Body = &releaseBody{ReadCloser: resp.Body, release: release}
+  return resp, nil
+}
+
+// releaseBody calls release once the response body is closed
+type releaseBody struct {
+  io.ReadCloser
+  release func()
+  once    sync.Once
+}
+
+func (b *releaseBody) Close() error {
+  err := b.ReadCloser.Close()
+  b.once.Do(b.release)
+  return err
+}
+
+// fetchOptions holds per-request settings for fetchURL
+type fetchOptions struct {
+  auth        authTokens
+  headers     http.Header   // extra request headers
+  timeout     time.Duration // limit of each request including its body; zero means none
+  stall       time.Duration // limit on waiting for the next bytes of the response; zero means none
+  maxSize     int64         // largest accepted body after decompression; zero means no limit
+  contentType string        // expected Content-Type prefix; empty accepts any
+  byteRange   string        // Range header; the response must then be 206 Partial Content
+}
+
+// assetFetchOptions returns the request settings for a remote asset, rendering its header templates
+func assetFetchOptions(cfg *EmbedConfig, a asset) (fetchOptions, error) {
+  headers, err := renderHeaders(a.entry.headerTemplates, headerData{Name: a.shortName, Path: a.sourcePath, URL: a.expandedURL})
+  if err != nil {
+    return fetchOptions{}, fmt.Errorf("%s: %v", a.expandedURL, err)
+  }
+  timeout := cfg.Timeout
+  if a.entry.Timeout > 0 {
+    timeout = a.entry.Timeout
+  }
+  stall := cfg.StallTimeout
+  if a.entry.StallTimeout > 0 {
+    stall = a.entry.StallTimeout
+  }
+  maxSize := cfg.MaxSize
+  if a.entry.MaxSize > 0 {
+    maxSize = a.entry.MaxSize
+  }
+  return fetchOptions{auth: cfg.authTokens(), headers: headers, timeout: timeout, stall: stall, maxSize: maxSize, contentType: a.entry.ExpectContentType, byteRange: a.entry.Range}, nil
+}
+
+// newRequest builds a request for url carrying the extra headers and the token configured for its host
+func newRequest(method, url string, opts fetchOptions) (*http.Request, error) {
+  req, err := http.NewRequest(method, url, nil)
+  if err != nil {
+    return nil, fmt.Errorf("failed to create request for %s: %v", url, err)
+  }
+  for name, values := range opts.headers {
+    req.Header[name] = values
+  }
+  if opts.byteRange != "" {
+    req.Header.Set("Range", opts.byteRange)
+  }
+  // Redirects are authorized again for their host, see reauthorize
+  req = req.WithContext(context.WithValue(req.Context(), authTokensKey{}, opts.auth))
+  opts.auth.authorize(req)
+  return req, nil
+}
+
+// fetchURL downloads url with client and returns the response body.
+// Tokens are only sent to the hosts they are configured for.
+func fetchURL(client *http.Client, url string, opts fetchOptions) ([]byte, error) {
+  f, err := fetchRemote(client, url, opts)
+  return f.data, err
+}
+
+// sourceFile is the content of a file together with where and when its source last changed it
+type sourceFile struct {
+  data     []byte
+  resolved string    // URL after following redirects; empty for local files
+  modTime  time.Time // Last-Modified or the local mtime; zero when unknown
+}
+
+// downloadError is a failed download of url. statusCode is the HTTP status when the server answered
+// and zero otherwise. attempts counts the URLs tried: 1, or the source and its mirrors when all of them failed,
+// in which case err joins the failure of every attempt
+type downloadError struct {
+  url        string
+  statusCode int
+  attempts   int
+  err        error
+}
+
+func (e *downloadError) Error() string {
+  if e.attempts > 1 {
+    return fmt.Sprintf("failed to download %s from any of %d sources:\n  %s", e.url, e.attempts, strings.ReplaceAll(e.err.Error(), "\n", "\n  "))
+  }
+  return fmt.Sprintf("failed to download %s: %v", e.url, e.err)
+}
+
+func (e *downloadError) Unwrap() error {
+  return e.err
+}
+
+// fetchRemote downloads url like fetchURL and also reports where redirects led
+func fetchRemote(client *http.Client, url string, opts fetchOptions) (sourceFile, error) {
+  req, err := newRequest("GET", url, opts)
+  if err != nil {
+    return sourceFile{}, err
+  }
+  if opts.timeout > 0 {
+    ctx, cancel := context.WithTimeout(req.Context(), opts.timeout)
+    defer cancel()
+    req = req.WithContext(ctx)
+  }
+  // The stall timer runs from sending the request, so it also covers connecting and waiting for the headers
+  var stall *time.Timer
+  if opts.stall > 0 {
+    ctx, cancel := context.WithCancelCause(req.Context())
+    defer cancel(nil)
+    stall = time.AfterFunc(opts.stall, func() { cancel(errStalled) })
+    defer stall.Stop()
+    req = req.WithContext(ctx)
+  }
+  failed := func(status int, err error) error {
+    if context.Cause(req.Context()) == errStalled {
+      err = fmt.Errorf("stalled: no data received for %s", opts.stall)
+    } else if req.Context().Err() == context.DeadlineExceeded {
+      err = fmt.Errorf("timed out after %s", opts.timeout)
+    }
+    return &downloadError{url: url, statusCode: status, attempts: 1, err: err}
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    return sourceFile{}, failed(0, err)
+  }
+  defer resp.Body.Close()
+  if stall != nil {
+    stall.Reset(opts.stall)
+  }
+  wantStatus := http.StatusOK
+  if opts.byteRange != "" {
+    // A server that ignores Range sends the whole file, which must not be embedded as the segment
+    if resp.StatusCode == http.StatusOK {
+      return sourceFile{}, failed(resp.StatusCode, fmt.Errorf("server ignored range %s and sent the whole file instead of 206 Partial Content", opts.byteRange))
+    }
+    wantStatus = http.StatusPartialContent
+  }
+  if resp.StatusCode != wantStatus {
+    return sourceFile{}, failed(resp.StatusCode, errors.New(describeStatus(resp)))
+  }
+  if !contentTypeMatches(resp, opts.contentType) {
+    return sourceFile{}, failed(resp.StatusCode, fmt.Errorf("Content-Type %q does not match expect-content-type %q", resp.Header.Get("Content-Type"), opts.contentType))
+  }
+  // net/http only decompresses transparently when it asked for gzip itself,
+  // so a gzip body is still encoded when the request set its own Accept-Encoding
+  var body io.Reader = resp.Body
+  if stall != nil {
+    body = &stallReader{r: resp.Body, timer: stall, stall: opts.stall}
+  }
+  if !resp.Uncompressed && isGzipEncoding(resp.Header.Get("Content-Encoding")) {
+    gz, err := gzip.NewReader(body)
+    if err != nil {
+      return sourceFile{}, fmt.Errorf("failed to decompress %s: %v", url, err)
+    }
+    defer gz.Close()
+    body = gz
+  } else if opts.maxSize > 0 && resp.ContentLength > opts.maxSize {
+    return sourceFile{}, failed(resp.StatusCode, fmt.Errorf("Content-Length %d exceeds max-size %d", resp.ContentLength, opts.maxSize))
+  }
+  // Chunked and compressed responses have no reliable length, so the limit is enforced while reading.
+  // Reading stops one byte past it and the rest of the body is never transferred
+  if opts.maxSize > 0 {
+    body = io.LimitReader(body, opts.maxSize+1)
+  }
+  data, err := io.ReadAll(body)
+  if err != nil {
+    return sourceFile{}, failed(resp.StatusCode, err)
+  }
+  if opts.maxSize > 0 && int64(len(data)) > opts.maxSize {
+    return sourceFile{}, failed(resp.StatusCode, fmt.Errorf("body exceeds max-size %d", opts.maxSize))
+  }
+  // A missing or malformed Last-Modified leaves the time zero
+  modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+  return sourceFile{data: data, resolved: resp.Request.URL.String(), modTime: modTime}, nil
+}
+
+// errStalled is the cancellation cause of a download that stopped receiving data for longer than its stall timeout
+var errStalled = errors.New("download stalled")
+
+// stallReader restarts timer whenever a read returns data, so that it only fires once the body stops arriving
+type stallReader struct {
+  r     io.Reader
+  timer *time.Timer
+  stall time.Duration
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+  n, err := s.r.Read(p)
+  if n > 0 {
+    s.timer.Reset(s.stall)
+  }
+  return n, err
+}
+
+// headUnchanged sends a HEAD request for url and, when its Content-Length equals the size of localFile,
+// returns the local content instead of downloading it again. Any failure (including servers
+// that reject HEAD or omit the length) reports a change so that the caller falls back to GET
+func headUnchanged(client *http.Client, url, localFile string, opts fetchOptions) (sourceFile, bool) {
+  info, err := os.Stat(localFile)
+  if err != nil || !info.Mode().IsRegular() {
+    return sourceFile{}, false
+  }
+  req, err := newRequest("HEAD", url, opts)
+  if err != nil {
+    return sourceFile{}, false
+  }
+  if opts.timeout > 0 {
+    ctx, cancel := context.WithTimeout(req.Context(), opts.timeout)
+    defer cancel()
+    req = req.WithContext(ctx)
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    return sourceFile{}, false
+  }
+  resp.Body.Close()
+  // A compressed length says nothing about the size of the decoded file
+  if resp.StatusCode != 200 || resp.ContentLength < 0 || resp.ContentLength != info.Size() || resp.Header.Get("Content-Encoding") != "" || !contentTypeMatches(resp, opts.contentType) {
+    return sourceFile{}, false
+  }
+  data, err := os.ReadFile(localFile)
+  if err != nil {
+    return sourceFile{}, false
+  }
+  modTime, _ := http.ParseTime(resp.Header.Get("Last-Modified"))
+  return sourceFile{data: data, resolved: resp.Request.URL.String(), modTime: modTime}, true
+}
+
+// memoryCache keeps downloaded content by URL. It is safe for concurrent use, and a nil cache stores nothing
+type memoryCache struct {
+  mu    sync.Mutex
+  files map[string]sourceFile
+}
+
+// newMemoryCache returns an empty memoryCache
+func newMemoryCache() *memoryCache {
+  return &memoryCache{files: make(map[string]sourceFile)}
+}
+
+// get returns the content cached for url
+func (c *memoryCache) get(url string) (sourceFile, bool) {
+  if c == nil {
+    return sourceFile{}, false
+  }
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  f, ok := c.files[url]
+  return f, ok
+}
+
+// put caches the content downloaded from url
+func (c *memoryCache) put(url string, f sourceFile) {
+  if c == nil {
+    return
+  }
+  c.mu.Lock()
+  defer c.mu.Unlock()
+  c.files[url] = f
+}
+
+// describeStatus explains an unexpected response status. A 3xx reaching this point was not followed,
+// so it says why instead of letting the redirect page pass for content
+func describeStatus(resp *http.Response) string {
+  if resp.StatusCode < 300 || resp.StatusCode > 399 {
+    return resp.Status
+  }
+  location := resp.Header.Get("Location")
+  if location == "" {
+    return resp.Status + " without a Location header: malformed redirect"
+  }
+  return fmt.Sprintf("%s to %s was not followed", resp.Status, location)
+}
+
+// contentTypeMatches reports whether the Content-Type of resp starts with expected, ignoring case,
+// so that parameters such as charset do not matter. An empty expected type matches anything
+func contentTypeMatches(resp *http.Response, expected string) bool {
+  if expected == "" {
+    return true
+  }
+  return strings.HasPrefix(strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Type"))), strings.ToLower(expected))
+}
+
+// isGzipEncoding reports whether a Content-Encoding header value denotes gzip
+func isGzipEncoding(encoding string) bool {
+  encoding = strings.ToLower(strings.TrimSpace(encoding))
+  return encoding == "gzip" || encoding == "x-gzip"
+}
+
+// hostAllowed reports whether u may be downloaded from. An empty allowlist allows every host;
+// entries match the host name case-insensitively, or any subdomain when written as *.example.com
+func hostAllowed(u *url.URL, allowedHosts []string) bool {
+  if len(allowedHosts) == 0 {
+    return true
+  }
+  host := strings.ToLower(u.Hostname())
+  for _, allowed := range allowedHosts {
+    allowed = strings.ToLower(allowed)
+    if suffix, ok := strings.CutPrefix(allowed, "*."); ok {
+      if strings.HasSuffix(host, "."+suffix) {
+        return true
+      }
+    } else if host == allowed || strings.ToLower(u.Host) == allowed {
+      return true
+    }
+  }
+  return false
+}
+
+// checkAllowedHosts rejects every remote asset whose host is not allowed, before anything is requested
+func checkAllowedHosts(cfg *EmbedConfig, assets []asset) error {
+  if len(cfg.AllowedHosts) == 0 {
+    return nil
+  }
+  for _, a := range assets {
+    if a.entry.Merge != nil {
+      for _, u := range mergeSources(a.entry, cfg.GitHub) {
+        if !isRemoteURL(u) {
+          continue
+        }
+        if err := checkAllowedHost(u, cfg.AllowedHosts); err != nil {
+          return err
+        }
+      }
+      continue
+    }
+    if !a.entry.isRemote(a.expandedURL) {
+      continue
+    }
+    urls := append([]string{a.expandedURL}, a.mirrors()...)
+    if a.entry.publicKey != nil {
+      urls = append(urls, a.signatureURL())
+    }
+    if a.githubAPI != nil {
+      urls = append(urls, a.githubAPI.contentsURL())
+    } else if cfg.GitHubCommits && a.github != nil {
+      // The commit is looked up through the API host
+      urls = append(urls, a.github.contentsURL())
+    }
+    for _, u := range urls {
+      if err := checkAllowedHost(u, cfg.AllowedHosts); err != nil {
+        return err
+      }
+    }
+  }
+  return nil
+}
+
+// checkAllowedHost rejects rawURL when its host is not allowed
+func checkAllowedHost(rawURL string, allowedHosts []string) error {
+  u, err := url.Parse(rawURL)
+  if err != nil {
+    return fmt.Errorf("invalid URL %s: %v", rawURL, err)
+  }
+  if !hostAllowed(u, allowedHosts) {
+    return fmt.Errorf("refusing to download %s: host %s is not in allowed-hosts", rawURL, u.Hostname())
+  }
+  return nil
+}
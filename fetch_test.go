@@ -0,0 +1,637 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFetchURLDecodesGzip(t *testing.T) {
+	expected := `{"compressed": true}`
+	var gzipped bytes.Buffer
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write([]byte(expected))
+	gz.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped.Bytes())
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		headers http.Header
+	}{
+		// Transport negotiates gzip and decodes transparently
+		{"transparent", nil},
+		// An explicit Accept-Encoding disables transparent decoding
+		{"explicit accept-encoding", http.Header{"Accept-Encoding": []string{"gzip"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := fetchURL(newHTTPClient(defaultMaxRedirects, nil, 0), server.URL+"/data.json", fetchOptions{headers: tt.headers})
+			if err != nil {
+				t.Fatalf("fetchURL() error: %v", err)
+			}
+			if string(data) != expected {
+				t.Errorf("fetchURL() = %q, want decoded %q", data, expected)
+			}
+		})
+	}
+}
+
+func TestAllowedHosts(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/redirect" {
+			// Same server, but under a host name that is not allowed
+			http.Redirect(w, r, "http://"+strings.Replace(r.Host, "127.0.0.1", "localhost", 1)+"/data.json", http.StatusFound)
+			return
+		}
+		w.Write([]byte("data"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		hosts   string
+		path    string
+		wantErr string
+	}{
+		{"disallowed host", "[example.com]", "/data.json", "host 127.0.0.1 is not in allowed-hosts"},
+		{"disallowed redirect", "[127.0.0.1]", "/redirect", "redirect to http://localhost:"},
+		{"allowed", "[example.com, 127.0.0.1]", "/data.json", ""},
+		{"allowed with port", "[" + strings.TrimPrefix(server.URL, "http://") + "]", "/data.json", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests = 0
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "go-mod: main\nallowed-hosts: " + tt.hosts + "\nfiles:\n  - " + server.URL + tt.path + "\n",
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("run() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() error = %v, want %q", err, tt.wantErr)
+			}
+			if tt.name == "disallowed host" && requests != 0 {
+				t.Errorf("server received %d requests, want none", requests)
+			}
+		})
+	}
+}
+
+func TestHeadCheck(t *testing.T) {
+	content := "stable content"
+	allowHead := true
+	var heads, gets int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodHead:
+			heads++
+			if !allowHead {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+		case http.MethodGet:
+			gets++
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(content)))
+		w.Write([]byte(content))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nhead-check: true\nfiles:\n  - " + server.URL + "/data.txt\n"})
+	generate := func() string {
+		t.Helper()
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "data.txt"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	// Without an existing file there is nothing to compare against
+	generate()
+	if heads != 0 || gets != 1 {
+		t.Errorf("first run: %d HEAD, %d GET; want 0 HEAD, 1 GET", heads, gets)
+	}
+
+	heads, gets = 0, 0
+	if got := generate(); got != content {
+		t.Errorf("data.txt = %q, want %q", got, content)
+	}
+	if heads != 1 || gets != 0 {
+		t.Errorf("same size: %d HEAD, %d GET; want 1 HEAD, 0 GET", heads, gets)
+	}
+
+	heads, gets = 0, 0
+	content = "changed, and longer"
+	if got := generate(); got != content {
+		t.Errorf("data.txt = %q, want %q", got, content)
+	}
+	if heads != 1 || gets != 1 {
+		t.Errorf("different size: %d HEAD, %d GET; want 1 HEAD, 1 GET", heads, gets)
+	}
+
+	heads, gets = 0, 0
+	allowHead = false
+	generate()
+	if gets != 1 {
+		t.Errorf("HEAD not allowed: %d GET, want a fallback to GET", gets)
+	}
+}
+
+func TestPerFileTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Headers arrive at once; the body is slow, so the timeout must cover reading it
+		w.(http.Flusher).Flush()
+		time.Sleep(150 * time.Millisecond)
+		w.Write([]byte("large artifact"))
+	}))
+	defer server.Close()
+
+	config := func(fileTimeout string) string {
+		c := "output: assets\ngo-mod: main\ntimeout: 50ms\nfiles:\n  - source: " + server.URL + "/artifact.bin\n"
+		if fileTimeout != "" {
+			c += "    timeout: " + fileTimeout + "\n"
+		}
+		return c
+	}
+
+	t.Run("global timeout", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config("")})
+		err := run(tmpDir, options{}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "timed out after 50ms") {
+			t.Errorf("run() error = %v, want the global timeout to fire", err)
+		}
+	})
+
+	t.Run("override", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config("2s")})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "artifact.bin")); string(data) != "large artifact" {
+			t.Errorf("artifact.bin = %q", data)
+		}
+	})
+
+	t.Run("override with overall timeout", func(t *testing.T) {
+		// The per-file deadline and the run's deadline apply together
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "overall-timeout: 5s\n" + config("2s")})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+	})
+}
+
+// roundTripFunc is an http.RoundTripper calling itself
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestDeadlineTransportReleasesContext(t *testing.T) {
+	runCtx, cancelRun := context.WithCancel(context.Background())
+	defer cancelRun()
+	var sent context.Context
+	fail := false
+	transport := deadlineTransport{ctx: runCtx, base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		sent = req.Context()
+		if fail {
+			return nil, errors.New("connection refused")
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("ok"))}, nil
+	})}
+
+	req := httptest.NewRequest("GET", "http://example.com/a.txt", nil)
+	resp, err := transport.RoundTrip(req)
+	if err != nil {
+		t.Fatalf("RoundTrip() error: %v", err)
+	}
+	// The request stays live while its body is read, and is released with it, long before the run ends
+	if sent.Err() != nil {
+		t.Fatalf("request context ended before the body was closed: %v", sent.Err())
+	}
+	resp.Body.Close()
+	if sent.Err() == nil {
+		t.Errorf("request context is still live after the body was closed")
+	}
+
+	fail = true
+	if _, err := transport.RoundTrip(req); err == nil {
+		t.Fatal("RoundTrip() succeeded, want the transport error")
+	}
+	if sent.Err() == nil {
+		t.Errorf("request context is still live after a failed request")
+	}
+
+	// Ending the run still aborts a request in flight
+	fail = false
+	if _, err := transport.RoundTrip(req); err != nil {
+		t.Fatalf("RoundTrip() error: %v", err)
+	}
+	cancelRun()
+	// The run's cancellation reaches the request from another goroutine
+	select {
+	case <-sent.Done():
+	case <-time.After(time.Second):
+		t.Errorf("request context is still live after the run ended")
+	}
+}
+
+func TestStallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stalled.bin":
+			// Part of the body arrives, then nothing until the client gives up
+			w.Write([]byte("partial"))
+			w.(http.Flusher).Flush()
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		case "/trickle.bin":
+			// Slow overall but never silent for long, so only the total time exceeds the stall timeout
+			for i := range 6 {
+				w.Write([]byte(strconv.Itoa(i)))
+				w.(http.Flusher).Flush()
+				time.Sleep(30 * time.Millisecond)
+			}
+		}
+	}))
+	defer server.Close()
+
+	t.Run("stalled body", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"embed.yaml": "output: assets\ngo-mod: main\ntimeout: 10s\nstall-timeout: 100ms\nfiles:\n  - " + server.URL + "/stalled.bin\n",
+		})
+		start := time.Now()
+		err := run(tmpDir, options{}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "stalled: no data received for 100ms") {
+			t.Errorf("run() error = %v, want the stall timeout to fire", err)
+		}
+		if elapsed := time.Since(start); elapsed > 3*time.Second {
+			t.Errorf("run() took %s, want it to give up after the stall timeout", elapsed)
+		}
+	})
+
+	t.Run("trickle", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: " + server.URL + "/trickle.bin\n    stall-timeout: 150ms\n",
+		})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "trickle.bin")); string(data) != "012345" {
+			t.Errorf("trickle.bin = %q, want 012345", data)
+		}
+	})
+
+	t.Run("negative", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "stall-timeout: -1s\nfiles:\n  - " + server.URL + "/trickle.bin\n"})
+		if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "invalid stall-timeout") {
+			t.Errorf("loadConfig() error = %v, want invalid stall-timeout", err)
+		}
+	})
+}
+
+func TestMaxSize(t *testing.T) {
+	chunk := bytes.Repeat([]byte("x"), 1024)
+	// 64MB is more than the socket buffers hold, so the server only gets through it if the client reads it all
+	const streamChunks = 64 * 1024
+	var streamed atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/stream.bin":
+			// Flushing before the body is complete forces chunked encoding without a Content-Length
+			for range streamChunks {
+				if _, err := w.Write(chunk); err != nil {
+					return
+				}
+				streamed.Add(int64(len(chunk)))
+				w.(http.Flusher).Flush()
+			}
+		case "/declared.bin":
+			w.Header().Set("Content-Length", "4096")
+			w.Write(bytes.Repeat(chunk, 4))
+		case "/small.bin":
+			w.Write(chunk)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		file    string
+		maxSize string
+		wantErr string
+	}{
+		{"chunked body over the limit", "stream.bin", "", "body exceeds max-size 2048"},
+		{"Content-Length over the limit", "declared.bin", "", "Content-Length 4096 exceeds max-size 2048"},
+		{"within the limit", "small.bin", "", ""},
+		{"per-file override", "declared.bin", "    max-size: 8192\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "output: assets\ngo-mod: main\nmax-size: 2048\nfiles:\n  - source: " + server.URL + "/" + tt.file + "\n" + tt.maxSize,
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("run() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			// Nothing of the aborted download is left behind
+			if entries, _ := os.ReadDir(tmpDir); len(entries) != 1 {
+				t.Errorf("failed run left files behind: %v", entries)
+			}
+		})
+	}
+
+	// The download was abandoned early instead of being read to the end
+	if n := streamed.Load(); n >= streamChunks*1024 {
+		t.Errorf("server streamed %d bytes, want the client to stop reading past max-size", n)
+	}
+}
+
+func TestExpectContentType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/config.json":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"ok":true}`))
+		case "/login/config.json":
+			// A misrouted URL answering 200 with a login page
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html>Sign in</html>"))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+		wantErr  string
+	}{
+		{"match ignoring charset", "/api/config.json", "application/json", ""},
+		{"case-insensitive", "/api/config.json", "Application/JSON", ""},
+		{"HTML instead of JSON", "/login/config.json", "application/json", `Content-Type "text/html; charset=utf-8" does not match expect-content-type "application/json"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: " + server.URL + tt.path + "\n    expect-content-type: " + tt.expected + "\n",
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("run() error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "assets", "config.json")); !os.IsNotExist(err) {
+				t.Errorf("the login page was written: %v", err)
+			}
+		})
+	}
+}
+
+func TestExpectContentTypeWithAPI(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "files:\n  - github: owner/repo@main:config.json\n    api: true\n    expect-content-type: application/json\n",
+	})
+	if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "expect-content-type is not supported with api") {
+		t.Errorf("loadConfig() error = %v, want the combination to be rejected", err)
+	}
+}
+
+func TestDownloadError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing.json":
+			http.NotFound(w, r)
+		case "/unavailable.json":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name     string
+		entry    string
+		url      string
+		status   int
+		attempts int
+	}{
+		{"status", "  - " + server.URL + "/missing.json\n", server.URL + "/missing.json", http.StatusNotFound, 1},
+		{"no response", "  - " + closed.URL + "/down.json\n", closed.URL + "/down.json", 0, 1},
+		{
+			"mirrors",
+			"  - source: " + server.URL + "/missing.json\n    mirrors:\n      - " + closed.URL + "/missing.json\n      - " + server.URL + "/unavailable.json\n",
+			server.URL + "/missing.json", http.StatusServiceUnavailable, 3,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n" + tt.entry})
+			err := run(tmpDir, options{}, io.Discard)
+			var dlErr *downloadError
+			if !errors.As(err, &dlErr) {
+				t.Fatalf("run() error = %v, want a *downloadError", err)
+			}
+			if dlErr.url != tt.url || dlErr.statusCode != tt.status || dlErr.attempts != tt.attempts {
+				t.Errorf("downloadError = {%s, %d, %d}, want {%s, %d, %d}", dlErr.url, dlErr.statusCode, dlErr.attempts, tt.url, tt.status, tt.attempts)
+			}
+		})
+	}
+
+	t.Run("attempts are inspectable", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n" + tests[2].entry})
+		err := run(tmpDir, options{}, io.Discard)
+		// The transport failure of the second attempt is still reachable through the aggregate
+		var urlErr *url.Error
+		if !errors.As(err, &urlErr) || urlErr.URL != closed.URL+"/missing.json" {
+			t.Errorf("run() error = %v, want the *url.Error of the unreachable mirror", err)
+		}
+	})
+}
+
+func TestTLSMinVersion(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	server.TLS = &tls.Config{MaxVersion: tls.VersionTLS12}
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	roots := x509.NewCertPool()
+	roots.AddCert(server.Certificate())
+
+	tests := []struct {
+		minVersion string
+		wantErr    bool
+	}{
+		{"", false},
+		{"1.2", false},
+		{"1.3", true},
+	}
+	for _, tt := range tests {
+		t.Run("min "+tt.minVersion, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			config := "go-mod: main\nfiles:\n  - a.txt\n"
+			if tt.minVersion != "" {
+				config = "tls-min-version: " + tt.minVersion + "\n" + config
+			}
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config})
+			cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+			if err != nil {
+				t.Fatalf("loadConfig() error: %v", err)
+			}
+			client := newHTTPClient(defaultMaxRedirects, nil, cfg.tlsMinVersion())
+			// Trust the test server's certificate, keeping the configured minimum version
+			transport, _ := client.Transport.(*http.Transport)
+			if transport == nil {
+				transport = http.DefaultTransport.(*http.Transport).Clone()
+				transport.TLSClientConfig = &tls.Config{}
+				client.Transport = transport
+			}
+			transport.TLSClientConfig.RootCAs = roots
+
+			data, err := fetchURL(client, server.URL, fetchOptions{})
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "protocol version") {
+					t.Errorf("fetchURL() = %q, %v, want the TLS 1.2 server to be refused", data, err)
+				}
+				return
+			}
+			if err != nil || string(data) != "secure" {
+				t.Errorf("fetchURL() = %q, %v, want %q", data, err, "secure")
+			}
+		})
+	}
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "tls-min-version: 1.4\nfiles:\n  - a.txt\n"})
+	if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "invalid tls-min-version") {
+		t.Errorf("loadConfig() error = %v, want an invalid tls-min-version", err)
+	}
+}
+
+func TestByteRange(t *testing.T) {
+	content := strings.Repeat("0123456789", 100)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ranged/data.bin":
+			http.ServeContent(w, r, "data.bin", time.Time{}, strings.NewReader(content))
+		case "/whole/data.bin":
+			// Ignores Range and answers with the full file
+			w.Write([]byte(content))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		path      string
+		byteRange string
+		expected  string
+		wantErr   string
+	}{
+		{"first bytes", "/ranged/data.bin", "bytes=0-3", "0123", ""},
+		{"open end", "/ranged/data.bin", "bytes=995-", "56789", ""},
+		{"suffix", "/ranged/data.bin", "bytes=-2", "89", ""},
+		{"range ignored", "/whole/data.bin", "bytes=0-3", "", "server ignored range bytes=0-3"},
+		{"not satisfiable", "/ranged/data.bin", "bytes=5000-", "", "416"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: " + server.URL + tt.path + "\n    range: " + tt.byteRange + "\n",
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("run() error = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "data.bin")); string(data) != tt.expected {
+				t.Errorf("data.bin = %q, want %q", data, tt.expected)
+			}
+		})
+	}
+}
+
+func TestByteRangeConfig(t *testing.T) {
+	tests := []struct {
+		entry   string
+		wantErr string
+	}{
+		{"source: https://example.com/a.bin\n    range: bytes=0-1,4-5", "invalid range"},
+		{"source: https://example.com/a.bin\n    range: bytes=9-1", "the last byte is before the first"},
+		{"source: local.bin\n    range: bytes=0-1", "range is only supported for remote files"},
+		{"github: owner/repo@main:a.bin\n    api: true\n    range: bytes=0-1", "range is not supported with api"},
+	}
+	for _, tt := range tests {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "files:\n  - " + tt.entry + "\n"})
+		if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("loadConfig(%q) error = %v, want %q", tt.entry, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,623 @@
+package main
+
+import (
+  "bytes"
+  "compress/gzip"
+  "encoding/base64"
+  "fmt"
+  "go/format"
+  "io"
+  "os"
+  "path"
+  "path/filepath"
+  "regexp"
+  "slices"
+  "sort"
+  "strconv"
+  "strings"
+  "unicode"
+)
+
+// generatedMarker is the standard line (https://go.dev/s/generatedcode) tools use to recognize generated files
+const generatedMarker = "// Code generated by remoteembed. DO NOT EDIT."
+
+// defaultBanner is the comment above the assets unless the config sets banner
+const defaultBanner = "// Embedded assets generated by remoteembed\n"
+
+// varBlockThreshold is the number of assets from which their variables are grouped in a single var block
+const varBlockThreshold = 5
+
+// generateEmbedGo renders the Go source file with an embed directive per asset.
+// The result is gofmt-formatted.
+func generateEmbedGo(pkgName string, assets []asset, cfg *EmbedConfig) (string, error) {
+  return renderEmbedGo(pkgName, assets, assets, cfg)
+}
+
+// generateEmbedGoFiles renders the Go files for assets: go-output alone, or with max-vars-per-file
+// the parts declaring at most that many variables each, in config order
+func generateEmbedGoFiles(pkgName string, assets []asset, cfg *EmbedConfig) ([]string, error) {
+  if cfg.MaxVarsPerFile == 0 {
+    src, err := generateEmbedGo(pkgName, assets, cfg)
+    return []string{src}, err
+  }
+  var files []string
+  for start := 0; start == 0 || start < len(assets); start += cfg.MaxVarsPerFile {
+    part := assets[start:min(start+cfg.MaxVarsPerFile, len(assets))]
+    // Declarations covering every asset go to the first part
+    var shared []asset
+    if start == 0 {
+      shared = assets
+    }
+    src, err := renderEmbedGo(pkgName, part, shared, cfg)
+    if err != nil {
+      return nil, err
+    }
+    files = append(files, src)
+  }
+  return files, nil
+}
+
+// renderEmbedGo renders a Go file declaring the variables of part. Declarations covering all assets,
+// such as the registry or the fs.FS accessor, are rendered for shared, which is nil in all but one file
+func renderEmbedGo(pkgName string, part, shared []asset, cfg *EmbedConfig) (string, error) {
+  // The body is rendered first so every feature can add the imports it needs
+  imports := importSet{}
+  var b strings.Builder
+  banner := docComment(cfg.Banner)
+  if banner == "" {
+    banner = defaultBanner
+  }
+  b.WriteString(banner + "\n")
+
+  if cfg.pathOnly() {
+    writePaths(&b, part)
+  } else if err := writeVars(&b, part, shared, cfg, imports); err != nil {
+    return "", err
+  }
+  if cfg.ModTimes {
+    imports.add("time")
+    writeModTimes(&b, part)
+  }
+  if cfg.Sizes {
+    writeSizes(&b, part)
+  } else if modules := slices.DeleteFunc(slices.Clone(part), func(a asset) bool { return !a.wasm }); len(modules) > 0 {
+    // The size of a module is always generated, e.g. for the progress of streaming compilation
+    writeSizes(&b, modules)
+  }
+  if cfg.GitHubCommits {
+    writeGitHubCommits(&b, part)
+  }
+  if shared != nil {
+    if cfg.Registry != "" {
+      writeRegistry(&b, cfg.Registry, shared)
+    }
+    if cfg.LookupFunc != "" {
+      writeLookupFunc(&b, cfg.LookupFunc, shared)
+    }
+    if cfg.SourceURLs != "" {
+      writeSourceURLs(&b, cfg.SourceURLs, shared, cfg.RedactSourceURLs)
+    }
+    if cfg.ValidateOnInit {
+      writeInitCheck(&b, shared, imports)
+    }
+    if cfg.Register != nil {
+      writeRegisterInit(&b, cfg.Register, shared, imports)
+    }
+    if cfg.FSFunc != "" {
+      imports.add("io/fs", "testing/fstest")
+      writeFSFunc(&b, cfg.FSFunc, shared)
+    }
+  }
+
+  src, err := format.Source([]byte(fmt.Sprintf("%s\n\npackage %s\n\n%s\n%s", generatedMarker, pkgName, imports.block(), b.String())))
+  if err != nil {
+    return "", fmt.Errorf("failed to format generated code: %v", err)
+  }
+  return string(src), nil
+}
+
+// writeVars declares a variable per asset of part, embedded with //go:embed or decoded from a literal, followed by the
+// declarations they depend on: type names and the literal decoder for shared, setters and decoding accessors for part
+func writeVars(b *strings.Builder, part, shared []asset, cfg *EmbedConfig, imports importSet) error {
+  // Many standalone //go:embed + var pairs read poorly, so past a threshold they share one var block
+  grouped := len(part) >= varBlockThreshold
+  keyword, sep := "var ", "\n"
+  if grouped {
+    keyword, sep = "", ""
+    b.WriteString("var (\n")
+  }
+  for i, a := range part {
+    doc := docComment(a.entry.Doc)
+    if grouped && doc != "" && i > 0 {
+      b.WriteString("\n")
+    }
+    if a.entry.Literal {
+      literal, err := compressLiteral(a.content)
+      if err != nil {
+        return fmt.Errorf("failed to compress %s: %v", a.expandedURL, err)
+      }
+      value := fmt.Sprintf("decodeAsset(%q)", literal)
+      if a.bytes {
+        value = "[]byte(" + value + ")"
+      }
+      if a.typeName != "" {
+        value = a.typeName + "(" + value + ")"
+      }
+      fmt.Fprintf(b, "%s%s%s = %s\n%s", doc, keyword, a.varName, value, sep)
+      continue
+    }
+    imports.add("embed")
+    if a.typeName == "" && !cfg.HotReload {
+      fmt.Fprintf(b, "%s//go:embed %s\n%s%s %s\n%s", doc, embedPattern(a.relEmbedPath), keyword, a.varName, a.goType(), sep)
+      continue
+    }
+    // go:embed only accepts string, []byte and embed.FS, so the typed variable converts an embedded one.
+    // With hot-reload the embedded content stays the default of a variable its setter replaces
+    raw := "embedded" + a.varName
+    value := raw
+    if a.typeName != "" {
+      value = a.typeName + "(" + raw + ")"
+    }
+    fmt.Fprintf(b, "//go:embed %s\n%s%s %s\n%s", embedPattern(a.relEmbedPath), keyword, raw, a.goType(), sep)
+    fmt.Fprintf(b, "%s%s%s = %s\n%s", doc, keyword, a.varName, value, sep)
+  }
+  if grouped {
+    b.WriteString(")\n\n")
+  }
+  if shared != nil {
+    if err := writeTypeNames(b, shared); err != nil {
+      return err
+    }
+  }
+  if slices.ContainsFunc(shared, func(a asset) bool { return a.entry.Literal }) {
+    imports.add("compress/gzip", "encoding/base64", "io", "strings")
+    b.WriteString(decodeAssetFunc)
+  }
+  if cfg.HotReload {
+    writeSetters(b, part)
+  }
+  for _, a := range part {
+    switch a.entry.EmbedEncoding {
+    case "hex":
+      imports.add("encoding/hex")
+      writeDecodeFunc(b, a, "hex.DecodeString")
+    case "base64":
+      imports.add("encoding/base64")
+      writeDecodeFunc(b, a, "base64.StdEncoding.DecodeString")
+    }
+  }
+  return nil
+}
+
+// writePaths declares a <Var>Path constant per asset of part with its path relative to the generated file, for mode: path-only
+func writePaths(b *strings.Builder, part []asset) {
+  b.WriteString("// Paths of the assets, relative to the directory of this file.\nconst (\n")
+  for _, a := range part {
+    fmt.Fprintf(b, "%s\t%sPath = %q\n", docComment(a.entry.Doc), a.varName, filepath.ToSlash(a.relEmbedPath))
+  }
+  b.WriteString(")\n\n")
+}
+
+// goOutputExt returns the extension of go-output, counting _test.go as a whole so that numbered parts stay test files
+func goOutputExt(goOutput string) string {
+  if strings.HasSuffix(goOutput, "_test.go") {
+    return "_test.go"
+  }
+  return filepath.Ext(goOutput)
+}
+
+// goOutputPart returns the path of the i-th file, counting from 1, that go-output is split into:
+// embed.go becomes embed_1.go and embed_test.go becomes embed_1_test.go
+func goOutputPart(goOutput string, i int) string {
+  ext := goOutputExt(goOutput)
+  return fmt.Sprintf("%s_%d%s", strings.TrimSuffix(goOutput, ext), i, ext)
+}
+
+// goOutputPartPattern matches the suffix goOutputPart adds to the name of go-output
+var goOutputPartPattern = regexp.MustCompile(`^_[0-9]+$`)
+
+// previousGoOutputs returns the existing files an earlier run generated for goOutput, whatever max-vars-per-file was then:
+// goOutput itself and its numbered parts. Files without the generated code marker are left out
+func previousGoOutputs(goOutput string) []string {
+  ext := goOutputExt(goOutput)
+  stem := strings.TrimSuffix(goOutput, ext)
+  candidates, _ := filepath.Glob(stem + "_*" + ext)
+  candidates = slices.DeleteFunc(candidates, func(p string) bool {
+    return !goOutputPartPattern.MatchString(strings.TrimSuffix(strings.TrimPrefix(p, stem), ext))
+  })
+  // embed_2.go sorts before embed_10.go
+  slices.SortFunc(candidates, func(a, b string) int {
+    if len(a) != len(b) {
+      return len(a) - len(b)
+    }
+    return strings.Compare(a, b)
+  })
+  var files []string
+  for _, p := range append([]string{goOutput}, candidates...) {
+    if isGeneratedFile(p) {
+      files = append(files, p)
+    }
+  }
+  return files
+}
+
+// isGeneratedFile reports whether the file at p starts with the generated code marker of remoteembed
+func isGeneratedFile(p string) bool {
+  f, err := os.Open(p)
+  if err != nil {
+    return false
+  }
+  defer f.Close()
+  head := make([]byte, len(generatedMarker))
+  _, err = io.ReadFull(f, head)
+  return err == nil && string(head) == generatedMarker
+}
+
+// importSet collects the packages the generated code refers to, mapped to the name they are imported as
+// when it is not the default
+type importSet map[string]string
+
+// add records paths as imported
+func (s importSet) add(paths ...string) {
+  for _, p := range paths {
+    if _, ok := s[p]; !ok {
+      s[p] = ""
+    }
+  }
+}
+
+// addNamed records path as imported under name, which is left out when it is the last element of path
+func (s importSet) addNamed(name, importPath string) {
+  if name == path.Base(importPath) {
+    name = ""
+  }
+  s[importPath] = name
+}
+
+// block renders the sorted import declaration. embed is imported for its side effect only,
+// since //go:embed into a string does not reference the package
+func (s importSet) block() string {
+  paths := make([]string, 0, len(s))
+  for p := range s {
+    paths = append(paths, p)
+  }
+  sort.Strings(paths)
+  if len(paths) == 0 {
+    return ""
+  }
+  var b strings.Builder
+  b.WriteString("import (\n")
+  for _, p := range paths {
+    if p == "embed" {
+      b.WriteString("\t_ \"embed\"\n")
+    } else if name := s[p]; name != "" {
+      fmt.Fprintf(&b, "\t%s %q\n", name, p)
+    } else {
+      fmt.Fprintf(&b, "\t%q\n", p)
+    }
+  }
+  b.WriteString(")\n")
+  return b.String()
+}
+
+// docComment turns doc text into // comment lines, one per line of text
+func docComment(doc string) string {
+  doc = strings.TrimSpace(doc)
+  if doc == "" {
+    return ""
+  }
+  var b strings.Builder
+  for _, line := range strings.Split(doc, "\n") {
+    line = strings.TrimRight(line, " \t\r")
+    if line == "" {
+      b.WriteString("//\n")
+      continue
+    }
+    b.WriteString("// " + line + "\n")
+  }
+  return b.String()
+}
+
+// writeDecodeFunc emits the <varName>Bytes accessor returning the original bytes of an encoded asset
+func writeDecodeFunc(b *strings.Builder, a asset, decode string) {
+  fmt.Fprintf(b, "// %sBytes returns the decoded content of %s.\n", a.varName, a.varName)
+  fmt.Fprintf(b, "func %sBytes() []byte {\n\tdata, err := %s(%s)\n", a.varName, decode, a.stringValue())
+  fmt.Fprintf(b, "\tif err != nil {\n\t\tpanic(\"remoteembed: corrupt %s: \" + err.Error())\n\t}\n\treturn data\n}\n\n", a.varName)
+}
+
+// writeSetters emits a Set<Var> function per asset replacing the content of its variable
+func writeSetters(b *strings.Builder, assets []asset) {
+  for _, a := range assets {
+    typ := a.goType()
+    if a.typeName != "" {
+      typ = a.typeName
+    }
+    fmt.Fprintf(b, "// Set%s replaces the content of %s, e.g. with a live file during development.\n", a.varName, a.varName)
+    fmt.Fprintf(b, "func Set%s(value %s) {\n\t%s = value\n}\n\n", a.varName, typ, a.varName)
+  }
+}
+
+// writeTypeNames emits the declaration of every type-name, in the order of their first asset.
+// All assets of a type need the same underlying type
+func writeTypeNames(b *strings.Builder, assets []asset) error {
+  underlying := map[string]asset{}
+  for _, a := range assets {
+    if a.typeName == "" {
+      continue
+    }
+    if first, ok := underlying[a.typeName]; ok {
+      if first.goType() != a.goType() {
+        return fmt.Errorf("type-name %s is used for %s (%s) and %s (%s): give them different type names", a.typeName, first.varName, first.goType(), a.varName, a.goType())
+      }
+      continue
+    }
+    underlying[a.typeName] = a
+    fmt.Fprintf(b, "// %s is the type of the embedded assets declared with it.\ntype %s %s\n\n", a.typeName, a.typeName, a.goType())
+  }
+  return nil
+}
+
+// writeModTimes emits a <Var>ModTime variable per asset; unknown times are the zero time.Time
+func writeModTimes(b *strings.Builder, assets []asset) {
+  b.WriteString("// Last modification times of the embedded assets, from the Last-Modified header or the local file.\n")
+  b.WriteString("// A zero time means the source did not report one.\nvar (\n")
+  for _, a := range assets {
+    if a.modTime.IsZero() {
+      fmt.Fprintf(b, "\t%sModTime = time.Time{}\n", a.varName)
+      continue
+    }
+    fmt.Fprintf(b, "\t%sModTime = time.Unix(%d, 0).UTC()\n", a.varName, a.modTime.Unix())
+  }
+  b.WriteString(")\n\n")
+}
+
+// writeSizes emits a <Var>Size constant per asset with the length of its embedded content
+func writeSizes(b *strings.Builder, assets []asset) {
+  b.WriteString("// Sizes in bytes of the embedded assets, after transcoding, normalization and embed-encoding.\nconst (\n")
+  for _, a := range assets {
+    fmt.Fprintf(b, "\t%sSize = %d\n", a.varName, a.size)
+  }
+  b.WriteString(")\n\n")
+}
+
+// writeGitHubCommits emits a <Var>Commit constant per asset downloaded from a GitHub repository
+func writeGitHubCommits(b *strings.Builder, assets []asset) {
+  if !slices.ContainsFunc(assets, func(a asset) bool { return a.commit != "" }) {
+    return
+  }
+  b.WriteString("// Commits that last changed the GitHub files of the embedded assets at the time they were downloaded.\nconst (\n")
+  for _, a := range assets {
+    if a.commit != "" {
+      fmt.Fprintf(b, "\t%sCommit = %q\n", a.varName, a.commit)
+    }
+  }
+  b.WriteString(")\n\n")
+}
+
+// sortedAssets returns a copy of assets ordered by key, so that declarations keyed by it do not depend on the
+// order of files and adding, removing or reordering files only touches their own lines
+func sortedAssets(assets []asset, key func(asset) string) []asset {
+  sorted := slices.Clone(assets)
+  slices.SortStableFunc(sorted, func(a, b asset) int {
+    return strings.Compare(key(a), key(b))
+  })
+  return sorted
+}
+
+// assetPath returns the unique path of a, the key of the registry, the lookup function and the fs.FS accessor
+func assetPath(a asset) string {
+  return a.uniquePath
+}
+
+// assetVarName returns the variable name of a, the key of the source URLs map
+func assetVarName(a asset) string {
+  return a.varName
+}
+
+// writeRegistry emits a slice of every embedded asset, named by its unique path, sorted by that path
+func writeRegistry(b *strings.Builder, name string, assets []asset) {
+  fmt.Fprintf(b, "// %s lists every embedded asset by its unique path.\n", name)
+  fmt.Fprintf(b, "var %s = []struct {\n\tName string\n\tData string\n}{\n", name)
+  for _, a := range sortedAssets(assets, assetPath) {
+    data := a.stringValue()
+    fmt.Fprintf(b, "\t{%q, %s},\n", a.uniquePath, data)
+  }
+  b.WriteString("}\n\n")
+}
+
+// writeLookupFunc emits a function switching over the unique paths of the assets, sorted by path, so that a fixed
+// set of names is looked up without building a map
+func writeLookupFunc(b *strings.Builder, name string, assets []asset) {
+  fmt.Fprintf(b, "// %s returns the embedded asset with the given unique path, and whether there is one.\n", name)
+  fmt.Fprintf(b, "func %s(name string) (string, bool) {\n\tswitch name {\n", name)
+  for _, a := range sortedAssets(assets, assetPath) {
+    fmt.Fprintf(b, "\tcase %q:\n\t\treturn %s, true\n", a.uniquePath, a.stringValue())
+  }
+  b.WriteString("\t}\n\treturn \"\", false\n}\n\n")
+}
+
+// writeRegisterInit emits an init function passing every asset, named by its unique path, to the hook in config order.
+// It follows the startup check, so that only validated assets are registered
+func writeRegisterInit(b *strings.Builder, hook *RegisterHook, assets []asset, imports importSet) {
+  if qualifier, _, ok := strings.Cut(hook.Func, "."); ok {
+    imports.addNamed(qualifier, hook.Import)
+  }
+  fmt.Fprintf(b, "// init registers every embedded asset with %s by its unique path.\n", hook.Func)
+  b.WriteString("func init() {\n")
+  for _, a := range assets {
+    data := a.stringValue()
+    fmt.Fprintf(b, "\t%s(%q, %s)\n", hook.Func, a.uniquePath, data)
+  }
+  b.WriteString("}\n\n")
+}
+
+// writeSourceURLs emits a map from every variable name to the expanded source of its asset, sorted by
+// variable name, with credentials hidden when redact is set
+func writeSourceURLs(b *strings.Builder, name string, assets []asset, redact bool) {
+  fmt.Fprintf(b, "// %s maps the variable name of every embedded asset to the source it was generated from.\n", name)
+  fmt.Fprintf(b, "var %s = map[string]string{\n", name)
+  for _, a := range sortedAssets(assets, assetVarName) {
+    source := a.expandedURL
+    if redact {
+      source = redactURL(source)
+    }
+    fmt.Fprintf(b, "\t%q: %q,\n", a.varName, source)
+  }
+  b.WriteString("}\n\n")
+}
+
+// writeInitCheck emits an init function that panics when an asset is empty or does not parse as its format.
+// Encoded assets are checked after decoding
+func writeInitCheck(b *strings.Builder, assets []asset, imports importSet) {
+  b.WriteString("// init checks the embedded assets, so that a broken build fails at startup instead of on first use.\n")
+  b.WriteString("func init() {\n")
+  hasXML := false
+  for _, a := range assets {
+    data := fmt.Sprintf("[]byte(%s)", a.varName)
+    if a.entry.EmbedEncoding == "hex" || a.entry.EmbedEncoding == "base64" {
+      data = a.varName + "Bytes()"
+    }
+    fmt.Fprintf(b, "\tif len(%s) == 0 {\n\t\tpanic(\"remoteembed: %s is empty\")\n\t}\n", a.varName, a.varName)
+    var parse, format string
+    switch a.entry.Format {
+    case "json":
+      imports.add("encoding/json")
+      parse, format = fmt.Sprintf("json.Unmarshal(%s, new(any))", data), "JSON"
+    case "xml":
+      hasXML = true
+      imports.add("bytes", "encoding/xml", "io")
+      parse, format = fmt.Sprintf("checkXMLAsset(%s)", data), "XML"
+    case "yaml":
+      imports.add(yamlModule)
+      parse, format = fmt.Sprintf("yaml.Unmarshal(%s, new(any))", data), "YAML"
+    default:
+      continue
+    }
+    fmt.Fprintf(b, "\tif err := %s; err != nil {\n\t\tpanic(\"remoteembed: %s is not valid %s: \" + err.Error())\n\t}\n", parse, a.varName, format)
+  }
+  b.WriteString("}\n\n")
+  if hasXML {
+    b.WriteString(checkXMLAssetFunc)
+  }
+}
+
+// yamlModule is the module the validate-on-init check of YAML files imports
+const yamlModule = "gopkg.in/yaml.v3"
+
+// checkInitCheckModule fails when validate-on-init would import yamlModule into a module whose go.mod does not
+// require it, since the generated file would not build. Without a go.mod above go-output there is nothing to check
+func checkInitCheckModule(baseDir string, cfg *EmbedConfig, assets []asset) error {
+  if !cfg.ValidateOnInit || !slices.ContainsFunc(assets, func(a asset) bool { return a.entry.Format == "yaml" }) {
+    return nil
+  }
+  for dir := filepath.Dir(filepath.Join(baseDir, cfg.GoOutput)); ; dir = filepath.Dir(dir) {
+    gomodPath := filepath.Join(dir, "go.mod")
+    data, err := os.ReadFile(gomodPath)
+    if err == nil {
+      if !requiresModule(string(data), yamlModule) {
+        return fmt.Errorf("validate-on-init checks YAML files with %s, which %s does not require: run go get %s", yamlModule, gomodPath, yamlModule)
+      }
+      return nil
+    }
+    if filepath.Dir(dir) == dir {
+      return nil
+    }
+  }
+}
+
+// requiresModule reports whether the go.mod content gomod requires module, in a single require line or a block
+func requiresModule(gomod, module string) bool {
+  inBlock := false
+  for _, l := range strings.Split(gomod, "\n") {
+    fields := strings.Fields(l)
+    switch {
+    case len(fields) == 0:
+    case inBlock && fields[0] == ")":
+      inBlock = false
+    case inBlock:
+      if fields[0] == module {
+        return true
+      }
+    case fields[0] == "require" && len(fields) > 1 && fields[1] == "(":
+      inBlock = true
+    case fields[0] == "require" && len(fields) > 1 && fields[1] == module:
+      return true
+    }
+  }
+  return false
+}
+
+// checkXMLAssetFunc is emitted once when any asset is checked as XML; the decoder rejects malformed documents
+const checkXMLAssetFunc = `// checkXMLAsset returns the first syntax error of the XML document data.
+func checkXMLAsset(data []byte) error {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	for {
+		if _, err := d.Token(); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+	}
+}
+
+`
+
+// writeFSFunc emits a function returning the embedded strings as an fs.FS keyed by their unique paths, in sorted order
+func writeFSFunc(b *strings.Builder, name string, assets []asset) {
+  fmt.Fprintf(b, "// %s returns the embedded assets as an fs.FS keyed by their unique paths.\n", name)
+  fmt.Fprintf(b, "func %s() fs.FS {\n\treturn fstest.MapFS{\n", name)
+  for _, a := range sortedAssets(assets, assetPath) {
+    fmt.Fprintf(b, "\t\t%q: {Data: []byte(%s), Mode: 0444},\n", a.uniquePath, a.varName)
+  }
+  b.WriteString("\t}\n}\n")
+}
+
+// embedPattern returns path as a go:embed argument, quoting it when it contains spaces
+func embedPattern(path string) string {
+  if strings.IndexFunc(path, unicode.IsSpace) >= 0 {
+    return strconv.Quote(path)
+  }
+  return path
+}
+
+// decodeAssetFunc is emitted once when any asset is a literal; it runs during package initialization
+const decodeAssetFunc = `// decodeAsset expands a base64-encoded gzip literal generated by remoteembed.
+func decodeAsset(s string) string {
+	zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(s)))
+	if err != nil {
+		panic("remoteembed: corrupt literal: " + err.Error())
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		panic("remoteembed: corrupt literal: " + err.Error())
+	}
+	return string(data)
+}
+
+`
+
+// compressLiteral gzips data and returns it base64-encoded, ready to be placed in a string literal.
+// The gzip header carries no name or timestamp, so the output is reproducible.
+func compressLiteral(data []byte) (string, error) {
+  var buf bytes.Buffer
+  zw, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+  if err != nil {
+    return "", err
+  }
+  if _, err := zw.Write(data); err != nil {
+    return "", err
+  }
+  if err := zw.Close(); err != nil {
+    return "", err
+  }
+  return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// decompressLiteral reverses compressLiteral
+func decompressLiteral(s string) ([]byte, error) {
+  zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, strings.NewReader(s)))
+  if err != nil {
+    return nil, err
+  }
+  return io.ReadAll(zr)
+}
@@ -0,0 +1,1222 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGenerateEmbedGoDoc(t *testing.T) {
+	assets := []asset{
+		{
+			fileInfo:     fileInfo{entry: &FileEntry{Doc: "Config is the embedded app configuration.\n\nIt is fetched from the config service.  \n"}},
+			relEmbedPath: "assets/config.xml",
+			varName:      "Config",
+		},
+		{
+			fileInfo:     fileInfo{entry: &FileEntry{}},
+			relEmbedPath: "assets/users.json",
+			varName:      "Users",
+		},
+	}
+
+	result, err := generateEmbedGo("main", assets, &EmbedConfig{})
+	if err != nil {
+		t.Fatalf("generateEmbedGo() error: %v", err)
+	}
+
+	expected := "// Config is the embedded app configuration.\n" +
+		"//\n" +
+		"// It is fetched from the config service.\n" +
+		"//\n" +
+		"//go:embed assets/config.xml\n" +
+		"var Config string\n"
+	if !strings.Contains(result, expected) {
+		t.Errorf("generated code missing doc comment:\n%s", result)
+	}
+	if !strings.Contains(result, "\n\n//go:embed assets/users.json\nvar Users string\n") {
+		t.Errorf("undocumented var should have no comment:\n%s", result)
+	}
+
+	formatted, err := format.Source([]byte(result))
+	if err != nil {
+		t.Fatalf("generated code does not parse: %v", err)
+	}
+	if string(formatted) != result {
+		t.Errorf("generated code is not gofmt-clean:\n%s", result)
+	}
+}
+
+// runGenerated builds and runs the Go program in dir (which must contain the generated
+// file and a main.go) and returns its output. It is skipped when no go toolchain is available.
+func runGenerated(t *testing.T, dir string) string {
+	t.Helper()
+	out, err := goRun(t, dir)
+	if err != nil {
+		t.Fatalf("go run failed: %v\n%s", err, out)
+	}
+	return out
+}
+
+// goRun runs the program in dir and returns its combined output, for programs expected to fail
+func goRun(t *testing.T, dir string) (string, error) {
+	t.Helper()
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "go.mod")); os.IsNotExist(err) {
+		writeTestFiles(t, dir, map[string]string{"go.mod": "module example.com/generated\n\ngo 1.24\n"})
+	}
+	cmd := exec.Command(goBin, "run", ".")
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func TestGeneratedFSFunc(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/config.xml":          "<config/>",
+		"src/mapping/users.json":  "mapping",
+		"src/settings/users.json": "settings",
+		"embed.yaml": `output: assets
+go-mod: main
+fs-func: Assets
+files:
+  - src/config.xml
+  - src/mapping/users.json
+  - src/settings/users.json
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"io/fs"
+)
+
+func main() {
+	for _, name := range []string{"config.xml", "mapping/users.json", "settings/users.json"} {
+		data, err := fs.ReadFile(Assets(), name)
+		fmt.Printf("%s=%s err=%v\n", name, data, err)
+	}
+	_, err := fs.ReadFile(Assets(), "missing.txt")
+	fmt.Println("missing:", err != nil)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	out := runGenerated(t, tmpDir)
+	expected := "config.xml=<config/> err=<nil>\n" +
+		"mapping/users.json=mapping err=<nil>\n" +
+		"settings/users.json=settings err=<nil>\n" +
+		"missing: true\n"
+	if out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedEmbedPathsWithSpecialCharacters(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/my file.txt":    "spaced",
+		"src/what?*:.json":   "sanitized",
+		"src/[draft] v2.sql": "bracketed",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/my file.txt
+  - src/what?*:.json
+  - src/[draft] v2.sql
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(MyFile, What, DraftV2)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	for _, want := range []string{
+		"//go:embed \"assets/my file.txt\"\nvar MyFile string",
+		"//go:embed assets/what___.json\nvar What string",
+		"//go:embed \"assets/_draft_ v2.sql\"\nvar DraftV2 string",
+	} {
+		if !strings.Contains(string(embedGo), want) {
+			t.Errorf("embed.go missing %q:\n%s", want, embedGo)
+		}
+	}
+
+	if out := runGenerated(t, tmpDir); out != "spaced sanitized bracketed\n" {
+		t.Errorf("program output = %q, want %q", out, "spaced sanitized bracketed\n")
+	}
+}
+
+func TestEmbedPattern(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"assets/config.xml", "assets/config.xml"},
+		{"assets/my file.txt", `"assets/my file.txt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := embedPattern(tt.input); result != tt.expected {
+				t.Errorf("embedPattern(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGeneratedRegistry(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json":          "users",
+		"src/mapping/items.json":  "mapping",
+		"src/settings/items.json": "settings",
+		"embed.yaml": `output: assets
+go-mod: main
+registry: AllAssets
+files:
+  - src/users.json
+  - src/mapping/items.json
+  - src/settings/items.json
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	for _, a := range AllAssets {
+		fmt.Printf("%s=%s\n", a.Name, a.Data)
+	}
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	// Entries are sorted by path, not in the order of files
+	expected := "mapping/items.json=mapping\nsettings/items.json=settings\nusers.json=users\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedLookupFunc(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json":         "users",
+		"src/mapping/items.json": "mapping",
+		"src/logo.png":           "\x89PNG",
+		"embed.yaml": `output: assets
+go-mod: main
+lookup-func: Asset
+files:
+  - src/users.json
+  - src/mapping/items.json
+  - source: src/logo.png
+    var-type: bytes
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	for _, name := range []string{"users.json", "mapping/items.json", "logo.png", "items.json", ""} {
+		data, ok := Asset(name)
+		fmt.Printf("%q %q %v\n", name, data, ok)
+	}
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	// Without a collision the unique path of a file is its name alone
+	expected := `"users.json" "users" true
+"mapping/items.json" "" false
+"logo.png" "\x89PNG" true
+"items.json" "mapping" true
+"" "" false
+`
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedPathOnly(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json":      "users",
+		"src/config/app.yaml": "app",
+		"embed.yaml": `output: assets
+go-mod: main
+mode: path-only
+sizes: true
+files:
+  - src/users.json
+  - source: src/config/app.yaml
+    doc: Settings loaded at startup.
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	for _, path := range []string{UsersPath, AppPath} {
+		data, err := os.ReadFile(path)
+		fmt.Println(path, string(data), err)
+	}
+	fmt.Println(UsersSize)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated := string(data)
+	for _, unwanted := range []string{"//go:embed", `"embed"`, "var "} {
+		if strings.Contains(generated, unwanted) {
+			t.Errorf("embed.go contains %q:\n%s", unwanted, generated)
+		}
+	}
+	if !strings.Contains(generated, "\t// Settings loaded at startup.\n\tAppPath = \"assets/app.yaml\"\n") {
+		t.Errorf("embed.go =\n%s\nwant a documented AppPath constant", generated)
+	}
+	// The files are still written, to be read at runtime
+	expected := "assets/users.json users <nil>\nassets/app.yaml app <nil>\n5\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestPathOnlyConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  string
+		wantErr string
+	}{
+		{"unknown mode", "mode: paths\nfiles: [a.json]\n", `invalid mode "paths": must be embed or path-only`},
+		{"registry", "mode: path-only\nregistry: All\nfiles: [a.json]\n", "registry cannot be used with mode: path-only"},
+		{"hot-reload", "mode: path-only\nhot-reload: true\nfiles: [a.json]\n", "hot-reload cannot be used with mode: path-only"},
+		{"literal", "mode: path-only\nfiles:\n  - source: a.json\n    literal: true\n", "files[0]: literal cannot be used with mode: path-only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\n" + tt.config})
+			_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeneratedMapsOrderIndependent(t *testing.T) {
+	generate := func(files ...string) string {
+		t.Helper()
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"src/users.json":          "users",
+			"src/mapping/items.json":  "mapping",
+			"src/settings/items.json": "settings",
+			"src/config.xml":          "<config/>",
+			"embed.yaml":              "output: assets\ngo-mod: main\nregistry: AllAssets\nlookup-func: Asset\nsource-urls: SourceURLs\nfs-func: Assets\nfiles:\n  - " + strings.Join(files, "\n  - ") + "\n",
+		})
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The variables themselves follow the order of files; everything keyed by path or name comes after them
+		_, maps, ok := strings.Cut(string(data), "// AllAssets lists")
+		if !ok {
+			t.Fatalf("embed.go has no registry:\n%s", data)
+		}
+		return maps
+	}
+
+	want := generate("src/config.xml", "src/mapping/items.json", "src/settings/items.json", "src/users.json")
+	if got := generate("src/users.json", "src/settings/items.json", "src/config.xml", "src/mapping/items.json"); got != want {
+		t.Errorf("maps after reordering files =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestGeneratedRegisterHook(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json":         "users",
+		"src/mapping/items.json": "mapping",
+		"embed.yaml": `output: assets
+go-mod: main
+register:
+  import: example.com/generated/internal/catalogpkg
+  func: catalog.Register
+files:
+  - src/users.json
+  - source: src/mapping/items.json
+    literal: true
+`,
+		"internal/catalogpkg/catalog.go": `package catalog
+
+var Names []string
+var Data = map[string]string{}
+
+func Register(name, data string) {
+	Names = append(Names, name)
+	Data[name] = data
+}
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	catalog "example.com/generated/internal/catalogpkg"
+)
+
+func main() {
+	for _, name := range catalog.Names {
+		fmt.Printf("%s=%s\n", name, catalog.Data[name])
+	}
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	src, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`catalog "example.com/generated/internal/catalogpkg"`,
+		"func init() {\n\tcatalog.Register(\"users.json\", Users)\n\tcatalog.Register(\"items.json\", Items)\n}",
+	} {
+		if !strings.Contains(string(src), want) {
+			t.Errorf("generated code is missing %s:\n%s", want, src)
+		}
+	}
+	expected := "users.json=users\nitems.json=mapping\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedMaxVarsPerFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	config := `output: assets
+go-mod: main
+registry: AllAssets
+files:
+  - src/a.txt
+  - src/b.txt
+  - source: src/c.txt
+    literal: true
+`
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt":  "a",
+		"src/b.txt":  "b",
+		"src/c.txt":  "c",
+		"embed.yaml": config,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	for _, a := range AllAssets {
+		fmt.Printf("%s=%s\n", a.Name, a.Data)
+	}
+}
+`,
+	})
+	// A go-output from before the split would redeclare every variable
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "max-vars-per-file: 2\n" + config})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "embed.go")); !os.IsNotExist(err) {
+		t.Errorf("embed.go was not removed after splitting: %v", err)
+	}
+	first, _ := os.ReadFile(filepath.Join(tmpDir, "embed_1.go"))
+	second, _ := os.ReadFile(filepath.Join(tmpDir, "embed_2.go"))
+	for _, want := range []string{"//go:embed assets/a.txt\nvar A string", "//go:embed assets/b.txt\nvar B string", "var AllAssets", "func decodeAsset("} {
+		if !strings.Contains(string(first), want) {
+			t.Errorf("embed_1.go is missing %s:\n%s", want, first)
+		}
+	}
+	if !strings.Contains(string(second), `var C = decodeAsset("`) || strings.Contains(string(second), "AllAssets") {
+		t.Errorf("embed_2.go should hold only C:\n%s", second)
+	}
+	expected := "a.txt=a\nb.txt=b\nc.txt=c\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+
+	// Fewer parts leave no stale part behind
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "max-vars-per-file: 3\n" + config})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "embed_2.go")); !os.IsNotExist(err) {
+		t.Errorf("embed_2.go was not removed: %v", err)
+	}
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGoOutputParts(t *testing.T) {
+	cfg := &EmbedConfig{GoOutput: "gen/assets.go", MaxVarsPerFile: 2}
+	got := cfg.goOutputFiles("/mod", 5)
+	want := []string{"/mod/gen/assets_1.go", "/mod/gen/assets_2.go", "/mod/gen/assets_3.go"}
+	if !slices.Equal(got, want) {
+		t.Errorf("goOutputFiles() = %v, want %v", got, want)
+	}
+	if !cfg.isGenerated("/mod", "/mod/gen/assets_12.go") || cfg.isGenerated("/mod", "/mod/gen/assets_x.go") {
+		t.Errorf("isGenerated() does not recognize the numbered parts of go-output only")
+	}
+}
+
+func TestGeneratedLiteral(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/data.txt":  "line one\r\nline two",
+		"src/plain.txt": "plain",
+		"embed.yaml": `output: assets
+go-mod: main
+registry: AllAssets
+files:
+  - source: src/data.txt
+    line-endings: lf
+    literal: true
+  - src/plain.txt
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	for _, a := range AllAssets {
+		fmt.Printf("%s=%q\n", a.Name, a.Data)
+	}
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets", "data.txt")); !os.IsNotExist(err) {
+		t.Errorf("literal asset was written to the output dir (stat error: %v)", err)
+	}
+	embedGo, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(embedGo), "var Data = decodeAsset(") {
+		t.Errorf("embed.go does not declare a literal:\n%s", embedGo)
+	}
+
+	expected := "data.txt=\"line one\\nline two\"\nplain.txt=\"plain\"\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedVarBlock(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a",
+		"src/b.txt": "b",
+		"src/c.txt": "c",
+		"src/d.txt": "d",
+		"src/e.txt": "e",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - source: src/b.txt
+    doc: B is documented.
+  - src/c.txt
+  - source: src/d.txt
+    literal: true
+  - src/e.txt
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(A, B, C, D, E)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	embedGo, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "var (\n" +
+		"\t//go:embed assets/a.txt\n" +
+		"\tA string\n" +
+		"\n" +
+		"\t// B is documented.\n" +
+		"\t//go:embed assets/b.txt\n" +
+		"\tB string\n" +
+		"\t//go:embed assets/c.txt\n" +
+		"\tC string\n"
+	if !strings.Contains(string(embedGo), expected) {
+		t.Errorf("embed.go does not group the vars:\n%s", embedGo)
+	}
+
+	if out := runGenerated(t, tmpDir); out != "a b c d e\n" {
+		t.Errorf("program output = %q, want %q", out, "a b c d e\n")
+	}
+}
+
+func TestGenerateEmbedGoImports(t *testing.T) {
+	embedded := asset{fileInfo: fileInfo{entry: &FileEntry{}}, relEmbedPath: "a.txt", varName: "A"}
+	literal := asset{fileInfo: fileInfo{entry: &FileEntry{Literal: true}}, varName: "B", content: []byte("b")}
+
+	tests := []struct {
+		name     string
+		assets   []asset
+		cfg      *EmbedConfig
+		expected []string
+	}{
+		{"embed only", []asset{embedded}, &EmbedConfig{}, []string{`_ "embed"`}},
+		{"registry needs nothing extra", []asset{embedded}, &EmbedConfig{Registry: "All"}, []string{`_ "embed"`}},
+		{"fs func", []asset{embedded}, &EmbedConfig{FSFunc: "Assets"}, []string{`_ "embed"`, `"io/fs"`, `"testing/fstest"`}},
+		{"literal only", []asset{literal}, &EmbedConfig{}, []string{`"compress/gzip"`, `"encoding/base64"`, `"io"`, `"strings"`}},
+		{"everything", []asset{embedded, literal}, &EmbedConfig{FSFunc: "Assets"}, []string{
+			`"compress/gzip"`, `_ "embed"`, `"encoding/base64"`, `"io"`, `"io/fs"`, `"strings"`, `"testing/fstest"`,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := generateEmbedGo("main", tt.assets, tt.cfg)
+			if err != nil {
+				t.Fatalf("generateEmbedGo() error: %v", err)
+			}
+			start := strings.Index(result, "import (\n")
+			end := strings.Index(result, "\n)\n")
+			if start < 0 || end < start {
+				t.Fatalf("no import block in:\n%s", result)
+			}
+			var imports []string
+			for _, line := range strings.Split(result[start+len("import (\n"):end], "\n") {
+				imports = append(imports, strings.TrimSpace(line))
+			}
+			if strings.Join(imports, "\n") != strings.Join(tt.expected, "\n") {
+				t.Errorf("imports = %q, want %q", imports, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGeneratedEncodedAccessors(t *testing.T) {
+	binary := "\x89PNG\r\n\x1a\n\x00\xff\xfe binary"
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/logo.png": binary,
+		"src/key.bin":  binary,
+		"src/raw.bin":  binary,
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/logo.png
+    embed-encoding: base64
+  - source: src/key.bin
+    embed-encoding: hex
+  - source: src/raw.bin
+    embed-encoding: raw
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%q\n%q\n%q\n", LogoBytes(), KeyBytes(), Raw)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	// The written files hold the encoded text
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "logo.png")); string(data) != "iVBORw0KGgoA//4gYmluYXJ5" {
+		t.Errorf("logo.png = %q, want base64 text", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "key.bin")); string(data) != "89504e470d0a1a0a00fffe2062696e617279" {
+		t.Errorf("key.bin = %q, want lowercase hex", data)
+	}
+
+	quoted := fmt.Sprintf("%q\n", binary)
+	if out := runGenerated(t, tmpDir); out != quoted+quoted+quoted {
+		t.Errorf("program output =\n%s\nwant the original bytes three times", out)
+	}
+}
+
+func TestGenerateEmbedGoBanner(t *testing.T) {
+	assets := []asset{{fileInfo: fileInfo{entry: &FileEntry{}}, relEmbedPath: "a.txt", varName: "A"}}
+
+	result, err := generateEmbedGo("main", assets, &EmbedConfig{})
+	if err != nil {
+		t.Fatalf("generateEmbedGo() error: %v", err)
+	}
+	if !strings.HasPrefix(result, "// Code generated by remoteembed. DO NOT EDIT.\n\npackage main\n") {
+		t.Errorf("generated code does not start with the generated marker:\n%s", result)
+	}
+	if !strings.Contains(result, "\n// Embedded assets generated by remoteembed\n") {
+		t.Errorf("default banner missing:\n%s", result)
+	}
+
+	t.Setenv("TEAM", "platform")
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "banner: |\n  Assets owned by ${TEAM}.\n  run: go generate ./...\nfiles: [a.txt]\n",
+	})
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	result, err = generateEmbedGo("main", assets, cfg)
+	if err != nil {
+		t.Fatalf("generateEmbedGo() error: %v", err)
+	}
+	if !strings.HasPrefix(result, "// Code generated by remoteembed. DO NOT EDIT.\n") {
+		t.Errorf("custom banner must keep the generated marker:\n%s", result)
+	}
+	if !strings.Contains(result, "\n// Assets owned by platform.\n// run: go generate ./...\n\n//go:embed a.txt\n") {
+		t.Errorf("custom banner missing:\n%s", result)
+	}
+	if strings.Contains(result, "Embedded assets generated by remoteembed") {
+		t.Errorf("custom banner should replace the default one:\n%s", result)
+	}
+}
+
+func TestGeneratedModTimes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dated.json" {
+			w.Header().Set("Last-Modified", "Tue, 15 Nov 1994 08:12:31 GMT")
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/local.txt": "local",
+		"embed.yaml": `output: assets
+go-mod: main
+mod-times: true
+files:
+  - ` + server.URL + `/dated.json
+  - ` + server.URL + `/undated.json
+  - src/local.txt
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+	"time"
+)
+
+func main() {
+	fmt.Println(DatedModTime.Format(time.RFC3339))
+	fmt.Println(UndatedModTime.IsZero())
+	fmt.Println(LocalModTime.Format(time.RFC3339))
+}
+`,
+	})
+	localTime := time.Date(2024, 2, 29, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(tmpDir, "src", "local.txt"), localTime, localTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	expected := "1994-11-15T08:12:31Z\ntrue\n2024-02-29T12:00:00Z\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedSizes(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/crlf.txt":  "a\r\nb\r\n",
+		"src/blob.bin":  "\x00\x01\x02",
+		"src/small.txt": "literal",
+		"embed.yaml": `output: assets
+go-mod: main
+sizes: true
+files:
+  - source: src/crlf.txt
+    line-endings: lf
+  - source: src/blob.bin
+    embed-encoding: hex
+  - source: src/small.txt
+    literal: true
+`,
+		"main.go": `package main
+
+import "fmt"
+
+// Sizes are constants, usable in array lengths
+var buf [CrlfSize]byte
+
+func main() {
+	fmt.Println(len(buf) == len(Crlf), BlobSize == len(Blob), SmallSize == len(Small))
+	fmt.Println(CrlfSize, BlobSize, SmallSize)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	// Sizes count the bytes after normalization and hex encoding
+	expected := "true true true\n4 6 7\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestGeneratedSourceURLs(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<config/>"))
+	}))
+	defer server.Close()
+	t.Setenv("API_TOKEN", "s3cret")
+
+	tests := []struct {
+		name     string
+		redact   bool
+		expected string
+	}{
+		{"full", false, server.URL + "/config.xml?token=s3cret\nsrc/local.txt\n"},
+		{"redacted", true, server.URL + "/config.xml?token=xxxxx\nsrc/local.txt\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"src/local.txt": "local",
+				"embed.yaml": fmt.Sprintf(`output: assets
+go-mod: main
+source-urls: SourceURLs
+redact-source-urls: %v
+files:
+  - %s/config.xml?token=$API_TOKEN
+  - src/local.txt
+`, tt.redact, server.URL),
+				"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(SourceURLs["Config"])
+	fmt.Println(SourceURLs["Local"])
+}
+`,
+			})
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if out := runGenerated(t, tmpDir); out != tt.expected {
+				t.Errorf("program output =\n%s\nwant\n%s", out, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGeneratedValidateOnInit(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{"valid", map[string]string{"src/config.json": `{"a":1}`, "src/feed.xml": "<feed><item/></feed>", "src/notes.txt": "notes"}, ""},
+		{"broken JSON", map[string]string{"src/config.json": `{"a":`, "src/feed.xml": "<feed/>", "src/notes.txt": "notes"}, "remoteembed: Config is not valid JSON"},
+		{"broken XML", map[string]string{"src/config.json": `{}`, "src/feed.xml": "<feed><item></feed>", "src/notes.txt": "notes"}, "remoteembed: Feed is not valid XML"},
+		{"empty", map[string]string{"src/config.json": `{}`, "src/feed.xml": "<feed/>", "src/notes.txt": ""}, "remoteembed: Notes is empty"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			tt.files["embed.yaml"] = `output: assets
+go-mod: main
+validate-on-init: true
+files:
+  - source: src/config.json
+    format: json
+  - source: src/feed.xml
+    format: xml
+    embed-encoding: base64
+  - src/notes.txt
+`
+			tt.files["main.go"] = "package main\n\nfunc main() { println(\"started\") }\n"
+			writeTestFiles(t, tmpDir, tt.files)
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			out, err := goRun(t, tmpDir)
+			if tt.wantErr == "" {
+				if err != nil || !strings.Contains(out, "started") {
+					t.Fatalf("go run = %v\n%s", err, out)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(out, tt.wantErr) || strings.Contains(out, "started") {
+				t.Errorf("go run = %v\n%s\nwant a panic at startup with %q", err, out, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestGeneratedValidateOnInitYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings string
+		wantErr  string
+	}{
+		{"valid", "a: 1\n", ""},
+		{"broken", "a: [1\n", "remoteembed: Settings is not valid YAML"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"src/settings.yaml": tt.settings,
+				"embed.yaml": `output: assets
+go-mod: main
+validate-on-init: true
+files:
+  - source: src/settings.yaml
+    format: yaml
+`,
+				"main.go": "package main\n\nfunc main() { println(\"started\") }\n",
+				// The module of the generated code needs yaml.v3, resolved from the module cache of this one
+				"go.mod": "module example.com/generated\n\ngo 1.24\n\nrequire gopkg.in/yaml.v3 v3.0.1\n",
+				"go.sum": "gopkg.in/yaml.v3 v3.0.1 h1:fxVm/GzAzEWqLHuvctI91KS9hhNmmWOoWu0XTYJS7CA=\n" +
+					"gopkg.in/yaml.v3 v3.0.1/go.mod h1:K4uyk7z7BCEPqu6E+C64Yfv1cQ7kz7rIZviUmN+EgEM=\n",
+			})
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			out, err := goRun(t, tmpDir)
+			if tt.wantErr == "" {
+				if err != nil || !strings.Contains(out, "started") {
+					t.Fatalf("go run = %v\n%s", err, out)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(out, tt.wantErr) || strings.Contains(out, "started") {
+				t.Errorf("go run = %v\n%s\nwant a panic at startup with %q", err, out, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateOnInitYAMLRequiresModule(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/settings.yaml": "a: 1\n",
+		"embed.yaml": `output: assets
+go-mod: main
+validate-on-init: true
+files:
+  - source: src/settings.yaml
+    format: yaml
+`,
+		"go.mod": "module example.com/generated\n\ngo 1.24\n\nrequire (\n\tgolang.org/x/text v0.3.0\n)\n",
+	})
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "run go get gopkg.in/yaml.v3") {
+		t.Fatalf("run() error = %v, want the missing requirement", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "embed.go")); !os.IsNotExist(err) {
+		t.Errorf("embed.go was written: %v", err)
+	}
+}
+
+func TestRequiresModule(t *testing.T) {
+	tests := []struct {
+		gomod string
+		want  bool
+	}{
+		{"module m\n\nrequire gopkg.in/yaml.v3 v3.0.1\n", true},
+		{"module m\n\nrequire (\n\tgolang.org/x/text v0.3.0\n\tgopkg.in/yaml.v3 v3.0.1 // indirect\n)\n", true},
+		{"module m\n\nrequire (\n\tgolang.org/x/text v0.3.0\n)\n\nreplace gopkg.in/yaml.v3 => ../yaml\n", false},
+		{"module m\n", false},
+	}
+	for _, tt := range tests {
+		if got := requiresModule(tt.gomod, "gopkg.in/yaml.v3"); got != tt.want {
+			t.Errorf("requiresModule(%q) = %v, want %v", tt.gomod, got, tt.want)
+		}
+	}
+}
+
+func TestGeneratedVarTypeAuto(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/readme.txt": "hello, 世界\n",
+		"src/logo.png":   "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+		"src/latin1.txt": "caf\xe9",
+		"src/raw.bin":    "\x00\x01",
+		"src/data.bin":   "plain text in a .bin",
+		"embed.yaml": `output: assets
+go-mod: main
+var-type: auto
+registry: All
+files:
+  - src/readme.txt
+  - src/logo.png
+  - src/latin1.txt
+  - source: src/raw.bin
+    var-type: string
+  - source: src/data.bin
+    var-type: bytes
+    literal: true
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Printf("%T %T %T %T %T %d\n", Readme, Logo, Latin1, Raw, Data, len(All))
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	embedGo, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`Readme\s+string`, `Logo\s+\[\]byte`, `Latin1\s+\[\]byte`, `Raw\s+string`, `Data\s+= \[\]byte\(decodeAsset\(`} {
+		if !regexp.MustCompile(want).Match(embedGo) {
+			t.Errorf("embed.go does not match %s:\n%s", want, embedGo)
+		}
+	}
+	if out, want := runGenerated(t, tmpDir), "string []uint8 []uint8 string []uint8 5\n"; out != want {
+		t.Errorf("program output = %q, want %q", out, want)
+	}
+}
+
+func TestGeneratedSubpackage(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/greeting.txt": "hello from a subpackage",
+		"embed.yaml": `output: internal/assets/files
+go-output: internal/assets/embed.go
+files:
+  - src/greeting.txt
+`,
+		"main.go": `package main
+
+import (
+	"fmt"
+
+	"example.com/generated/internal/assets"
+)
+
+func main() {
+	fmt.Print(assets.Greeting)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	embedGo, err := os.ReadFile(filepath.Join(tmpDir, "internal", "assets", "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"package assets\n", "//go:embed files/greeting.txt\n"} {
+		if !strings.Contains(string(embedGo), want) {
+			t.Errorf("embed.go does not contain %q:\n%s", want, embedGo)
+		}
+	}
+	if out := runGenerated(t, tmpDir); out != "hello from a subpackage" {
+		t.Errorf("output = %q", out)
+	}
+}
+
+func TestOutputOutsideGoOutputDir(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/greeting.txt": "hello",
+		"embed.yaml": `output: assets
+go-output: internal/assets/embed.go
+files:
+  - src/greeting.txt
+`,
+	})
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "assets/greeting.txt is outside internal/assets") {
+		t.Errorf("run() error = %v, want the output to be rejected", err)
+	}
+}
+
+func TestGeneratedHotReload(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/config.xml": "<config/>",
+		"src/logo.png":   "png",
+		"src/query.sql":  "SELECT 1",
+		"embed.yaml": `output: assets
+go-mod: main
+hot-reload: true
+files:
+  - src/config.xml
+  - source: src/logo.png
+    var-type: bytes
+  - source: src/query.sql
+    type-name: SQL
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(Config, string(Logo), Query)
+	SetConfig("<live/>")
+	SetLogo([]byte("live png"))
+	SetQuery(SQL("SELECT 2"))
+	fmt.Println(Config, string(Logo), Query)
+	// The embedded content stays available as the default
+	fmt.Println(embeddedConfig)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	expected := "<config/> png SELECT 1\n<live/> live png SELECT 2\n<config/>\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+
+	// Without hot-reload the variables are embedded directly and have no setters
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - src/config.xml\n"})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "SetConfig") || strings.Contains(string(data), "embeddedConfig") {
+		t.Errorf("embed.go without hot-reload =\n%s", data)
+	}
+}
+
+func TestGeneratedTypeName(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/create_tables.sql": "CREATE TABLE users (id INT);",
+		"src/drop_tables.sql":   "DROP TABLE users;",
+		"src/logo.png":          "\x89PNG",
+		"embed.yaml": `output: assets
+go-mod: main
+type-name: SQLScript
+registry: AllAssets
+files:
+  - src/create_tables.sql
+  - source: src/drop_tables.sql
+    literal: true
+  - source: src/logo.png
+    var-type: bytes
+    type-name: Image
+`,
+		"main.go": `package main
+
+import "fmt"
+
+// run only accepts scripts, which is what the type is for
+func run(script SQLScript) {
+	fmt.Println(string(script))
+}
+
+func main() {
+	run(CreateTables)
+	run(DropTables)
+	var img Image = Logo
+	fmt.Println(len(img), len(AllAssets))
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"type SQLScript string", "type Image []byte", "var embeddedCreateTables string", "var CreateTables = SQLScript(embeddedCreateTables)"} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("embed.go does not contain %q:\n%s", want, data)
+		}
+	}
+	want := "CREATE TABLE users (id INT);\nDROP TABLE users;\n4 3\n"
+	if out := runGenerated(t, tmpDir); out != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+}
+
+func TestGeneratedTypeNameConflict(t *testing.T) {
+	assets := []asset{
+		{fileInfo: fileInfo{entry: &FileEntry{}}, varName: "Schema", typeName: "Doc"},
+		{fileInfo: fileInfo{entry: &FileEntry{}}, varName: "Logo", typeName: "Doc", bytes: true},
+	}
+	_, err := generateEmbedGo("main", assets, &EmbedConfig{})
+	if err == nil || !strings.Contains(err.Error(), "type-name Doc is used for Schema (string) and Logo ([]byte)") {
+		t.Errorf("generateEmbedGo() error = %v, want a conflicting type-name error", err)
+	}
+}
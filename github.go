@@ -0,0 +1,170 @@
+package main
+
+import (
+  "encoding/base64"
+  "encoding/json"
+  "fmt"
+  "net/http"
+  "net/url"
+  "path/filepath"
+  "strings"
+)
+
+// githubAPIURL is the base URL of the GitHub REST API
+var githubAPIURL = "https://api.github.com"
+
+// githubFile is a file in a GitHub repository at a ref
+type githubFile struct {
+  owner, repo, ref, path string
+}
+
+// parseGitHubFile parses the owner/repo@ref:path shorthand; the ref is optional and defaults to HEAD
+func parseGitHubFile(s string) (*githubFile, error) {
+  repo, filePath, found := strings.Cut(s, ":")
+  repo, ref, _ := strings.Cut(repo, "@")
+  owner, name, _ := strings.Cut(repo, "/")
+  filePath = strings.TrimPrefix(filePath, "/")
+  if !found || owner == "" || name == "" || strings.Contains(name, "/") || filePath == "" {
+    return nil, fmt.Errorf("invalid github %q: want owner/repo@ref:path", s)
+  }
+  return &githubFile{owner: owner, repo: name, ref: ref, path: filePath}, nil
+}
+
+// repoFile returns the repository file a repo-relative path refers to when github defaults are configured,
+// or nil for URLs and explicit local paths
+func repoFile(file string, gh *GitHubSource) *githubFile {
+  if gh == nil || isRemoteURL(file) {
+    return nil
+  }
+  if strings.HasPrefix(file, "./") || strings.HasPrefix(file, "../") || filepath.IsAbs(file) {
+    return nil
+  }
+  return &githubFile{owner: gh.Owner, repo: gh.Repo, ref: gh.Ref, path: strings.TrimPrefix(file, "/")}
+}
+
+// rawURL returns the raw.githubusercontent.com URL of the file
+func (f *githubFile) rawURL() string {
+  ref := f.ref
+  if ref == "" {
+    ref = "HEAD"
+  }
+  return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", f.owner, f.repo, ref, f.path)
+}
+
+// contentsURL returns the REST API contents endpoint of the file
+func (f *githubFile) contentsURL() string {
+  segments := strings.Split(f.path, "/")
+  for i, seg := range segments {
+    segments[i] = url.PathEscape(seg)
+  }
+  u := fmt.Sprintf("%s/repos/%s/%s/contents/%s", githubAPIURL, f.owner, f.repo, strings.Join(segments, "/"))
+  // Without a ref the API serves the default branch, like HEAD does for raw URLs
+  if f.ref != "" && f.ref != "HEAD" {
+    u += "?ref=" + url.QueryEscape(f.ref)
+  }
+  return u
+}
+
+// githubContent is the part of a contents or git blob API response used to read a file
+type githubContent struct {
+  Type     string `json:"type"`
+  Encoding string `json:"encoding"`
+  Content  string `json:"content"`
+  SHA      string `json:"sha"`
+}
+
+// githubCommit is the part of a commits API response used to pin a file
+type githubCommit struct {
+  SHA string `json:"sha"`
+}
+
+// latestCommit returns the SHA of the last commit that changed the file at its ref, from the commits API
+func latestCommit(client *http.Client, f *githubFile, opts fetchOptions) (string, error) {
+  query := url.Values{"path": {f.path}, "per_page": {"1"}}
+  if f.ref != "" && f.ref != "HEAD" {
+    query.Set("sha", f.ref)
+  }
+  commitsURL := fmt.Sprintf("%s/repos/%s/%s/commits?%s", githubAPIURL, f.owner, f.repo, query.Encode())
+  opts.headers = http.Header{"Accept": {"application/vnd.github+json"}}
+  resp, err := fetchRemote(client, commitsURL, opts)
+  if err != nil {
+    return "", err
+  }
+  var commits []githubCommit
+  if err := json.Unmarshal(resp.data, &commits); err != nil {
+    return "", fmt.Errorf("failed to parse %s: %v", commitsURL, err)
+  }
+  if len(commits) == 0 || commits[0].SHA == "" {
+    return "", fmt.Errorf("no commit of %s/%s changed %s", f.owner, f.repo, f.path)
+  }
+  return commits[0].SHA, nil
+}
+
+// resolveGitHubCommits looks up the last commit of every asset in a GitHub repository, for github-commits
+func resolveGitHubCommits(client *http.Client, cfg *EmbedConfig, assets []asset) error {
+  for i, a := range assets {
+    if a.github == nil {
+      continue
+    }
+    timeout := cfg.Timeout
+    if a.entry.Timeout > 0 {
+      timeout = a.entry.Timeout
+    }
+    sha, err := latestCommit(client, a.github, fetchOptions{auth: cfg.authTokens(), timeout: timeout})
+    if err != nil {
+      return err
+    }
+    assets[i].commit = sha
+  }
+  return nil
+}
+
+// fetchGitHubAPI downloads a file through the REST contents API, which works for private repositories
+// without the limits of raw URLs. Files above 1MB come without content and are read from the git blobs API
+func fetchGitHubAPI(client *http.Client, f *githubFile, opts fetchOptions) (sourceFile, error) {
+  headers := opts.headers.Clone()
+  if headers == nil {
+    headers = http.Header{}
+  }
+  headers.Set("Accept", "application/vnd.github+json")
+  opts.headers = headers
+  // max-size limits the file, not the larger base64 JSON it arrives in
+  maxSize := opts.maxSize
+  opts.maxSize = 0
+
+  contentsURL := f.contentsURL()
+  resp, err := fetchRemote(client, contentsURL, opts)
+  if err != nil {
+    return sourceFile{}, err
+  }
+  var content githubContent
+  if err := json.Unmarshal(resp.data, &content); err != nil {
+    return sourceFile{}, fmt.Errorf("failed to parse %s: %v", contentsURL, err)
+  }
+  if content.Type != "file" {
+    return sourceFile{}, fmt.Errorf("%s is not a file in %s/%s", f.path, f.owner, f.repo)
+  }
+  if content.Encoding != "base64" || content.Content == "" {
+    blobURL := fmt.Sprintf("%s/repos/%s/%s/git/blobs/%s", githubAPIURL, f.owner, f.repo, content.SHA)
+    blob, err := fetchRemote(client, blobURL, opts)
+    if err != nil {
+      return sourceFile{}, err
+    }
+    content = githubContent{}
+    if err := json.Unmarshal(blob.data, &content); err != nil {
+      return sourceFile{}, fmt.Errorf("failed to parse %s: %v", blobURL, err)
+    }
+    if content.Encoding != "base64" {
+      return sourceFile{}, fmt.Errorf("unexpected encoding %q of %s", content.Encoding, blobURL)
+    }
+  }
+  // The API wraps base64 content in lines
+  data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(content.Content, "\n", ""))
+  if err != nil {
+    return sourceFile{}, fmt.Errorf("failed to decode %s: %v", contentsURL, err)
+  }
+  if maxSize > 0 && int64(len(data)) > maxSize {
+    return sourceFile{}, &downloadError{url: contentsURL, statusCode: http.StatusOK, attempts: 1, err: fmt.Errorf("%d bytes exceed max-size %d", len(data), maxSize)}
+  }
+  return sourceFile{data: data, resolved: f.rawURL(), modTime: resp.modTime}, nil
+}
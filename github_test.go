@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitHubFile(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    githubFile
+		wantErr bool
+	}{
+		{"owner/repo@v1.2.0:dist/app.js", githubFile{"owner", "repo", "v1.2.0", "dist/app.js"}, false},
+		{"owner/repo:/README.md", githubFile{"owner", "repo", "", "README.md"}, false},
+		{"owner/repo", githubFile{}, true},
+		{"owner:file.txt", githubFile{}, true},
+		{"owner/repo/extra@main:file.txt", githubFile{}, true},
+		{"owner/repo@main:", githubFile{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := parseGitHubFile(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseGitHubFile() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseGitHubFile() error: %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("parseGitHubFile() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchGitHubAPI(t *testing.T) {
+	small := "small file\n"
+	large := strings.Repeat("x", 1<<20+1)
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		if accept := r.Header.Get("Accept"); accept != "application/vnd.github+json" {
+			t.Errorf("Accept = %q", accept)
+		}
+		var resp githubContent
+		switch r.URL.RequestURI() {
+		case "/repos/owner/repo/contents/docs/small.txt?ref=v1":
+			// The API wraps base64 content at 60 characters
+			encoded := base64.StdEncoding.EncodeToString([]byte(small))
+			resp = githubContent{Type: "file", Encoding: "base64", Content: encoded[:8] + "\n" + encoded[8:] + "\n", SHA: "aaa"}
+		case "/repos/owner/repo/contents/large.bin":
+			// Files above 1MB are returned without content
+			resp = githubContent{Type: "file", Encoding: "none", SHA: "bbb"}
+		case "/repos/owner/repo/git/blobs/bbb":
+			resp = githubContent{Encoding: "base64", Content: base64.StdEncoding.EncodeToString([]byte(large)), SHA: "bbb"}
+		case "/repos/owner/repo/contents/docs":
+			resp = githubContent{Type: "dir"}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIURL = orig }(githubAPIURL)
+	githubAPIURL = server.URL
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": `output: assets
+go-mod: main
+files:
+  - github: owner/repo@v1:docs/small.txt
+    api: true
+  - github: owner/repo:large.bin
+    api: true
+`})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	for name, want := range map[string]string{"small.txt": small, "large.bin": large} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, "assets", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s has %d bytes, want %d", name, len(data), len(want))
+		}
+	}
+	if len(requests) != 3 {
+		t.Errorf("requests = %v, want contents of both files and one blob", requests)
+	}
+
+	t.Run("directory", func(t *testing.T) {
+		_, err := fetchGitHubAPI(http.DefaultClient, &githubFile{owner: "owner", repo: "repo", path: "docs"}, fetchOptions{})
+		if err == nil || !strings.Contains(err.Error(), "is not a file") {
+			t.Errorf("fetchGitHubAPI() error = %v, want it to reject directories", err)
+		}
+	})
+}
+
+func TestGitHubAPIRequiresGitHubFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: https://example.com/file.txt
+    api: true
+`})
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "api requires a github file") {
+		t.Errorf("run() error = %v, want it to require a github file", err)
+	}
+}
+
+func TestGitHubCommits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/owner/repo/contents/docs/guide.md":
+			json.NewEncoder(w).Encode(githubContent{Type: "file", Encoding: "base64", Content: base64.StdEncoding.EncodeToString([]byte("# Guide\n")), SHA: "blob"})
+		case "/repos/owner/repo/commits":
+			if path, sha := r.URL.Query().Get("path"), r.URL.Query().Get("sha"); path != "docs/guide.md" || sha != "v1" {
+				t.Errorf("commits requested for path %q at %q", path, sha)
+			}
+			json.NewEncoder(w).Encode([]githubCommit{{SHA: "3f786850e387550fdab836ed7e6dc881de23001b"}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIURL = orig }(githubAPIURL)
+	githubAPIURL = server.URL
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"local.txt": "local",
+		"embed.yaml": `output: assets
+go-mod: main
+github-commits: true
+files:
+  - github: owner/repo@v1:docs/guide.md
+    api: true
+  - local.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `GuideCommit = "3f786850e387550fdab836ed7e6dc881de23001b"`) {
+		t.Errorf("embed.go does not have the commit of guide.md:\n%s", data)
+	}
+	// Files outside GitHub have no commit
+	if strings.Contains(string(data), "LocalCommit") {
+		t.Errorf("embed.go has a commit for local.txt:\n%s", data)
+	}
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+  "crypto/hmac"
+  "crypto/sha256"
+  "encoding/base64"
+  "encoding/hex"
+  "fmt"
+  "net/http"
+  "strings"
+  "text/template"
+  "time"
+)
+
+// headerData is the file metadata available to header templates
+type headerData struct {
+  Name string // file name, e.g. config.json
+  Path string // source path, e.g. myorg/repo/main/config.json
+  URL  string // expanded download URL
+}
+
+// headerFuncs are the helpers available to header templates
+var headerFuncs = template.FuncMap{
+  "env": getEnv,
+  // now formats the current UTC time with a Go time layout, e.g. {{now "20060102"}}
+  "now": func(layout string) string {
+    return time.Now().UTC().Format(layout)
+  },
+  // hmacSHA256 returns the hex encoded HMAC-SHA256 of message keyed with key
+  "hmacSHA256": func(key, message string) string {
+    mac := hmac.New(sha256.New, []byte(key))
+    mac.Write([]byte(message))
+    return hex.EncodeToString(mac.Sum(nil))
+  },
+  "base64": func(s string) string {
+    return base64.StdEncoding.EncodeToString([]byte(s))
+  },
+}
+
+// parseHeaderTemplates validates header names and compiles their value templates
+func parseHeaderTemplates(headers map[string]string) (map[string]*template.Template, error) {
+  if len(headers) == 0 {
+    return nil, nil
+  }
+  templates := make(map[string]*template.Template, len(headers))
+  for name, value := range headers {
+    if name == "" || strings.ContainsAny(name, " \t\r\n:") {
+      return nil, fmt.Errorf("invalid header name %q", name)
+    }
+    tmpl, err := template.New(name).Funcs(headerFuncs).Option("missingkey=error").Parse(value)
+    if err != nil {
+      return nil, fmt.Errorf("invalid template for header %s: %v", name, err)
+    }
+    templates[name] = tmpl
+  }
+  return templates, nil
+}
+
+// renderHeaders executes the header templates of a file against its metadata
+func renderHeaders(templates map[string]*template.Template, data headerData) (http.Header, error) {
+  if len(templates) == 0 {
+    return nil, nil
+  }
+  headers := make(http.Header, len(templates))
+  for name, tmpl := range templates {
+    var b strings.Builder
+    if err := tmpl.Execute(&b, data); err != nil {
+      return nil, fmt.Errorf("failed to render header %s: %v", name, err)
+    }
+    value := b.String()
+    if strings.ContainsAny(value, "\r\n") {
+      return nil, fmt.Errorf("failed to render header %s: value contains a line break", name)
+    }
+    headers.Set(name, value)
+  }
+  return headers, nil
+}
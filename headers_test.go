@@ -0,0 +1,81 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunSendsTemplatedHeaders(t *testing.T) {
+	received := make(map[string]http.Header)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received[r.URL.Path] = r.Header.Clone()
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: ` + server.URL + `/signed/users.json
+    headers:
+      X-Asset-Name: "{{.Name}}"
+      X-Signature: '{{hmacSHA256 "secret" .Path}}'
+  - ` + server.URL + `/plain/orders.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	signed := received["/signed/users.json"]
+	if got := signed.Get("X-Asset-Name"); got != "users.json" {
+		t.Errorf("X-Asset-Name = %q, want %q", got, "users.json")
+	}
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte("signed/users.json"))
+	if got, want := signed.Get("X-Signature"), hex.EncodeToString(mac.Sum(nil)); got != want {
+		t.Errorf("X-Signature = %q, want %q", got, want)
+	}
+	if got := received["/plain/orders.json"].Get("X-Asset-Name"); got != "" {
+		t.Errorf("headers leaked to another file: X-Asset-Name = %q", got)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets", "users.json")); err != nil {
+		t.Errorf("users.json not written: %v", err)
+	}
+}
+
+func TestParseHeaderTemplatesValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"bad template", map[string]string{"X-Sig": "{{.Name"}},
+		{"unknown function", map[string]string{"X-Sig": "{{sign .Name}}"}},
+		{"bad header name", map[string]string{"X Sig": "value"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := parseHeaderTemplates(tt.headers); err == nil {
+				t.Errorf("parseHeaderTemplates(%v) expected an error", tt.headers)
+			}
+		})
+	}
+
+	templates, err := parseHeaderTemplates(map[string]string{"X-Sig": "{{.Missing}}"})
+	if err != nil {
+		t.Fatalf("unexpected parse error: %v", err)
+	}
+	if _, err := renderHeaders(templates, headerData{Name: "a.txt"}); err == nil {
+		t.Error("expected an error rendering an unknown field")
+	}
+}
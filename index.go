@@ -0,0 +1,145 @@
+package main
+
+import (
+  "fmt"
+  "html"
+  "net/http"
+  "net/url"
+  "path"
+  "regexp"
+  "sort"
+  "strings"
+)
+
+// indexLink is a file linked from a directory index page
+type indexLink struct {
+  url  string // absolute URL of the file
+  name string // unescaped path relative to the index
+}
+
+// hrefPattern matches the href attribute of an anchor tag, double-quoted, single-quoted or bare.
+// Autoindex pages are simple enough that a full HTML parser is not needed
+var hrefPattern = regexp.MustCompile(`(?is)<a\s[^>]*?\bhref\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+
+// listIndexes fetches the index page of every index entry and records the files it links to that match
+// the entry's pattern. It has to run before planAssets, which expands index entries from these links
+func listIndexes(client *http.Client, cfg *EmbedConfig) error {
+  for i := range cfg.Files {
+    entry := &cfg.Files[i]
+    if entry.Index == "" {
+      continue
+    }
+    index := expandEnvVars(entry.Index)
+    if err := checkAllowedHost(index, cfg.AllowedHosts); err != nil {
+      return err
+    }
+    timeout := cfg.Timeout
+    if entry.Timeout > 0 {
+      timeout = entry.Timeout
+    }
+    page, err := fetchRemote(client, index, fetchOptions{auth: cfg.authTokens(), timeout: timeout})
+    if err != nil {
+      return err
+    }
+    // Relative links resolve against where the page was served from, after redirects such as dir to dir/
+    base := page.resolved
+    if base == "" {
+      base = index
+    }
+    links, err := indexLinks(base, page.data)
+    if err != nil {
+      return fmt.Errorf("failed to read index %s: %v", index, err)
+    }
+    entry.indexed = nil
+    for _, l := range links {
+      if entry.Pattern == "" || matchesIgnore(l.name, []string{entry.Pattern}) {
+        entry.indexed = append(entry.indexed, l)
+      }
+    }
+  }
+  return nil
+}
+
+// indexLinks returns the files linked from an index page served at base, in lexical order.
+// Only links to files below the index directory are kept: parent and subdirectory links,
+// sort links (?C=N;O=D), fragments and links to other hosts are skipped, as are names that
+// only leave the directory once unescaped, such as %2E%2E/main.go
+func indexLinks(base string, page []byte) ([]indexLink, error) {
+  baseURL, err := url.Parse(base)
+  if err != nil {
+    return nil, err
+  }
+  // The index is a directory, even when it was requested without the trailing slash
+  if !strings.HasSuffix(baseURL.Path, "/") {
+    baseURL.Path += "/"
+    baseURL.RawPath = ""
+  }
+  baseURL.RawQuery, baseURL.Fragment = "", ""
+  seen := map[string]bool{}
+  var links []indexLink
+  for _, m := range hrefPattern.FindAllSubmatch(page, -1) {
+    href := strings.TrimSpace(html.UnescapeString(string(m[1]) + string(m[2]) + string(m[3])))
+    if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(href, "?") {
+      continue
+    }
+    ref, err := url.Parse(href)
+    if err != nil {
+      continue
+    }
+    u := baseURL.ResolveReference(ref)
+    u.Fragment = ""
+    if u.Scheme != baseURL.Scheme || !strings.EqualFold(u.Host, baseURL.Host) || u.RawQuery != "" {
+      continue
+    }
+    // The escaped path keeps %2E%2E and %2F from turning into path separators before the prefix is cut
+    escaped, ok := strings.CutPrefix(u.EscapedPath(), baseURL.EscapedPath())
+    if !ok {
+      continue
+    }
+    name, err := url.PathUnescape(escaped)
+    if err != nil || !isRelativeName(name) || seen[name] {
+      continue
+    }
+    seen[name] = true
+    links = append(links, indexLink{url: u.String(), name: name})
+  }
+  sort.Slice(links, func(i, j int) bool { return links[i].name < links[j].name })
+  return links, nil
+}
+
+// isRelativeName reports whether name is a file path that stays below the directory it is relative to:
+// it has no empty, . or .. segments and is not absolute
+func isRelativeName(name string) bool {
+  if name == "" || path.Clean(name) != name {
+    return false
+  }
+  for _, seg := range strings.Split(name, "/") {
+    if seg == "" || seg == "." || seg == ".." || strings.Contains(seg, "\\") {
+      return false
+    }
+  }
+  return true
+}
+
+// expandIndex turns an index entry into the files listIndexes found for it. Each file keeps its path
+// relative to the index, like the files of a recursive directory
+func expandIndex(baseDir string, cfg *EmbedConfig, entry *FileEntry) ([]fileInfo, error) {
+  if len(entry.indexed) == 0 {
+    if entry.Pattern != "" {
+      return nil, fmt.Errorf("%s: no linked files match %s", entry.Index, entry.Pattern)
+    }
+    return nil, fmt.Errorf("%s: no linked files found", entry.Index)
+  }
+  var infos []fileInfo
+  for _, l := range entry.indexed {
+    fileInfos, err := expandSource(baseDir, cfg, entry, entry.Index, l.url)
+    if err != nil {
+      return nil, err
+    }
+    for _, fi := range fileInfos {
+      fi.treePath = l.name
+      infos = append(infos, fi)
+    }
+  }
+  return infos, nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndexLinks(t *testing.T) {
+	page := `<html><head><title>Index of /dir/</title></head><body>
+<a href="?C=N;O=D">Name</a> <a href='?C=M;O=A'>Last modified</a>
+<a href="../">Parent Directory</a>
+<a href="sub/">sub/</a>
+<A HREF="b.sql">b.sql</A>
+<a class="file" href='a.sql'>a.sql</a>
+<a href=c%20d.sql>c d.sql</a>
+<a href="/dir/e.sql#top">e.sql</a>
+<a href="b.sql">again</a>
+<a href="https://other.example.com/dir/x.sql">elsewhere</a>
+<a href="/other/y.sql">outside</a>
+<a href="sub/f.sql?download=1">query</a>
+<a href="g&amp;h.sql">g&amp;h.sql</a>
+<a href="%2E%2E/main.go">escaped parent</a>
+<a href="%2e%2e/%2e%2e/pwn.sql">escaped grandparent</a>
+<a href="sub%2F..%2F..%2Fpwn.sql">escaped separators</a>
+<a href="sub%2F%2Fdouble.sql">empty segment</a>
+<a name="anchor">no href</a>
+</body></html>`
+	links, err := indexLinks("https://files.example.com/dir", []byte(page))
+	if err != nil {
+		t.Fatalf("indexLinks() error: %v", err)
+	}
+	want := []indexLink{
+		{"https://files.example.com/dir/a.sql", "a.sql"},
+		{"https://files.example.com/dir/b.sql", "b.sql"},
+		{"https://files.example.com/dir/c%20d.sql", "c d.sql"},
+		{"https://files.example.com/dir/e.sql", "e.sql"},
+		{"https://files.example.com/dir/g&h.sql", "g&h.sql"},
+	}
+	if !reflect.DeepEqual(links, want) {
+		t.Errorf("indexLinks() =\n%v\nwant\n%v", links, want)
+	}
+}
+
+func TestRunIndex(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/dumps":
+			http.Redirect(w, r, "/dumps/", http.StatusMovedPermanently)
+		case "/dumps/":
+			io.WriteString(w, `<pre><a href="../">../</a>
+<a href="schema.sql">schema.sql</a>
+<a href="data.sql">data.sql</a>
+<a href="README.txt">README.txt</a>
+<a href="old/">old/</a></pre>`)
+		case "/dumps/schema.sql", "/dumps/data.sql":
+			io.WriteString(w, "-- "+strings.TrimPrefix(r.URL.Path, "/dumps/"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - index: " + server.URL + "/dumps\n    pattern: \"*.sql\"\n",
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	for _, name := range []string{"data.sql", "schema.sql"} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, "assets", name))
+		if err != nil || string(data) != "-- "+name {
+			t.Errorf("assets/%s = %q, %v", name, data, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets", "README.txt")); !os.IsNotExist(err) {
+		t.Errorf("README.txt does not match the pattern but was embedded")
+	}
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if !strings.Contains(string(embedGo), "//go:embed assets/data.sql\nvar Data string") {
+		t.Errorf("embed.go does not embed data.sql:\n%s", embedGo)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - index: " + server.URL + "/dumps/\n    pattern: \"*.csv\"\n",
+	})
+	if err := run(tmpDir, options{}, io.Discard); err == nil || !strings.Contains(err.Error(), "no linked files match *.csv") {
+		t.Errorf("run() error = %v, want no matching files", err)
+	}
+}
+
+func TestRunIndexTraversal(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/dumps/" {
+			io.WriteString(w, `<a href="%2E%2E/main.go">main.go</a>
+<a href="%2E%2E/%2E%2E/pwn.txt">pwn.txt</a>
+<a href="schema.sql">schema.sql</a>`)
+			return
+		}
+		io.WriteString(w, "pwned")
+	}))
+	defer server.Close()
+
+	root := t.TempDir()
+	tmpDir := filepath.Join(root, "project")
+	writeTestFiles(t, tmpDir, map[string]string{
+		"main.go":    "package main\n",
+		"embed.yaml": "output: assets\ngo-output: none\nfiles:\n  - index: " + server.URL + "/dumps/\n",
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "main.go")); string(data) != "package main\n" {
+		t.Errorf("main.go was overwritten with %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(root, "pwn.txt")); !os.IsNotExist(err) {
+		t.Errorf("pwn.txt was written outside the project")
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "schema.sql")); string(data) != "pwned" {
+		t.Errorf("assets/schema.sql = %q, want the linked file", data)
+	}
+
+	// Names are checked against output in planAssets too, with or without go-output
+	for _, goOutput := range []string{"embed.go", "none"} {
+		writeTestFiles(t, tmpDir, map[string]string{
+			"embed.yaml": "output: assets\ngo-output: " + goOutput + "\nfiles:\n  - index: " + server.URL + "/dumps/\n",
+		})
+		cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg.Files[0].indexed = []indexLink{{server.URL + "/pwn.txt", "../../pwn.txt"}}
+		if _, err := planAssets(tmpDir, cfg); err == nil || !strings.Contains(err.Error(), "is outside output assets") {
+			t.Errorf("go-output %s: planAssets() error = %v, want outside output", goOutput, err)
+		}
+	}
+}
+
+func TestLoadConfigIndex(t *testing.T) {
+	tests := []struct {
+		entry   string
+		wantErr string
+	}{
+		{"  - index: https://files.example.com/dir/\n    pattern: \"*.sql\"\n", ""},
+		{"  - index: ./dir\n", "must be an http(s) URL"},
+		{"  - index: https://files.example.com/dir/\n    pattern: \"[\"\n", "invalid pattern"},
+		{"  - index: https://files.example.com/dir/\n    source: https://files.example.com/a.sql\n", "cannot be combined"},
+		{"  - index: https://files.example.com/dir/\n    checksum: sha256:" + strings.Repeat("0", 64) + "\n", "checksum is not supported"},
+		{"  - source: https://files.example.com/a.sql\n    pattern: \"*.sql\"\n", "only supported for index entries"},
+	}
+	for _, tt := range tests {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "files:\n" + tt.entry})
+		_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("loadConfig(%q) error: %v", tt.entry, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("loadConfig(%q) error = %v, want %q", tt.entry, err, tt.wantErr)
+		}
+	}
+}
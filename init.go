@@ -0,0 +1,133 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "path/filepath"
+  "sort"
+  "strings"
+
+  "gopkg.in/yaml.v3"
+)
+
+// githubDirScheme prefixes the repository directory -init-from scaffolds a config from
+const githubDirScheme = "github-dir://"
+
+// githubEntry is the part of an item of a contents API directory listing used to walk it
+type githubEntry struct {
+  Type string `json:"type"`
+  Path string `json:"path"`
+}
+
+// initConfig is the config written by -init-from
+type initConfig struct {
+  Output      string `yaml:"output"`
+  GithubToken string `yaml:"github-token,omitempty"`
+  GitHub      struct {
+    Owner string `yaml:"owner"`
+    Repo  string `yaml:"repo"`
+    Ref   string `yaml:"ref,omitempty"`
+  } `yaml:"github"`
+  Files []string `yaml:"files"`
+}
+
+// parseGitHubDir parses github-dir://owner/repo/path@ref. The path is optional and means the repository root,
+// and without a ref the default branch is listed
+func parseGitHubDir(s string) (*githubFile, error) {
+  rest, ok := strings.CutPrefix(s, githubDirScheme)
+  if !ok {
+    return nil, fmt.Errorf("invalid -init-from %q: want %sowner/repo/path@ref", s, githubDirScheme)
+  }
+  rest, ref, _ := strings.Cut(rest, "@")
+  parts := strings.SplitN(strings.Trim(rest, "/"), "/", 3)
+  if len(parts) < 2 || parts[0] == "" || parts[1] == "" {
+    return nil, fmt.Errorf("invalid -init-from %q: want %sowner/repo/path@ref", s, githubDirScheme)
+  }
+  dir := &githubFile{owner: parts[0], repo: parts[1], ref: ref}
+  if len(parts) == 3 {
+    dir.path = strings.Trim(parts[2], "/")
+  }
+  return dir, nil
+}
+
+// listGitHubDir returns the paths of every file below dir, walking subdirectories through the contents API.
+// Symlinks and submodules are skipped. Nothing is downloaded
+func listGitHubDir(client *http.Client, dir *githubFile, opts fetchOptions) ([]string, error) {
+  headers := http.Header{}
+  headers.Set("Accept", "application/vnd.github+json")
+  opts.headers = headers
+
+  listURL := dir.contentsURL()
+  resp, err := fetchRemote(client, listURL, opts)
+  if err != nil {
+    return nil, err
+  }
+  var entries []githubEntry
+  if err := json.Unmarshal(resp.data, &entries); err != nil {
+    // A file comes back as a single object
+    return nil, fmt.Errorf("%s is not a directory in %s/%s", dir.path, dir.owner, dir.repo)
+  }
+  var files []string
+  for _, e := range entries {
+    switch e.Type {
+    case "file":
+      files = append(files, e.Path)
+    case "dir":
+      sub, err := listGitHubDir(client, &githubFile{owner: dir.owner, repo: dir.repo, ref: dir.ref, path: e.Path}, opts)
+      if err != nil {
+        return nil, err
+      }
+      files = append(files, sub...)
+    }
+  }
+  sort.Strings(files)
+  return files, nil
+}
+
+// initFrom writes a new config listing every file of the repository directory named by opts.initFrom.
+// GITHUB_TOKEN, from .env or the environment, is used for the listing and referenced by the config when set.
+// An existing config is never overwritten
+func initFrom(cwd string, opts options, stdout io.Writer) error {
+  configPath := opts.configFile(cwd)
+  if _, err := os.Stat(configPath); err == nil {
+    return fmt.Errorf("%s already exists", configPath)
+  }
+  dir, err := parseGitHubDir(opts.initFrom)
+  if err != nil {
+    return err
+  }
+  loadDotEnv(filepath.Dir(configPath))
+  token := getEnv("GITHUB_TOKEN")
+
+  client := newHTTPClient(defaultMaxRedirects, nil, 0)
+  files, err := listGitHubDir(client, dir, fetchOptions{auth: authTokens{github: token}})
+  if err != nil {
+    return err
+  }
+  if len(files) == 0 {
+    return fmt.Errorf("no files found in %s", opts.initFrom)
+  }
+
+  cfg := initConfig{Output: "assets", Files: files}
+  if token != "" {
+    cfg.GithubToken = "$GITHUB_TOKEN"
+  }
+  cfg.GitHub.Owner, cfg.GitHub.Repo, cfg.GitHub.Ref = dir.owner, dir.repo, dir.ref
+  var b strings.Builder
+  enc := yaml.NewEncoder(&b)
+  enc.SetIndent(2)
+  if err := enc.Encode(&cfg); err != nil {
+    return fmt.Errorf("failed to encode config: %v", err)
+  }
+  if err := enc.Close(); err != nil {
+    return fmt.Errorf("failed to encode config: %v", err)
+  }
+  if err := os.WriteFile(configPath, []byte(b.String()), 0644); err != nil {
+    return fmt.Errorf("failed to write %s: %v", configPath, err)
+  }
+  fmt.Fprintf(stdout, "wrote %s with %d files from %s\n", configPath, len(files), opts.initFrom)
+  return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseGitHubDir(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    githubFile
+		wantErr bool
+	}{
+		{input: "github-dir://owner/repo/schemas/v1@main", want: githubFile{owner: "owner", repo: "repo", ref: "main", path: "schemas/v1"}},
+		{input: "github-dir://owner/repo/schemas/", want: githubFile{owner: "owner", repo: "repo", path: "schemas"}},
+		{input: "github-dir://owner/repo@v2", want: githubFile{owner: "owner", repo: "repo", ref: "v2"}},
+		{input: "github-dir://owner", wantErr: true},
+		{input: "https://github.com/owner/repo", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseGitHubDir(tt.input)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseGitHubDir(%q) = %+v, want an error", tt.input, got)
+			}
+			continue
+		}
+		if err != nil || *got != tt.want {
+			t.Errorf("parseGitHubDir(%q) = %+v, %v; want %+v", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestInitFrom(t *testing.T) {
+	var requests []string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		auth = r.Header.Get("Authorization")
+		if r.URL.Query().Get("ref") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+		var entries []githubEntry
+		switch r.URL.Path {
+		case "/repos/owner/repo/contents/schemas":
+			entries = []githubEntry{
+				{Type: "file", Path: "schemas/users.json"},
+				{Type: "dir", Path: "schemas/v2"},
+				{Type: "symlink", Path: "schemas/latest"},
+				{Type: "file", Path: "schemas/orders.json"},
+			}
+		case "/repos/owner/repo/contents/schemas/v2":
+			entries = []githubEntry{{Type: "file", Path: "schemas/v2/users.json"}}
+		case "/repos/owner/repo/contents/README.md":
+			// A file is returned as an object, not a listing
+			json.NewEncoder(w).Encode(githubContent{Type: "file"})
+			return
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(entries)
+	}))
+	defer server.Close()
+	defer func(orig string) { githubAPIURL = orig }(githubAPIURL)
+	githubAPIURL = server.URL
+	t.Setenv("GITHUB_TOKEN", "secret")
+
+	tmpDir := t.TempDir()
+	if err := initFrom(tmpDir, options{initFrom: "github-dir://owner/repo/schemas@main"}, io.Discard); err != nil {
+		t.Fatalf("initFrom() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `output: assets
+github-token: $GITHUB_TOKEN
+github:
+  owner: owner
+  repo: repo
+  ref: main
+files:
+  - schemas/orders.json
+  - schemas/users.json
+  - schemas/v2/users.json
+`
+	if string(data) != want {
+		t.Errorf("embed.yaml =\n%s\nwant\n%s", data, want)
+	}
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want the GITHUB_TOKEN", auth)
+	}
+	wantRequests := []string{"/repos/owner/repo/contents/schemas?ref=main", "/repos/owner/repo/contents/schemas/v2?ref=main"}
+	if !slices.Equal(requests, wantRequests) {
+		t.Errorf("requests = %q, want only the directory listings %q", requests, wantRequests)
+	}
+
+	// The scaffolded config loads and resolves to raw URLs of the listed files
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if got := resolveFileURL(cfg.Files[0].Source, cfg.GitHub); got != "https://raw.githubusercontent.com/owner/repo/main/schemas/orders.json" {
+		t.Errorf("first file resolves to %s", got)
+	}
+
+	t.Run("existing config", func(t *testing.T) {
+		err := initFrom(tmpDir, options{initFrom: "github-dir://owner/repo/schemas@main"}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "already exists") {
+			t.Errorf("initFrom() error = %v, want the existing config kept", err)
+		}
+	})
+
+	t.Run("not a directory", func(t *testing.T) {
+		err := initFrom(t.TempDir(), options{initFrom: "github-dir://owner/repo/README.md@main"}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "README.md is not a directory in owner/repo") {
+			t.Errorf("initFrom() error = %v, want a file rejected", err)
+		}
+	})
+}
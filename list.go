@@ -0,0 +1,100 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "net/url"
+  "os"
+  "strings"
+  "text/tabwriter"
+)
+
+// secretParams are query parameter names whose values are redacted by redactURL
+var secretParams = []string{"token", "key", "secret", "password", "passwd", "signature", "sig", "auth", "credential"}
+
+// listAssets prints the variable name, embed path and source of every asset as a table,
+// followed by the index entries, whose files are only known from their index pages
+func listAssets(stdout io.Writer, assets []asset, indexes []FileEntry) error {
+  tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+  fmt.Fprintln(tw, "VAR\tEMBED PATH\tSOURCE")
+  for _, a := range assets {
+    embedPath := a.relEmbedPath
+    if a.entry.Literal {
+      embedPath = "(literal)"
+    }
+    fmt.Fprintf(tw, "%s\t%s\t%s\n", a.varName, embedPath, redactURL(a.expandedURL))
+  }
+  for _, entry := range indexes {
+    source := redactURL(expandEnvVars(entry.Index))
+    if entry.Pattern != "" {
+      source += " (" + entry.Pattern + ")"
+    }
+    fmt.Fprintf(tw, "-\t(index)\t%s\n", source)
+  }
+  return tw.Flush()
+}
+
+// withoutIndexes returns a copy of cfg without its index entries, and those entries
+func withoutIndexes(cfg *EmbedConfig) (*EmbedConfig, []FileEntry) {
+  listed := *cfg
+  listed.Files = nil
+  var indexes []FileEntry
+  for _, entry := range cfg.Files {
+    if entry.Index != "" {
+      indexes = append(indexes, entry)
+    } else {
+      listed.Files = append(listed.Files, entry)
+    }
+  }
+  return &listed, indexes
+}
+
+// checkLocalSources reports the local files of assets that do not exist, resolved against baseDir, without reading them.
+// Remote files and data: URIs are not checked
+func checkLocalSources(baseDir string, cfg *EmbedConfig, assets []asset) error {
+  var missing []string
+  for _, a := range assets {
+    sources := []string{a.expandedURL}
+    if a.entry.Merge != nil {
+      sources = mergeSources(a.entry, cfg.GitHub)
+    }
+    for _, source := range sources {
+      if a.entry.isRemote(source) || a.entry.isDataURI(source) {
+        continue
+      }
+      if _, err := os.Stat(resolvePath(baseDir, source)); err != nil {
+        missing = append(missing, source)
+      }
+    }
+  }
+  if len(missing) > 0 {
+    return fmt.Errorf("local files not found: %s", strings.Join(missing, ", "))
+  }
+  return nil
+}
+
+// redactURL hides the password of a URL and the values of query parameters that look like credentials.
+// Local paths are returned unchanged.
+func redactURL(rawURL string) string {
+  if !isRemoteURL(rawURL) {
+    return rawURL
+  }
+  u, err := url.Parse(rawURL)
+  if err != nil {
+    return rawURL
+  }
+  if u.RawQuery != "" {
+    query := u.Query()
+    for name := range query {
+      lower := strings.ToLower(name)
+      for _, secret := range secretParams {
+        if strings.Contains(lower, secret) {
+          query[name] = []string{"xxxxx"}
+          break
+        }
+      }
+    }
+    u.RawQuery = query.Encode()
+  }
+  return u.Redacted()
+}
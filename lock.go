@@ -0,0 +1,142 @@
+package main
+
+import (
+  "crypto/sha256"
+  "encoding/hex"
+  "fmt"
+  "maps"
+  "os"
+  "slices"
+  "strings"
+  "time"
+
+  "gopkg.in/yaml.v3"
+)
+
+// lockHeader is written at the top of every lockfile
+const lockHeader = "# Generated by remoteembed. DO NOT EDIT.\n"
+
+// lockFile pins every remote file to the URL it resolved to and the checksum of its content.
+// Assets keeps what only exists in the generated Go code, so that -only can regenerate it
+type lockFile struct {
+  Files  []lockEntry `yaml:"files"`
+  Assets []lockAsset `yaml:"assets,omitempty"`
+}
+
+// lockAsset is the part of a variable's generated code that is not in output: its modification time
+// with mod-times and its compressed content when it is a literal
+type lockAsset struct {
+  Var     string    `yaml:"var"`
+  ModTime time.Time `yaml:"mod-time,omitempty"`
+  Literal string    `yaml:"literal,omitempty"`
+}
+
+// lockEntry is the locked state of one configured URL. Resolved is only set when the URL redirected.
+// Transform tells apart entries embedding one URL differently, since the digest is taken of the embedded content
+type lockEntry struct {
+  URL       string `yaml:"url"`
+  Transform string `yaml:"transform,omitempty"`
+  Resolved  string `yaml:"resolved,omitempty"`
+  SHA256    string `yaml:"sha256"`
+}
+
+// readLockFile parses the lockfile at path
+func readLockFile(path string) (*lockFile, error) {
+  data, err := os.ReadFile(path)
+  if err != nil {
+    return nil, fmt.Errorf("failed to read lockfile: %v", err)
+  }
+  var lock lockFile
+  if err := yaml.Unmarshal(data, &lock); err != nil {
+    return nil, fmt.Errorf("failed to parse lockfile %s: %v", path, err)
+  }
+  return &lock, nil
+}
+
+// lookup returns the entry locked for url embedded with transform, see lockTransform
+func (l *lockFile) lookup(url, transform string) (lockEntry, bool) {
+  for _, e := range l.Files {
+    if e.URL == url && e.Transform == transform {
+      return e, true
+    }
+  }
+  return lockEntry{}, false
+}
+
+// add records the content downloaded for url and embedded with transform, once per URL and transform
+func (l *lockFile) add(url, transform, resolved string, data []byte) {
+  if _, ok := l.lookup(url, transform); ok {
+    return
+  }
+  e := lockEntry{URL: url, Transform: transform, SHA256: sha256Hex(data)}
+  if resolved != url {
+    e.Resolved = resolved
+  }
+  l.Files = append(l.Files, e)
+}
+
+// addAsset records the modification time and literal content of a, when the generated code has them
+func (l *lockFile) addAsset(cfg *EmbedConfig, a asset, data []byte) error {
+  e := lockAsset{Var: a.varName}
+  if cfg.ModTimes {
+    e.ModTime = a.modTime
+  }
+  if a.entry.Literal {
+    literal, err := compressLiteral(data)
+    if err != nil {
+      return fmt.Errorf("failed to compress %s: %v", a.varName, err)
+    }
+    e.Literal = literal
+  }
+  if !e.ModTime.IsZero() || e.Literal != "" {
+    l.Assets = append(l.Assets, e)
+  }
+  return nil
+}
+
+// lockTransform describes the settings that make the embedded content of entry differ from the file at its URL,
+// such as range, pipe or line-endings. It is empty for files embedded as downloaded, which keeps their entries
+// keyed by the URL alone
+func (cfg *EmbedConfig) lockTransform(entry *FileEntry) string {
+  var parts []string
+  add := func(key, value string) {
+    if value != "" {
+      parts = append(parts, key+": "+value)
+    }
+  }
+  add("range", entry.Range)
+  add("encoding", entry.Encoding)
+  if cfg.stripBOM(entry) {
+    add("strip-bom", "true")
+  }
+  add("pipe", entry.Pipe)
+  for _, prefix := range slices.Sorted(maps.Keys(entry.RewriteURLs)) {
+    add("rewrite-urls", prefix+" "+entry.RewriteURLs[prefix])
+  }
+  lineEndings, trailingNewline := cfg.normalizeOptions(entry)
+  if lineEndings != "keep" {
+    add("line-endings", lineEndings)
+  }
+  if trailingNewline {
+    add("ensure-trailing-newline", "true")
+  }
+  if entry.EmbedEncoding != "raw" {
+    add("embed-encoding", entry.EmbedEncoding)
+  }
+  return strings.Join(parts, ", ")
+}
+
+// marshal renders the lockfile with its header
+func (l *lockFile) marshal() ([]byte, error) {
+  data, err := yaml.Marshal(l)
+  if err != nil {
+    return nil, fmt.Errorf("failed to encode lockfile: %v", err)
+  }
+  return append([]byte(lockHeader), data...), nil
+}
+
+// sha256Hex returns the hex encoded SHA-256 digest of data
+func sha256Hex(data []byte) string {
+  sum := sha256.Sum256(data)
+  return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLockfilePinsResolvedURL(t *testing.T) {
+	latest := "/v1/data.json"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/latest/data.json":
+			http.Redirect(w, r, latest, http.StatusFound)
+		case "/v1/data.json":
+			w.Write([]byte("v1"))
+		case "/v2/data.json":
+			w.Write([]byte("v2"))
+		case "/static.json":
+			w.Write([]byte("static"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+lockfile: embed.lock
+files:
+  - ` + server.URL + `/latest/data.json
+  - ` + server.URL + `/static.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	lockPath := filepath.Join(tmpDir, "embed.lock")
+	lock, err := readLockFile(lockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []lockEntry{
+		{URL: server.URL + "/latest/data.json", Resolved: server.URL + "/v1/data.json", SHA256: sha256Hex([]byte("v1"))},
+		{URL: server.URL + "/static.json", SHA256: sha256Hex([]byte("static"))},
+	}
+	if len(lock.Files) != len(expected) {
+		t.Fatalf("lockfile entries = %+v, want %+v", lock.Files, expected)
+	}
+	for i := range expected {
+		if lock.Files[i] != expected[i] {
+			t.Errorf("lockfile entry %d = %+v, want %+v", i, lock.Files[i], expected[i])
+		}
+	}
+
+	// latest moves on, but a frozen run keeps downloading the pinned artifact
+	latest = "/v2/data.json"
+	before, _ := os.ReadFile(lockPath)
+	if err := run(tmpDir, options{frozen: true}, io.Discard); err != nil {
+		t.Fatalf("frozen run() error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "data.json")); string(data) != "v1" {
+		t.Errorf("frozen run embedded %q, want %q", data, "v1")
+	}
+	if after, _ := os.ReadFile(lockPath); string(after) != string(before) {
+		t.Errorf("frozen run changed the lockfile:\n%s", after)
+	}
+
+	// A regular run follows the redirect again and updates the lockfile
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if data, _ := os.ReadFile(lockPath); !strings.Contains(string(data), "resolved: "+server.URL+"/v2/data.json") {
+		t.Errorf("lockfile was not updated:\n%s", data)
+	}
+}
+
+func TestFrozenErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("changed"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  string
+		lock    string
+		wantErr string
+	}{
+		{
+			name:    "no lockfile configured",
+			config:  "go-mod: main\nfiles:\n  - " + server.URL + "/a.txt\n",
+			wantErr: "-frozen requires a lockfile",
+		},
+		{
+			name:    "missing lockfile",
+			config:  "go-mod: main\nlockfile: embed.lock\nfiles:\n  - " + server.URL + "/a.txt\n",
+			wantErr: "failed to read lockfile",
+		},
+		{
+			name:    "url not locked",
+			config:  "go-mod: main\nlockfile: embed.lock\nfiles:\n  - " + server.URL + "/a.txt\n",
+			lock:    "files: []\n",
+			wantErr: "is not in the lockfile",
+		},
+		{
+			name:    "checksum mismatch",
+			config:  "go-mod: main\nlockfile: embed.lock\nfiles:\n  - " + server.URL + "/a.txt\n",
+			lock:    "files:\n  - url: " + server.URL + "/a.txt\n    sha256: " + sha256Hex([]byte("original")) + "\n",
+			wantErr: "checksum mismatch",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			files := map[string]string{"embed.yaml": tt.config}
+			if tt.lock != "" {
+				files["embed.lock"] = tt.lock
+			}
+			writeTestFiles(t, tmpDir, files)
+			err := run(tmpDir, options{frozen: true}, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("run() error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "embed.go")); !os.IsNotExist(err) {
+				t.Errorf("embed.go was written despite the error")
+			}
+		})
+	}
+}
+
+func TestLockfileSameURLDifferentTransforms(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a\nb\n"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+lockfile: embed.lock
+files:
+  - ` + server.URL + `/data.txt
+  - source: ` + server.URL + `/data.txt
+    line-endings: crlf
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	lock, err := readLockFile(filepath.Join(tmpDir, "embed.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []lockEntry{
+		{URL: server.URL + "/data.txt", SHA256: sha256Hex([]byte("a\nb\n"))},
+		{URL: server.URL + "/data.txt", Transform: "line-endings: crlf", SHA256: sha256Hex([]byte("a\r\nb\r\n"))},
+	}
+	if len(lock.Files) != len(expected) {
+		t.Fatalf("lockfile entries = %+v, want %+v", lock.Files, expected)
+	}
+	for i := range expected {
+		if lock.Files[i] != expected[i] {
+			t.Errorf("lockfile entry %d = %+v, want %+v", i, lock.Files[i], expected[i])
+		}
+	}
+
+	// Each entry is verified against its own digest
+	if err := run(tmpDir, options{frozen: true}, io.Discard); err != nil {
+		t.Fatalf("frozen run() error: %v", err)
+	}
+}
@@ -2,60 +2,746 @@ package main
 
 import (
   "bufio"
+  "context"
+  "errors"
+  "flag"
   "fmt"
   "io"
+  "io/fs"
+  "maps"
   "net/http"
+  "net/url"
   "os"
+  "os/signal"
+  "path"
   "path/filepath"
+  "regexp"
+  "slices"
+  "strconv"
   "strings"
-  "gopkg.in/yaml.v3"
+  "sync"
+  "syscall"
+  "time"
+  "unicode"
 )
 
-var envVars = make(map[string]string)
+// envVars holds the variables of the .env file loaded last. envMu guards it, since generateInMemory may run concurrently
+var (
+  envMu   sync.RWMutex
+  envVars = make(map[string]string)
+)
+
+// errOverallTimeout marks a run aborted by overall-timeout; the process then exits with exitTimeout
+var errOverallTimeout = errors.New("generation timed out")
+
+// exitTimeout is the exit code for a run aborted by overall-timeout, as used by timeout(1)
+const exitTimeout = 124
+
+// options holds command-line flags
+type options struct {
+  diff    bool // print a diff of go-output instead of generating
+  changes bool // report which asset files changed
+  watch   bool // regenerate whenever the config or local sources change
+  config  string // path of the config file, embed.yaml by default
+  outputDir string // overrides output, relative to the working directory
+  all     bool // generate every embed.yaml found below the current directory
+  list    bool // print the resolved variables, embed paths and sources without generating
+  assetsOnly bool // only fetch the assets, as with go-output: none
+  only    string // comma-separated variable names or sources to refresh; the other files are kept as they are
+  printConfig bool // print the effective config as YAML instead of generating
+  strictEnv bool // fail when an environment variable referenced in the config is unset, as with strict-env
+  sample  string // number (N) or percentage (N%) of random remote files to check for reachability instead of generating
+  manifest string // path of a JSON or Markdown manifest of the assets written after generating, relative to the working directory
+  initFrom string // github-dir:// URL of a repository directory to write a new config from instead of generating
 
-type EmbedConfig struct {
-  GoOutput    string   `yaml:"go-output"`
-  Output      string   `yaml:"output"`
-  Files       []string `yaml:"files"`
-  GoMod       string   `yaml:"go-mod"`
-  GithubToken string   `yaml:"github-token"`
-  VarNaming   string   `yaml:"var-naming"` // "pascal" (default) or "snake"
+  werror  bool // turn warnings into errors
+  stderr  io.Writer // receives warnings; os.Stderr when nil
+  frozen  bool // download the URLs pinned in the lockfile and verify their checksums
+
+  // remoteCache, when set, keeps downloaded content by URL across runs (used by watch mode)
+  remoteCache *memoryCache
+  // lock collects (or, with frozen, provides) the lockfile entries of the current run
+  lock *lockFile
+  // previous, set with only, provides the files that are not refreshed
+  previous *previousOutput
+  // artifacts, set from artifact-dir, provides remote files downloaded by an earlier build
+  artifacts *artifactStore
+  // sink, when set, receives every file of the run instead of the filesystem
+  sink sink
+}
+
+// configFile returns the absolute path of the config file
+func (opts options) configFile(cwd string) string {
+  if opts.config == "" {
+    return filepath.Join(cwd, "embed.yaml")
+  }
+  return resolvePath(cwd, opts.config)
 }
 
 func main() {
-  // 1. Read embed.yaml in current directory (for use from examples/basic)
+  var opts options
+  flag.StringVar(&opts.config, "config", "", "path of the config file; relative paths inside it are resolved against its directory (default \"embed.yaml\")")
+  flag.StringVar(&opts.outputDir, "output-dir", "", "write the assets to this directory instead of the config's output (relative to the working directory; <short_name> is supported)")
+  flag.BoolVar(&opts.diff, "diff", false, "print a unified diff between the current go-output and the content that would be generated, without writing anything; only what go-output depends on is downloaded (see README)")
+  flag.BoolVar(&opts.changes, "changes", false, "print which asset files are new, changed or removed compared to the previous run")
+  flag.BoolVar(&opts.watch, "watch", false, "watch embed.yaml and local source files and regenerate on change until interrupted")
+  flag.BoolVar(&opts.list, "list", false, "print the variable name, embed path and source URL of every file without downloading or writing anything")
+  flag.BoolVar(&opts.assetsOnly, "assets-only", false, "download and copy the files into output without generating go-output, as with go-output: none")
+  flag.StringVar(&opts.only, "only", "", "comma-separated variable names or sources to re-fetch; every other file keeps its current content")
+  flag.StringVar(&opts.sample, "sample", "", "check that N (or N%) randomly chosen remote files are reachable with HEAD requests, as with preflight, and report the results without downloading or writing anything")
+  flag.BoolVar(&opts.all, "all", false, "discover every embed.yaml below the current directory (within the module) and generate each in place")
+  flag.BoolVar(&opts.frozen, "frozen", false, "download the resolved URLs recorded in the lockfile, fail on checksum mismatches and leave the lockfile unchanged")
+  flag.BoolVar(&opts.printConfig, "print-config", false, "print the effective config, with defaults applied, environment variables expanded and tokens redacted, as YAML without downloading or writing anything")
+  flag.BoolVar(&opts.strictEnv, "strict-env", false, "fail before downloading anything when an environment variable referenced in the config is unset or empty")
+  flag.StringVar(&opts.manifest, "manifest", "", "after generating, write the variable name, embed path and source of every file to this .json or .md file (relative to the working directory)")
+  flag.StringVar(&opts.initFrom, "init-from", "", "write a new embed.yaml (or -config) listing every file of a GitHub directory, given as github-dir://owner/repo/path@ref, without downloading them")
+  flag.BoolVar(&opts.werror, "Werror", false, "treat warnings (sanitized file names, empty files) as errors")
+  flag.Parse()
+
+  // Read embed.yaml in current directory (for use from examples/basic) unless -config is given
   cwd, _ := os.Getwd()
+  if opts.initFrom != "" {
+    if opts.all || opts.watch {
+      fmt.Fprintln(os.Stderr, "-init-from cannot be combined with -all or -watch")
+      os.Exit(2)
+    }
+    if err := initFrom(cwd, opts, os.Stdout); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    return
+  }
+  if opts.sample != "" && (opts.diff || opts.list || opts.watch || opts.only != "") {
+    fmt.Fprintln(os.Stderr, "-sample cannot be combined with -diff, -list, -watch or -only")
+    os.Exit(2)
+  }
+  if opts.manifest != "" && manifestFormat(opts.manifest) == "" {
+    fmt.Fprintf(os.Stderr, "-manifest %s must end in .json or .md\n", opts.manifest)
+    os.Exit(2)
+  }
+  if opts.all {
+    if opts.watch || opts.config != "" || opts.outputDir != "" || opts.only != "" || opts.manifest != "" {
+      fmt.Fprintln(os.Stderr, "-all cannot be combined with -watch, -config, -output-dir, -only or -manifest")
+      os.Exit(2)
+    }
+    if err := runAll(cwd, opts, os.Stdout); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(exitCode(err))
+    }
+    return
+  }
+  if opts.watch {
+    if opts.only != "" {
+      fmt.Fprintln(os.Stderr, "-watch cannot be combined with -only")
+      os.Exit(2)
+    }
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+    defer stop()
+    if err := watch(ctx, cwd, opts, os.Stdout, os.Stderr); err != nil {
+      fmt.Fprintln(os.Stderr, err)
+      os.Exit(1)
+    }
+    return
+  }
+  if err := run(cwd, opts, os.Stdout); err != nil {
+    fmt.Fprintln(os.Stderr, err)
+    os.Exit(exitCode(err))
+  }
+}
+
+// exitCode returns the process exit code for a failed run
+func exitCode(err error) int {
+  if errors.Is(err, errOverallTimeout) {
+    return exitTimeout
+  }
+  return 1
+}
 
-  // Load .env file if present
-  loadDotEnv(cwd)
+// run generates the embeds described by the config. Relative paths in the config,
+// local sources and .env are resolved against the directory the config lives in.
+func run(cwd string, opts options, stdout io.Writer) (err error) {
+  configPath := opts.configFile(cwd)
+  baseDir := filepath.Dir(configPath)
 
-  configPath := filepath.Join(cwd, "embed.yaml")
-  if _, err := os.Stat(configPath); os.IsNotExist(err) {
-    fmt.Fprintln(os.Stderr, "embed.yaml not found in current directory")
-    os.Exit(1)
+  // 1. Load .env file if present and read the config
+  loadDotEnv(baseDir)
+
+  cfg, err := loadConfig(configPath)
+  if err != nil {
+    return err
+  }
+  cfg.warn = warner{werror: opts.werror, out: opts.stderr}
+  if opts.outputDir != "" {
+    // output is relative to the config directory, the flag to the working directory
+    if cfg.Output, err = filepath.Rel(baseDir, resolvePath(cwd, opts.outputDir)); err != nil {
+      return fmt.Errorf("invalid -output-dir %s: %v", opts.outputDir, err)
+    }
+  }
+  // Unset variables expand to nothing, turning https://$HOST/config.xml into a confusing 404
+  if opts.strictEnv || cfg.StrictEnv {
+    if unset := cfg.unsetEnvVars(); len(unset) > 0 {
+      return fmt.Errorf("environment variables referenced in %s are not set: %s", filepath.Base(configPath), strings.Join(unset, ", "))
+    }
+  }
+  if opts.assetsOnly {
+    cfg.GoOutput = goOutputNone
+    cfg.ManifestGo = ""
+  }
+  if opts.printConfig {
+    return printConfig(stdout, cfg)
+  }
+  if opts.diff && cfg.assetsOnly() {
+    return fmt.Errorf("-diff compares go-output, which is not generated with go-output: none or -assets-only")
+  }
+
+  client := newHTTPClient(cfg.maxRedirects(), cfg.AllowedHosts, cfg.tlsMinVersion())
+  ctx := context.Background()
+  if cfg.OverallTimeout > 0 {
+    var cancel context.CancelFunc
+    ctx, cancel = context.WithTimeout(ctx, cfg.OverallTimeout)
+    defer cancel()
+    base := client.Transport
+    if base == nil {
+      base = http.DefaultTransport
+    }
+    client.Transport = deadlineTransport{ctx: ctx, base: base}
+    defer func() {
+      if err != nil && ctx.Err() == context.DeadlineExceeded {
+        err = fmt.Errorf("%w (overall-timeout: %s): %w", errOverallTimeout, cfg.OverallTimeout, err)
+      }
+    }()
+  }
+
+  // 2. Resolve destinations and variable names. Index entries list their files from the index page,
+  // except under -list, which makes no requests and shows the index entries themselves
+  if opts.list {
+    listed, indexes := withoutIndexes(cfg)
+    assets, err := planAssets(baseDir, listed)
+    if err != nil {
+      return err
+    }
+    if err := listAssets(stdout, assets, indexes); err != nil {
+      return err
+    }
+    return checkLocalSources(baseDir, listed, assets)
+  }
+  if err := listIndexes(client, cfg); err != nil {
+    return err
+  }
+  assets, err := planAssets(baseDir, cfg)
+  if err != nil {
+    return err
+  }
+  if err := checkAllowedHosts(cfg, assets); err != nil {
+    return err
   }
-  configData, err := os.ReadFile(configPath)
+  if opts.sample != "" {
+    return sampleAssets(stdout, client, cfg, assets, opts.sample)
+  }
+  // Without Go code there is no package to detect
+  pkgName := ""
+  if !cfg.assetsOnly() {
+    if pkgName, err = detectPackageName(baseDir, cfg); err != nil {
+      return err
+    }
+    if err := checkInitCheckModule(baseDir, cfg, assets); err != nil {
+      return err
+    }
+  }
+  embedGoPath := filepath.Join(baseDir, cfg.GoOutput)
+  lockPath := ""
+  if cfg.Lockfile != "" {
+    lockPath = resolvePath(baseDir, cfg.Lockfile)
+  }
+  switch {
+  case opts.frozen && lockPath == "":
+    return fmt.Errorf("-frozen requires a lockfile to be configured")
+  case opts.frozen:
+    if opts.lock, err = readLockFile(lockPath); err != nil {
+      return err
+    }
+  case lockPath != "":
+    opts.lock = &lockFile{}
+  }
+  if cfg.ArtifactDir != "" {
+    if opts.artifacts, err = newArtifactStore(resolvePath(baseDir, cfg.ArtifactDir), lockPath); err != nil {
+      return err
+    }
+  }
+  if opts.only != "" {
+    if lockPath == "" {
+      return fmt.Errorf("-only requires a lockfile to be configured, which keeps what the previous run generated")
+    }
+    if err := checkOnly(assets, onlyNames(opts.only)); err != nil {
+      return err
+    }
+    if opts.previous, err = readPreviousOutput(lockPath); err != nil {
+      return err
+    }
+  }
+
+  // SOURCE_DATE_EPOCH pins the mtime of everything written for reproducible builds
+  epoch, hasEpoch, err := sourceDateEpoch()
   if err != nil {
-    fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", configPath, err)
-    os.Exit(1)
+    return err
   }
-  var cfg EmbedConfig
-  if err := yaml.Unmarshal(configData, &cfg); err != nil {
-    fmt.Fprintf(os.Stderr, "failed to parse %s: %v\n", configPath, err)
-    os.Exit(1)
+
+  if cfg.GitHubCommits {
+    if err := resolveGitHubCommits(client, cfg, assets); err != nil {
+      return err
+    }
   }
-  if cfg.GoOutput == "" {
-    cfg.GoOutput = "embed.go"
+
+  if opts.diff {
+    // Literal assets, modification times, sizes (always generated for modules) and automatic var types are part of embed.go itself,
+    // so they are needed to render it
+    for i, a := range assets {
+      if !a.entry.Literal && !cfg.ModTimes && !cfg.Sizes && !a.wasm && cfg.varType(a.entry) != "auto" {
+        continue
+      }
+      f, err := fetchAsset(client, cfg, opts, baseDir, a)
+      if err != nil {
+        return err
+      }
+      assets[i].content = f.data
+      assets[i].modTime = clampModTime(f.modTime, epoch, hasEpoch)
+      assets[i].size = len(f.data)
+      if cfg.varType(a.entry) == "auto" {
+        assets[i].bytes = isBinary(f.data)
+      }
+    }
+    embedGos, err := generateEmbedGoFiles(pkgName, assets, cfg)
+    if err != nil {
+      return err
+    }
+    updates := map[string]string{}
+    for _, p := range previousGoOutputs(embedGoPath) {
+      updates[p] = ""
+    }
+    for i, p := range cfg.goOutputFiles(baseDir, len(assets)) {
+      updates[p] = embedGos[i]
+    }
+    for _, p := range slices.Sorted(maps.Keys(updates)) {
+      current, err := os.ReadFile(p)
+      if err != nil && !os.IsNotExist(err) {
+        return fmt.Errorf("failed to read %s: %v", p, err)
+      }
+      rel, _ := filepath.Rel(baseDir, p)
+      name := filepath.ToSlash(rel)
+      fmt.Fprint(stdout, unifiedDiff("a/"+name, "b/"+name, string(current), updates[p]))
+    }
+    return nil
   }
-  if cfg.GithubToken != "" {
-    cfg.GithubToken = expandEnvVars(cfg.GithubToken)
+
+  if cfg.Preflight {
+    if err := preflight(client, cfg, assets); err != nil {
+      return err
+    }
   }
-  if len(cfg.Files) == 0 {
-    fmt.Fprintln(os.Stderr, "No files specified in embed.yaml")
-    os.Exit(1)
+
+  // 3. Fetch every file into the sink's staging area first, so a failure leaves the working tree untouched
+  out := opts.sink
+  if out == nil {
+    var store *contentStore
+    if cfg.CacheDir != "" {
+      store = &contentStore{dir: resolvePath(baseDir, cfg.CacheDir)}
+    }
+    disk, err := newDiskSink(baseDir, store, epoch, hasEpoch)
+    if err != nil {
+      return err
+    }
+    out = disk
   }
+  defer out.close()
 
-  // 2. Download files and write to output dir (relative to cwd)
+  var report changeReport
+  var validator *schemaValidator
+  fetched, err := fetchAll(ctx, client, cfg, opts, baseDir, assets)
+  if err != nil {
+    return err
+  }
+  // Results are processed in config order. Followed source maps are appended to assets while iterating
+  // and fetched here one by one
+  for i := 0; i < len(assets); i++ {
+    a := assets[i]
+    var f sourceFile
+    if i < len(fetched) {
+      f = fetched[i]
+    } else {
+      if err := ctx.Err(); err != nil {
+        return err
+      }
+      if f, err = fetchAsset(client, cfg, opts, baseDir, a); err != nil {
+        return err
+      }
+    }
+    if opts.lock != nil && !opts.frozen && !a.entry.unpinned && a.entry.isRemote(a.expandedURL) {
+      opts.lock.add(a.expandedURL, cfg.lockTransform(a.entry), f.resolved, f.data)
+    }
+    data := f.data
+    assets[i].modTime = clampModTime(f.modTime, epoch, hasEpoch)
+    if opts.lock != nil && !opts.frozen {
+      if err := opts.lock.addAsset(cfg, assets[i], data); err != nil {
+        return err
+      }
+    }
+    assets[i].size = len(data)
+    if cfg.varType(a.entry) == "auto" {
+      assets[i].bytes = isBinary(data)
+    }
+    if len(data) == 0 {
+      if err := cfg.warn.warnf("%s is empty", a.expandedURL); err != nil {
+        return err
+      }
+    }
+    if a.entry.Schema != "" {
+      if validator == nil {
+        validator = newSchemaValidator(client, cfg, baseDir)
+      }
+      if err := validator.validate(a.entry.Schema, a.uniquePath, data); err != nil {
+        return err
+      }
+    }
+    if cfg.FollowSourcemaps {
+      if assets, err = followSourceMap(cfg, assets, a, data); err != nil {
+        return err
+      }
+    }
+    if a.entry.Literal {
+      assets[i].content = data
+      continue
+    }
+    if opts.changes {
+      name, _ := filepath.Rel(baseDir, a.localFile)
+      report.track(filepath.ToSlash(name), a.localFile, data)
+    }
+    if err := out.stage(i, a, data); err != nil {
+      return err
+    }
+  }
+
+  if err := ctx.Err(); err != nil {
+    return err
+  }
+
+  // 4. Commit the staged files to the output dir (relative to the config directory) and render embed.go
+  if err := out.commit(assets); err != nil {
+    return err
+  }
+
+  if lockPath != "" && !opts.frozen {
+    data, err := opts.lock.marshal()
+    if err != nil {
+      return err
+    }
+    if err := out.write(lockPath, data); err != nil {
+      return err
+    }
+  }
+  if cfg.assetsOnly() {
+    if opts.changes {
+      report.print(stdout)
+    }
+    return nil
+  }
+  embedGos, err := generateEmbedGoFiles(pkgName, assets, cfg)
+  if err != nil {
+    return err
+  }
+  previousGoFiles := previousGoOutputs(embedGoPath)
+  if opts.changes {
+    report.trackRemoved(baseDir, previousGoFiles, assets)
+  }
+  goFiles := cfg.goOutputFiles(baseDir, len(assets))
+  for i, p := range goFiles {
+    if err := out.write(p, []byte(embedGos[i])); err != nil {
+      return err
+    }
+  }
+  // Files left from a different max-vars-per-file would declare the variables a second time
+  for _, p := range previousGoFiles {
+    if !slices.Contains(goFiles, p) {
+      if err := out.remove(p); err != nil {
+        return err
+      }
+    }
+  }
+  if cfg.ManifestGo != "" {
+    generatedAt := time.Now()
+    if hasEpoch {
+      generatedAt = epoch
+    }
+    manifest, err := generateManifestGo(pkgName, assets, generatedAt)
+    if err != nil {
+      return err
+    }
+    if err := out.write(filepath.Join(filepath.Dir(embedGoPath), cfg.ManifestGo), []byte(manifest)); err != nil {
+      return err
+    }
+  }
+  if opts.manifest != "" {
+    manifest, err := renderAssetManifest(manifestFormat(opts.manifest), assets)
+    if err != nil {
+      return err
+    }
+    if err := out.write(resolvePath(cwd, opts.manifest), manifest); err != nil {
+      return err
+    }
+  }
+  if opts.changes {
+    report.print(stdout)
+  }
+  return nil
+}
+
+// fetchAsset downloads or reads the content of an asset and applies its transcoding and text normalization.
+// With -frozen, remote content is verified against the lockfile, and with -only the files it does not name
+// keep the content of the previous run. It is safe for concurrent use
+func fetchAsset(client *http.Client, cfg *EmbedConfig, opts options, baseDir string, a asset) (sourceFile, error) {
+  if opts.previous != nil && !matchesOnly(a, onlyNames(opts.only)) {
+    return opts.previous.keptFile(cfg, a)
+  }
+  if a.entry.Merge != nil {
+    return fetchMerged(client, cfg, opts, baseDir, a)
+  }
+  if !a.entry.isRemote(a.expandedURL) {
+    var data []byte
+    var err error
+    localPath := ""
+    if a.entry.isDataURI(a.expandedURL) {
+      if data, err = decodeDataURI(a.expandedURL); err != nil {
+        return sourceFile{}, fmt.Errorf("%s: %v", a.uniquePath, err)
+      }
+    } else {
+      localPath = resolvePath(baseDir, a.expandedURL)
+      if data, err = readLocalFile(localPath); err != nil {
+        return sourceFile{}, err
+      }
+    }
+    if data, err = transformContent(cfg, baseDir, a, data); err != nil {
+      return sourceFile{}, err
+    }
+    if a.entry.checksum != nil {
+      if err := a.entry.checksum.verify(data); err != nil {
+        return sourceFile{}, fmt.Errorf("%s: %v", a.expandedURL, err)
+      }
+    }
+    f := sourceFile{data: data}
+    if localPath != "" {
+      if info, err := os.Stat(localPath); err == nil {
+        f.modTime = info.ModTime()
+      }
+    }
+    return f, nil
+  }
+
+  fetchOpts, err := assetFetchOptions(cfg, a)
+  if err != nil {
+    return sourceFile{}, err
+  }
+  sources := append([]string{a.expandedURL}, a.mirrors()...)
+  var locked lockEntry
+  frozen := opts.frozen && !a.entry.unpinned
+  if frozen {
+    var ok bool
+    if locked, ok = opts.lock.lookup(a.expandedURL, cfg.lockTransform(a.entry)); !ok {
+      return sourceFile{}, fmt.Errorf("%s is not in the lockfile (run without -frozen to update it)", a.expandedURL)
+    }
+    if locked.Resolved != "" {
+      if err := checkAllowedHost(locked.Resolved, cfg.AllowedHosts); err != nil {
+        return sourceFile{}, err
+      }
+      sources = []string{locked.Resolved}
+    }
+  }
+  if f, ok := opts.artifacts.lookup(cfg, a); ok {
+    return f, nil
+  }
+
+  // Try the source, then each mirror, until one serves content matching the checksum
+  var failures []error
+  for _, src := range sources {
+    f, err := fetchSource(client, cfg, opts, baseDir, a, src, fetchOpts)
+    if err == nil && frozen {
+      if sum := sha256Hex(f.data); sum != locked.SHA256 {
+        err = fmt.Errorf("checksum mismatch for %s: lockfile has sha256 %s, downloaded %s", src, locked.SHA256, sum)
+      }
+    }
+    if err != nil {
+      if len(sources) == 1 {
+        return sourceFile{}, err
+      }
+      failures = append(failures, err)
+      continue
+    }
+    return f, nil
+  }
+  // The status is that of the last attempt that got a response
+  status := 0
+  for _, err := range failures {
+    var dlErr *downloadError
+    if errors.As(err, &dlErr) && dlErr.statusCode != 0 {
+      status = dlErr.statusCode
+    }
+  }
+  return sourceFile{}, &downloadError{url: a.expandedURL, statusCode: status, attempts: len(sources), err: errors.Join(failures...)}
+}
+
+// fetchSource downloads one candidate URL of an asset, verifies its signature, transforms it and verifies its checksum
+func fetchSource(client *http.Client, cfg *EmbedConfig, opts options, baseDir string, a asset, src string, fetchOpts fetchOptions) (sourceFile, error) {
+  // A segment of a file is not the file
+  cacheKey := src
+  if a.entry.Range != "" {
+    cacheKey += " " + a.entry.Range
+  }
+  f, ok := opts.remoteCache.get(cacheKey)
+  if !ok {
+    var err error
+    // Mirrors are plain URLs even when the source goes through the GitHub API
+    switch {
+    case a.githubAPI != nil && src == a.expandedURL:
+      f, err = fetchGitHubAPI(client, a.githubAPI, fetchOpts)
+    case cfg.HeadCheck && !a.entry.Literal && a.entry.Range == "" && !cfg.rewritesContent(a.entry):
+      // Sizes are only comparable when the output holds the downloaded bytes unchanged
+      var unchanged bool
+      if f, unchanged = headUnchanged(client, src, a.localFile, fetchOpts); !unchanged {
+        f, err = fetchRemote(client, src, fetchOpts)
+      }
+    default:
+      f, err = fetchRemote(client, src, fetchOpts)
+    }
+    if err != nil {
+      return sourceFile{}, err
+    }
+    opts.remoteCache.put(cacheKey, f)
+  }
+  // The signature covers the file as published, before it is transformed
+  if a.entry.publicKey != nil {
+    if err := verifySignature(client, a, f.data, fetchOpts); err != nil {
+      return sourceFile{}, err
+    }
+  }
+  data, err := transformContent(cfg, baseDir, a, f.data)
+  if err != nil {
+    return sourceFile{}, err
+  }
+  if a.entry.checksum != nil {
+    if err := a.entry.checksum.verify(data); err != nil {
+      return sourceFile{}, fmt.Errorf("%s: %v", src, err)
+    }
+  }
+  f.data = data
+  return f, nil
+}
+
+// transformContent converts the raw content of an asset to UTF-8 when it declares an encoding, strips its byte order mark,
+// pipes it through its pipe command, rewrites its URL references, normalizes its line endings and trailing newline,
+// checks it parses as its validate format, and finally applies its embed-encoding. A WebAssembly module only has its header checked before encoding
+func transformContent(cfg *EmbedConfig, baseDir string, a asset, data []byte) ([]byte, error) {
+  // A module is binary, so none of the text transforms apply, and it has to be one
+  if a.wasm {
+    if err := checkWasm(data); err != nil {
+      return nil, fmt.Errorf("%s: %v", a.expandedURL, err)
+    }
+    return encodeContent(data, a.entry.EmbedEncoding), nil
+  }
+  data, err := toUTF8(data, a.entry.Encoding)
+  if err != nil {
+    return nil, fmt.Errorf("failed to decode %s as %s: %v", a.expandedURL, a.entry.Encoding, err)
+  }
+  if cfg.stripBOM(a.entry) {
+    data = stripBOM(data)
+  }
+  if a.entry.Pipe != "" {
+    if data, err = pipeContent(baseDir, a.entry.Pipe, data); err != nil {
+      return nil, fmt.Errorf("%s: %v", a.expandedURL, err)
+    }
+  }
+  data = rewriteURLs(data, a.entry.RewriteURLs)
+  lineEndings, trailingNewline := cfg.normalizeOptions(a.entry)
+  data = normalizeText(data, lineEndings, trailingNewline)
+  if err := checkFormat(a.entry.Validate, data); err != nil {
+    return nil, fmt.Errorf("%s: %v", a.expandedURL, err)
+  }
+  return encodeContent(data, a.entry.EmbedEncoding), nil
+}
+
+// clampModTime limits a source modification time to SOURCE_DATE_EPOCH, when set,
+// so the generated file stays reproducible
+func clampModTime(t, epoch time.Time, hasEpoch bool) time.Time {
+  if hasEpoch && t.After(epoch) {
+    return epoch
+  }
+  return t
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames it into place
+func writeFileAtomic(path string, data []byte) error {
+  f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-")
+  if err != nil {
+    return fmt.Errorf("failed to write %s: %v", path, err)
+  }
+  defer os.Remove(f.Name())
+  _, err = f.Write(data)
+  if closeErr := f.Close(); err == nil {
+    err = closeErr
+  }
+  if err == nil {
+    err = os.Chmod(f.Name(), 0644)
+  }
+  if err == nil {
+    err = os.Rename(f.Name(), path)
+  }
+  if err != nil {
+    return fmt.Errorf("failed to write %s: %v", path, err)
+  }
+  return nil
+}
+
+// asset is a single file to embed with its resolved destination and variable name
+type asset struct {
+  fileInfo
+  uniquePath   string
+  localFile    string // absolute destination path
+  relEmbedPath string // path used in the //go:embed directive
+  varName      string
+  content      []byte    // data of a literal asset, rendered into embed.go itself
+  modTime      time.Time // last modification of the source, rendered with mod-times
+  size         int       // length of the embedded content, rendered with sizes
+  commit       string    // SHA of the last commit that changed a GitHub file, rendered with github-commits
+  wasm         bool      // a .wasm module: checked, declared as []byte and given a size constant
+  bytes        bool      // the variable is a []byte instead of a string
+  typeName     string    // named type of the variable, with goType as its underlying type
+}
+
+// goType returns the Go type of the asset's variable, or the underlying type of its type-name
+func (a asset) goType() string {
+  if a.bytes {
+    return "[]byte"
+  }
+  return "string"
+}
+
+// stringValue returns a Go expression for the content of the asset's variable as a string
+func (a asset) stringValue() string {
+  if a.bytes || a.typeName != "" {
+    return "string(" + a.varName + ")"
+  }
+  return a.varName
+}
+
+// mirrors returns the asset's mirror URLs with environment variables expanded
+func (a asset) mirrors() []string {
+  mirrors := make([]string, len(a.entry.Mirrors))
+  for i, m := range a.entry.Mirrors {
+    mirrors[i] = expandEnvVars(m)
+  }
+  return mirrors
+}
+
+// planAssets expands the configured files and resolves where each one is written
+// and how it is embedded, without touching the network or the filesystem
+func planAssets(baseDir string, cfg *EmbedConfig) ([]asset, error) {
   outDir := cfg.Output
   if outDir == "" {
     outDir = "."
@@ -64,46 +750,41 @@ func main() {
   // First, expand all file URLs and extract source paths
   var fileInfos []fileInfo
 
-  for _, fileURL := range cfg.Files {
-    expandedURL := expandEnvVars(fileURL)
-    var sourcePath, shortName string
-
-    if strings.HasPrefix(expandedURL, "http://") || strings.HasPrefix(expandedURL, "https://") {
-      // For URLs, extract path after the domain
-      parts := strings.Split(expandedURL, "/")
-      shortName = parts[len(parts)-1]
-      // Use path parts after protocol and domain (skip first 3: "", "", "domain")
-      if len(parts) > 3 {
-        sourcePath = strings.Join(parts[3:], "/")
-      } else {
-        sourcePath = shortName
-      }
-    } else {
-      // For local files, use the file path
-      shortName = filepath.Base(expandedURL)
-      sourcePath = filepath.ToSlash(expandedURL)
+  for i := range cfg.Files {
+    infos, err := expandEntry(baseDir, cfg, &cfg.Files[i])
+    if err != nil {
+      return nil, err
     }
-
-    fileInfos = append(fileInfos, fileInfo{
-      originalURL: fileURL,
-      expandedURL: expandedURL,
-      sourcePath:  sourcePath,
-      shortName:   shortName,
-    })
+    fileInfos = append(fileInfos, infos...)
   }
 
   // Calculate unique relative paths for each file
-  uniquePaths := resolveUniquePaths(fileInfos)
-
-  // Now download/copy files using the unique paths
-  type embedInfo struct {
-    relEmbedPath string
-    uniquePath   string
+  suffixed := cfg.CollisionStrategy == "suffix"
+  var uniquePaths []string
+  if suffixed {
+    uniquePaths = resolveSuffixedPaths(fileInfos)
+  } else {
+    uniquePaths = resolveUniquePaths(fileInfos)
   }
-  var embedInfos []embedInfo
 
+  var assets []asset
+  var hidden []string
   for i, fi := range fileInfos {
     uniquePath := uniquePaths[i]
+    // Files from a directory tree keep at least their structure below the tree root, unless everything is flattened
+    if !suffixed && fi.treePath != "" && strings.Count(fi.treePath, "/") > strings.Count(uniquePath, "/") {
+      uniquePath = fi.treePath
+    }
+    // A numbered name replaces the file name itself
+    fi.shortName = path.Base(uniquePath)
+    // Destination names must be valid for go:embed
+    if sanitized := sanitizeEmbedPath(uniquePath); sanitized != uniquePath {
+      if err := cfg.warn.warnf("renamed %q to %q: the name contains characters that go:embed does not allow", uniquePath, sanitized); err != nil {
+        return nil, err
+      }
+      uniquePath = sanitized
+      fi.shortName = path.Base(sanitized)
+    }
     outPath := strings.ReplaceAll(outDir, "<short_name>", strings.TrimSuffix(fi.shortName, filepath.Ext(fi.shortName)))
 
     // Build the full output path including unique subdirectories
@@ -115,154 +796,393 @@ func main() {
       fullOutPath = outPath
     }
 
-    absOutPath := filepath.Join(cwd, fullOutPath)
-    if err := os.MkdirAll(absOutPath, 0755); err != nil {
-      fmt.Fprintf(os.Stderr, "failed to create dir %s: %v\n", absOutPath, err)
-      os.Exit(1)
-    }
-
-    localFile := filepath.Join(absOutPath, fi.shortName)
-
-    if strings.HasPrefix(fi.expandedURL, "http://") || strings.HasPrefix(fi.expandedURL, "https://") {
-      req, err := http.NewRequest("GET", fi.expandedURL, nil)
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to create request for %s: %v\n", fi.expandedURL, err)
-        os.Exit(1)
-      }
-      if cfg.GithubToken != "" && (strings.Contains(fi.expandedURL, "github.com") || strings.Contains(fi.expandedURL, "githubusercontent.com")) {
-        req.Header.Set("Authorization", "Bearer "+cfg.GithubToken)
-      }
-      resp, err := http.DefaultClient.Do(req)
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to download %s: %v\n", fi.expandedURL, err)
-        os.Exit(1)
-      }
-      defer resp.Body.Close()
-      if resp.StatusCode != 200 {
-        fmt.Fprintf(os.Stderr, "failed to download %s: %s\n", fi.expandedURL, resp.Status)
-        os.Exit(1)
-      }
-      f, err := os.Create(localFile)
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to create file %s: %v\n", localFile, err)
-        os.Exit(1)
-      }
-      _, err = io.Copy(f, resp.Body)
-      f.Close()
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to write file %s: %v\n", localFile, err)
-        os.Exit(1)
-      }
-    } else {
-      srcFile := filepath.Join(cwd, fi.expandedURL)
-      src, err := os.Open(srcFile)
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to open source file %s: %v\n", srcFile, err)
-        os.Exit(1)
-      }
-      defer src.Close()
-      dst, err := os.Create(localFile)
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to create destination file %s: %v\n", localFile, err)
-        os.Exit(1)
-      }
-      _, err = io.Copy(dst, src)
-      dst.Close()
-      if err != nil {
-        fmt.Fprintf(os.Stderr, "failed to copy file to %s: %v\n", localFile, err)
-        os.Exit(1)
-      }
-    }
-
     // Calculate relative embed path
     fullPath := filepath.Join(fullOutPath, fi.shortName)
+    // Names come from URLs and index pages, so they must not climb out of output, whatever is done with go-output
+    if rel, err := filepath.Rel(outPath, fullPath); err != nil || rel == ".." || strings.HasPrefix(filepath.ToSlash(rel), "../") {
+      return nil, fmt.Errorf("%s: %s is outside output %s", fi.originalURL, filepath.ToSlash(fullPath), filepath.ToSlash(outPath))
+    }
     goOutputDir := filepath.Dir(cfg.GoOutput)
     relEmbedPath := fullPath
     if goOutputDir != "." && goOutputDir != "" {
       relEmbedPath, _ = filepath.Rel(goOutputDir, fullPath)
     }
-    relEmbedPath = filepath.ToSlash(relEmbedPath)
-    embedInfos = append(embedInfos, embedInfo{relEmbedPath: relEmbedPath, uniquePath: uniquePath})
-  }
+    if cfg.assetsOnly() && fi.entry.Literal {
+      return nil, fmt.Errorf("%s: literal assets are rendered into go-output and cannot be used with go-output: none or -assets-only", fi.originalURL)
+    }
+    if cfg.assetsOnly() && isHiddenFromEmbed(uniquePath) {
+      hidden = append(hidden, uniquePath)
+    }
+    // go:embed only reaches files in the package directory and below it. Without go-output or with mode: path-only
+    // nothing embeds them
+    if !fi.entry.Literal && !cfg.assetsOnly() && !cfg.pathOnly() && (relEmbedPath == ".." || strings.HasPrefix(filepath.ToSlash(relEmbedPath), "../")) {
+      return nil, fmt.Errorf("%s is outside %s, the directory of go-output: put output below it", filepath.ToSlash(fullPath), filepath.ToSlash(goOutputDir))
+    }
+
+    // An asset written over a generated file would be replaced by it, or embed stale generated content
+    if !fi.entry.Literal && cfg.isGenerated(baseDir, filepath.Join(baseDir, fullPath)) {
+      return nil, fmt.Errorf("%s would be written to %s, which is also generated by remoteembed: move go-output out of output or rename the file", fi.originalURL, filepath.ToSlash(fullPath))
+    }
 
-  // Generate variable names from unique paths
-  var embedVars []string
-  for _, info := range embedInfos {
-    varName := toPascalCase(strings.TrimSuffix(info.uniquePath, filepath.Ext(info.uniquePath)))
+    // Modules are binary, unless embed-encoding turned them into text
+    wasm := isWasmName(fi.shortName)
+    encoded := fi.entry.EmbedEncoding == "hex" || fi.entry.EmbedEncoding == "base64"
+    if wasm {
+      if err := checkWasmEntry(fi.entry, encoded); err != nil {
+        return nil, fmt.Errorf("%s: %v", fi.originalURL, err)
+      }
+    }
+
+    // Generate variable names from unique paths
+    varName := toPascalCase(trimExt(uniquePath))
     if cfg.VarNaming == "snake" {
-      varName = toGoVarName(info.uniquePath, "snake")
+      varName = toGoVarName(uniquePath, "snake")
     }
-    embedVars = append(embedVars, fmt.Sprintf("//go:embed %s\nvar %s string\n", info.relEmbedPath, varName))
+
+    assets = append(assets, asset{
+      fileInfo:     fi,
+      uniquePath:   uniquePath,
+      localFile:    filepath.Join(baseDir, fullPath),
+      relEmbedPath: filepath.ToSlash(relEmbedPath),
+      varName:      varName,
+      bytes:        cfg.varType(fi.entry) == "bytes" || wasm && !encoded,
+      typeName:     cfg.typeName(fi.entry),
+      wasm:         wasm,
+    })
+  }
+  // The generated directives name every file, which embeds hidden ones too, but a hand-written
+  // //go:embed of the output directory silently leaves them out
+  if len(hidden) > 0 {
+    if err := cfg.warn.warnf("%s: a //go:embed of the output directory skips names starting with . or _, embed it with the all: prefix (//go:embed all:%s)", strings.Join(hidden, ", "), filepath.ToSlash(outDir)); err != nil {
+      return nil, err
+    }
+  }
+  aliased, err := applyAliases(cfg.Aliases, assets)
+  if err != nil {
+    return nil, err
   }
+  if err := numberVarNames(assets, aliased); err != nil {
+    return nil, err
+  }
+  return assets, nil
+}
 
-  // 3. Detect package name
-  pkgName := "main"
-  if strings.TrimSpace(cfg.GoMod) != "" {
-    pkgName = strings.TrimSpace(cfg.GoMod)
-  } else {
-    // Try go.mod first
-    gomodPath := filepath.Join(cwd, "go.mod")
-    if data, err := os.ReadFile(gomodPath); err == nil {
-      lines := strings.Split(string(data), "\n")
-      for _, l := range lines {
-        l = strings.TrimSpace(l)
-        if strings.HasPrefix(l, "module ") {
-          parts := strings.Split(l, "/")
-          pkgName = parts[len(parts)-1]
-          pkgName = strings.ReplaceAll(pkgName, "-", "_")
-          break
-        }
+// isHiddenFromEmbed reports whether a directory pattern in go:embed skips p, a slash-separated path below the
+// embedded directory, because one of its elements starts with . or _
+func isHiddenFromEmbed(p string) bool {
+  for _, elem := range strings.Split(p, "/") {
+    if strings.HasPrefix(elem, ".") || strings.HasPrefix(elem, "_") {
+      return true
+    }
+  }
+  return false
+}
+
+// applyAliases renames the assets whose source is a key of aliases and returns the indices of the renamed ones.
+// A key matches the source as written in files, or the expanded source of a single file. Every alias has to match.
+func applyAliases(aliases map[string]string, assets []asset) (map[int]bool, error) {
+  aliased := map[int]bool{}
+  if len(aliases) == 0 {
+    return aliased, nil
+  }
+  matched := map[string]bool{}
+  for i := range assets {
+    a := &assets[i]
+    key := filepath.ToSlash(a.expandedURL)
+    name, ok := aliases[key]
+    if !ok && a.treePath == "" {
+      key = a.originalURL
+      name, ok = aliases[key]
+    }
+    if ok {
+      a.varName = name
+      matched[key] = true
+      aliased[i] = true
+    }
+  }
+  var unmatched []string
+  for source := range aliases {
+    if !matched[source] {
+      unmatched = append(unmatched, source)
+    }
+  }
+  if len(unmatched) > 0 {
+    slices.Sort(unmatched)
+    return nil, fmt.Errorf("aliases do not match any file: %s", strings.Join(unmatched, ", "))
+  }
+  return aliased, nil
+}
+
+// numberVarNames makes the generated variable names unique. Paths that only differ in punctuation, such as
+// api-v1.json and api_v1.json, convert to the same name, which is then numbered like colliding paths.
+// Aliased names are kept as they are, so it is an error when one of them is taken by another asset
+func numberVarNames(assets []asset, aliased map[int]bool) error {
+  var names, keys []string
+  var indices []int
+  for i, a := range assets {
+    if !aliased[i] {
+      names = append(names, a.varName)
+      keys = append(keys, a.uniquePath)
+      indices = append(indices, i)
+    }
+  }
+  numberCollisions(names, keys)
+  for j, i := range indices {
+    assets[i].varName = names[j]
+  }
+  owners := map[string]string{}
+  for _, a := range assets {
+    if other, ok := owners[a.varName]; ok {
+      return fmt.Errorf("%s and %s would both be named %s: change aliases", other, a.expandedURL, a.varName)
+    }
+    owners[a.varName] = a.expandedURL
+  }
+  return nil
+}
+
+// expandEntry turns a config file entry into the files it refers to.
+// A list entry expands to each source listed in its variable in turn.
+func expandEntry(baseDir string, cfg *EmbedConfig, entry *FileEntry) ([]fileInfo, error) {
+  if entry.Index != "" {
+    return expandIndex(baseDir, cfg, entry)
+  }
+  if entry.Merge != nil {
+    return expandMerge(entry), nil
+  }
+  if entry.isDataURI(entry.Source) {
+    // The payload is used as written, so a $ in it is not an environment variable
+    name := strings.Join(pathSegments(entry.Name), "/")
+    return []fileInfo{{originalURL: entry.Source, expandedURL: entry.Source, sourcePath: name, shortName: path.Base(name), entry: entry}}, nil
+  }
+  if !entry.List {
+    return expandSource(baseDir, cfg, entry, entry.Source, expandEnvVars(entry.Source))
+  }
+  items := envFileList(entry.Source)
+  if len(items) == 0 {
+    return nil, fmt.Errorf("%s lists no files: the variable is unset or empty", entry.Source)
+  }
+  var infos []fileInfo
+  for _, item := range items {
+    itemInfos, err := expandSource(baseDir, cfg, entry, item, item)
+    if err != nil {
+      return nil, err
+    }
+    infos = append(infos, itemInfos...)
+  }
+  return infos, nil
+}
+
+// envListPattern matches a source that is nothing but one $VAR or ${VAR} reference, as list requires
+var envListPattern = regexp.MustCompile(`^\$(?:\{([A-Za-z_][A-Za-z0-9_]*)\}|([A-Za-z_][A-Za-z0-9_]*))$`)
+
+// envFileList returns the sources listed in the value of source, a single variable reference,
+// separated by newlines or commas. Items are trimmed and empty items are dropped
+func envFileList(source string) []string {
+  m := envListPattern.FindStringSubmatch(strings.TrimSpace(source))
+  if m == nil {
+    return nil
+  }
+  value := getEnv(m[1] + m[2])
+  var items []string
+  for _, item := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '\n' || r == '\r' }) {
+    if item = strings.TrimSpace(item); item != "" {
+      items = append(items, item)
+    }
+  }
+  return items
+}
+
+// expandSource turns one source of a config file entry (fileURL, expandedURL after env expansion)
+// into the files it refers to. Recursive entries expand to every file below a local directory, in lexical order.
+func expandSource(baseDir string, cfg *EmbedConfig, entry *FileEntry, fileURL, expandedURL string) ([]fileInfo, error) {
+  // A local entry is a path even where github defaults would make it a repository file
+  gh := entry.github
+  if gh != nil {
+    fileURL = entry.GitHub
+  } else if entry.Type != sourceTypeLocal {
+    gh = repoFile(expandedURL, cfg.GitHub)
+  }
+  if gh != nil {
+    expandedURL = gh.rawURL()
+  }
+  if entry.API && gh == nil {
+    return nil, fmt.Errorf("%s: api requires a github file", fileURL)
+  }
+
+  if entry.Type == sourceTypeURL && !isRemoteURL(expandedURL) {
+    return nil, fmt.Errorf("%s: type url needs an http(s) URL, got %s", fileURL, expandedURL)
+  }
+  if entry.isRemote(expandedURL) {
+    if entry.Recursive {
+      return nil, fmt.Errorf("%s: recursive is only supported for local directories", fileURL)
+    }
+    // For URLs, extract path after the domain
+    parts := strings.Split(strings.SplitN(expandedURL, "?", 2)[0], "/")
+    for i, part := range parts {
+      if unescaped, err := url.PathUnescape(part); err == nil {
+        parts[i] = unescaped
       }
-    } else {
-      // Scan all .go files in cwd for package name
-      entries, err := os.ReadDir(cwd)
-      if err == nil {
-        pkgCount := map[string]int{}
-        for _, entry := range entries {
-          // Only consider .go files that are not embed.go and not generated (e.g., only main.go)
-          if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".go") && entry.Name() != cfg.GoOutput && entry.Name() != "embed.go" {
-            filePath := filepath.Join(cwd, entry.Name())
-            data, err := os.ReadFile(filePath)
-            if err == nil {
-              lines := strings.Split(string(data), "\n")
-              for _, l := range lines {
-                l = strings.TrimSpace(l)
-                if strings.HasPrefix(l, "package ") {
-                  name := strings.TrimPrefix(l, "package ")
-                  name = strings.Fields(name)[0]
-                  pkgCount[name]++
-                  break
-                }
-              }
-            }
-          }
-        }
-        // Use the most common package name
-        maxCount := 0
-        for name, count := range pkgCount {
-          if count > maxCount {
-            pkgName = name
-            maxCount = count
-          }
-        }
+    }
+    shortName := parts[len(parts)-1]
+    sourcePath := shortName
+    // Use path parts after protocol and domain (skip first 3: "", "", "domain")
+    if len(parts) > 3 {
+      sourcePath = strings.Join(pathSegments(strings.Join(parts[3:], "/")), "/")
+    }
+    info := fileInfo{originalURL: fileURL, expandedURL: expandedURL, sourcePath: sourcePath, shortName: shortName, github: gh, entry: entry}
+    if entry.API {
+      info.githubAPI = gh
+    }
+    return []fileInfo{info}, nil
+  }
+
+  // For local files, use the file path
+  if !entry.Recursive {
+    return []fileInfo{{
+      originalURL: fileURL,
+      expandedURL: expandedURL,
+      sourcePath:  strings.Join(pathSegments(filepath.ToSlash(expandedURL)), "/"),
+      shortName:   filepath.Base(expandedURL),
+      entry:       entry,
+    }}, nil
+  }
+
+  root := resolvePath(baseDir, expandedURL)
+  var infos []fileInfo
+  err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+    if err != nil {
+      return err
+    }
+    rel, _ := filepath.Rel(root, p)
+    rel = filepath.ToSlash(rel)
+    if rel != "." && matchesIgnore(rel, entry.Ignore) {
+      if d.IsDir() {
+        return filepath.SkipDir
       }
+      return nil
+    }
+    // The generated files may live in the embedded directory, but must never embed themselves
+    if !d.Type().IsRegular() || cfg.isGenerated(baseDir, p) {
+      return nil
     }
+    local := filepath.Join(expandedURL, filepath.FromSlash(rel))
+    infos = append(infos, fileInfo{
+      originalURL: fileURL,
+      expandedURL: local,
+      sourcePath:  strings.Join(pathSegments(filepath.ToSlash(local)), "/"),
+      shortName:   d.Name(),
+      treePath:    rel,
+      entry:       entry,
+    })
+    return nil
+  })
+  if err != nil {
+    return nil, fmt.Errorf("failed to walk %s: %v", root, err)
   }
+  if len(infos) == 0 {
+    return nil, fmt.Errorf("%s: no files found", fileURL)
+  }
+  return infos, nil
+}
 
-  // 4. Generate embed.go in cwd
-  embedGo := fmt.Sprintf("package %s\n\nimport (\n\t_ \"embed\"\n)\n\n// Embedded assets generated by remoteembed\n\n", pkgName)
-  for _, v := range embedVars {
-    embedGo += v + "\n"
+// matchesIgnore reports whether the slash-separated path rel matches one of the ignore patterns.
+// Patterns use path.Match syntax and are matched against both the relative path and the base name.
+func matchesIgnore(rel string, patterns []string) bool {
+  base := path.Base(rel)
+  for _, p := range patterns {
+    if ok, _ := path.Match(p, rel); ok {
+      return true
+    }
+    if ok, _ := path.Match(p, base); ok {
+      return true
+    }
   }
-  embedGoPath := filepath.Join(cwd, cfg.GoOutput)
-  if err := os.WriteFile(embedGoPath, []byte(embedGo), 0644); err != nil {
-    fmt.Fprintf(os.Stderr, "failed to write %s: %v\n", embedGoPath, err)
-    os.Exit(1)
+  return false
+}
+
+// sanitizeEmbedPath replaces characters that go:embed rejects in file names
+// (or would treat as pattern syntax) with _ in every element of a slash-separated path
+func sanitizeEmbedPath(p string) string {
+  return strings.Map(func(r rune) rune {
+    if unicode.IsControl(r) || strings.ContainsRune("\"'`*:;<>?|[]\\", r) {
+      return '_'
+    }
+    return r
+  }, p)
+}
+
+// warner reports the non-fatal problems of a run
+type warner struct {
+  werror bool      // turn warnings into errors (-Werror)
+  out    io.Writer // os.Stderr when nil
+}
+
+// warnf reports a non-fatal problem, or returns it as an error under -Werror
+func (w warner) warnf(format string, args ...any) error {
+  if w.werror {
+    return fmt.Errorf(format+" (-Werror)", args...)
+  }
+  out := w.out
+  if out == nil {
+    out = os.Stderr
+  }
+  fmt.Fprintf(out, "warning: "+format+"\n", args...)
+  return nil
+}
+
+// resolvePath returns p relative to base unless it is already absolute
+func resolvePath(base, p string) string {
+  if filepath.IsAbs(p) {
+    return p
   }
+  return filepath.Join(base, p)
+}
+
+// readLocalFile reads the local source file srcFile
+func readLocalFile(srcFile string) ([]byte, error) {
+  data, err := os.ReadFile(srcFile)
+  if err != nil {
+    return nil, fmt.Errorf("failed to open source file %s: %v", srcFile, err)
+  }
+  return data, nil
+}
+
+// resolveFileURL turns a repository-relative path into a raw GitHub URL when github defaults are configured.
+// Absolute URLs and explicit local paths (./, ../ or absolute) are returned unchanged.
+func resolveFileURL(file string, gh *GitHubSource) string {
+  if f := repoFile(file, gh); f != nil {
+    return f.rawURL()
+  }
+  return file
+}
+
+// isRemoteURL reports whether file should be downloaded over HTTP(S)
+func isRemoteURL(file string) bool {
+  return strings.HasPrefix(file, "http://") || strings.HasPrefix(file, "https://")
+}
+
+// sourceDateEpoch returns the time from SOURCE_DATE_EPOCH (https://reproducible-builds.org/specs/source-date-epoch/)
+func sourceDateEpoch() (time.Time, bool, error) {
+  value := getEnv("SOURCE_DATE_EPOCH")
+  if value == "" {
+    return time.Time{}, false, nil
+  }
+  seconds, err := strconv.ParseInt(value, 10, 64)
+  if err != nil {
+    return time.Time{}, false, fmt.Errorf("invalid SOURCE_DATE_EPOCH %q: %v", value, err)
+  }
+  return time.Unix(seconds, 0).UTC(), true, nil
 }
 
 // loadDotEnv loads environment variables from a .env file if it exists
 func loadDotEnv(dir string) {
+  // Replace the variables of a previous load so a rewritten .env is picked up as a whole
+  vars := make(map[string]string)
+  defer func() {
+    envMu.Lock()
+    envVars = vars
+    envMu.Unlock()
+  }()
   envPath := filepath.Join(dir, ".env")
   f, err := os.Open(envPath)
   if err != nil {
@@ -285,42 +1205,153 @@ func loadDotEnv(dir string) {
     if len(value) >= 2 && ((value[0] == '"' && value[len(value)-1] == '"') || (value[0] == '\'' && value[len(value)-1] == '\'')) {
       value = value[1 : len(value)-1]
     }
-    envVars[key] = value
+    vars[key] = value
   }
 }
 
 // getEnv returns the value of an environment variable, checking .env first then os.Getenv
 func getEnv(key string) string {
-  if val, ok := envVars[key]; ok {
+  envMu.RLock()
+  val, ok := envVars[key]
+  envMu.RUnlock()
+  if ok {
     return val
   }
   return os.Getenv(key)
 }
 
-// expandEnvVars expands environment variables in the format $VAR or ${VAR}
+// expandEnvVars expands environment variables in the format $VAR or ${VAR}, and ${VAR:-default}, which uses
+// default when VAR is unset or empty. ${VAR:?message} expands to VAR; loadConfig has already failed when it is unset
 func expandEnvVars(s string) string {
-  return os.Expand(s, getEnv)
+  return expandRefs(s, func(ref string) string {
+    name, op, arg := parseEnvRef(ref)
+    value := getEnv(name)
+    if value == "" && op == ":-" {
+      return expandEnvVars(arg)
+    }
+    return value
+  })
+}
+
+// expandRefs is os.Expand, except that a ${...} reference ends at its matching brace, so a default can hold
+// references itself: ${A:-${B}}. mapping gets the name of $VAR, or everything between the braces of ${...}
+func expandRefs(s string, mapping func(ref string) string) string {
+  var buf strings.Builder
+  i := 0
+  for j := 0; j < len(s); j++ {
+    if s[j] != '$' || j+1 >= len(s) {
+      continue
+    }
+    buf.WriteString(s[i:j])
+    ref, w := envRefAt(s[j+1:])
+    switch {
+    case ref == "" && w > 0:
+      // Invalid syntax such as ${} or an unterminated ${ is dropped, as os.Expand does
+    case ref == "":
+      buf.WriteByte('$')
+    default:
+      buf.WriteString(mapping(ref))
+    }
+    j += w
+    i = j + 1
+  }
+  if i == 0 {
+    return s
+  }
+  buf.WriteString(s[i:])
+  return buf.String()
+}
+
+// envRefAt returns the reference at the start of s, which follows a $, and the number of bytes it takes
+func envRefAt(s string) (string, int) {
+  switch {
+  case s[0] == '{':
+    depth := 0
+    for i := 1; i < len(s); i++ {
+      switch {
+      case s[i] == '$' && i+1 < len(s) && s[i+1] == '{':
+        depth++
+        i++
+      case s[i] == '}' && depth > 0:
+        depth--
+      case s[i] == '}':
+        if i == 1 {
+          return "", 2
+        }
+        return s[1:i], i + 1
+      }
+    }
+    return "", 1
+  case strings.IndexByte("*#$@!?-0123456789", s[0]) >= 0:
+    return s[:1], 1
+  }
+  i := 0
+  for i < len(s) && (s[i] == '_' || 'a' <= s[i] && s[i] <= 'z' || 'A' <= s[i] && s[i] <= 'Z' || '0' <= s[i] && s[i] <= '9') {
+    i++
+  }
+  return s[:i], i
+}
+
+// parseEnvRef splits the reference expandRefs passes for ${...} into the variable name, the ":-" or ":?"
+// operator and its argument. A plain reference has no operator
+func parseEnvRef(ref string) (name, op, arg string) {
+  if i := strings.Index(ref, ":"); i > 0 && i+1 < len(ref) && (ref[i+1] == '-' || ref[i+1] == '?') {
+    return ref[:i], ref[i:i+2], ref[i+2:]
+  }
+  return ref, "", ""
 }
 
 // toGoVarName converts a file name to a Go exported variable name
 // naming: "pascal" (default) -> PascalCase, "snake" -> Snake_Case
 func toGoVarName(name string, naming string) string {
-  name = strings.TrimSuffix(name, filepath.Ext(name))
+  name = trimExt(name)
   if naming == "snake" {
-    name = strings.ReplaceAll(name, "-", "_")
-    name = strings.ReplaceAll(name, ".", "_")
+    // Dot-only segments are dropped and dotfile prefixes removed, so they never produce empty words
+    segments := pathSegments(filepath.ToSlash(name))
+    for i, seg := range segments {
+      segments[i] = strings.TrimLeft(seg, ".")
+    }
+    name = strings.Join(segments, "_")
+    // Any character that can't appear in an identifier (-, ., spaces, ...) becomes _
+    name = strings.Map(func(r rune) rune {
+      if unicode.IsLetter(r) || unicode.IsDigit(r) {
+        return r
+      }
+      return '_'
+    }, name)
     return strings.Title(name)
   }
   // Default: PascalCase
   return toPascalCase(name)
 }
 
+// pathSegments splits a slash-separated path, dropping empty, "." and ".." segments
+func pathSegments(p string) []string {
+  var segments []string
+  for _, seg := range strings.Split(p, "/") {
+    if strings.Trim(seg, ".") != "" {
+      segments = append(segments, seg)
+    }
+  }
+  return segments
+}
+
+// trimExt removes the extension of the last path element, keeping dotfiles such as .env intact
+func trimExt(name string) string {
+  ext := path.Ext(name)
+  if ext == path.Base(name) {
+    return name
+  }
+  return strings.TrimSuffix(name, ext)
+}
+
 // toPascalCase converts a string to PascalCase
 func toPascalCase(name string) string {
   var parts []string
   current := ""
   for _, r := range name {
-    if r == '-' || r == '_' || r == '.' || r == '/' {
+    // Separators like -, _, ., / and anything else not valid in an identifier start a new word
+    if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
       if current != "" {
         parts = append(parts, current)
         current = ""
@@ -345,10 +1376,15 @@ type fileInfo struct {
   expandedURL string
   sourcePath  string // path portion for uniqueness calculation
   shortName   string
+  treePath    string // path below the root of a recursive directory entry
+  github      *githubFile // set when the file is in a GitHub repository
+  githubAPI   *githubFile // set when the file is downloaded through the GitHub API
+  entry       *FileEntry
 }
 
 // resolveUniquePaths takes file infos and returns the minimum unique path for each file
-// by including parent directory parts from the right until all paths are unique
+// by including parent directory parts from the right until all paths are unique. The path of a file only
+// depends on the set of files, not on their order
 func resolveUniquePaths(files []fileInfo) []string {
   result := make([]string, len(files))
 
@@ -405,11 +1441,80 @@ func resolveUniquePaths(files []fileInfo) []string {
     }
   }
 
+  // Files with the same full path, e.g. from different hosts, are told apart by their URL
+  keys := make([]string, len(files))
+  for i, f := range files {
+    keys[i] = f.expandedURL
+  }
+  numberCollisions(result, keys)
+  return result
+}
+
+// numberCollisions makes names that are still equal distinct without depending on their order: of the entries
+// sharing a name, the one with the smallest key keeps it and the others get _1, _2, ... before the extension
+// in key order, skipping names already taken
+func numberCollisions(names, keys []string) {
+  byName := make(map[string][]int)
+  for i, name := range names {
+    byName[name] = append(byName[name], i)
+  }
+  taken := make(map[string]bool)
+  var collided []string
+  for name, indices := range byName {
+    taken[name] = true
+    if len(indices) > 1 {
+      collided = append(collided, name)
+    }
+  }
+  // Numbered names are handed out in name order, so that two groups never race for one
+  slices.Sort(collided)
+  for _, name := range collided {
+    indices := byName[name]
+    slices.SortStableFunc(indices, func(a, b int) int { return strings.Compare(keys[a], keys[b]) })
+    base := trimExt(name)
+    ext := strings.TrimPrefix(name, base)
+    n := 1
+    for _, i := range indices[1:] {
+      for taken[fmt.Sprintf("%s_%d%s", base, n, ext)] {
+        n++
+      }
+      names[i] = fmt.Sprintf("%s_%d%s", base, n, ext)
+      taken[names[i]] = true
+    }
+  }
+}
+
+// resolveSuffixedPaths names every file by its file name alone, so that all of them share one directory.
+// The first file with a name (in config order) keeps it and later ones get _1, _2, ... before the extension,
+// skipping names that another file already has
+func resolveSuffixedPaths(files []fileInfo) []string {
+  result := make([]string, len(files))
+  reserved := make(map[string]bool)
+  for _, f := range files {
+    reserved[f.shortName] = true
+  }
+  used := make(map[string]bool)
+  for i, f := range files {
+    name := f.shortName
+    if used[name] {
+      base := trimExt(name)
+      ext := strings.TrimPrefix(name, base)
+      for n := 1; ; n++ {
+        name = fmt.Sprintf("%s_%d%s", base, n, ext)
+        if !used[name] && !reserved[name] {
+          break
+        }
+      }
+    }
+    used[name] = true
+    result[i] = name
+  }
   return result
 }
 
 // resolveUniqueVarNames takes a list of embed paths and returns unique variable names
-// by including parent directory parts when there are duplicates
+// by including parent directory parts when there are duplicates. Like resolveUniquePaths, the name
+// of a path only depends on the set of paths
 func resolveUniqueVarNames(paths []string, naming string) []string {
   // First pass: get base var names and detect duplicates
   baseNames := make([]string, len(paths))
@@ -431,7 +1536,7 @@ func resolveUniqueVarNames(paths []string, naming string) []string {
 
     if len(indices) > 1 {
       // Need to make unique - find minimum depth where this path differs from all others
-      pathParts := strings.Split(filepath.ToSlash(p), "/")
+      pathParts := pathSegments(filepath.ToSlash(p))
 
       for depth := 2; depth <= len(pathParts); depth++ {
         startIdx := len(pathParts) - depth
@@ -443,15 +1548,14 @@ func resolveUniqueVarNames(paths []string, naming string) []string {
 
         // Build var name from path parts (excluding extension from last part)
         lastPart := relevantParts[len(relevantParts)-1]
-        lastPart = strings.TrimSuffix(lastPart, filepath.Ext(lastPart))
-        relevantParts[len(relevantParts)-1] = lastPart
+        relevantParts[len(relevantParts)-1] = trimExt(lastPart)
 
         var candidate string
         if naming == "snake" {
           // For snake case: Title only the prefix parts, keep base name lowercase with underscores
           var prefixParts []string
           for j := 0; j < len(relevantParts)-1; j++ {
-            prefixParts = append(prefixParts, strings.Title(relevantParts[j]))
+            prefixParts = append(prefixParts, strings.Title(strings.TrimLeft(relevantParts[j], ".")))
           }
           // Base part: replace - and . with _, keep lowercase
           basePart := relevantParts[len(relevantParts)-1]
@@ -473,7 +1577,7 @@ func resolveUniqueVarNames(paths []string, naming string) []string {
           if otherIdx == i {
             continue
           }
-          otherParts := strings.Split(filepath.ToSlash(paths[otherIdx]), "/")
+          otherParts := pathSegments(filepath.ToSlash(paths[otherIdx]))
           otherStartIdx := len(otherParts) - depth
           if otherStartIdx < 0 {
             otherStartIdx = 0
@@ -481,14 +1585,13 @@ func resolveUniqueVarNames(paths []string, naming string) []string {
           otherRelevantParts := make([]string, len(otherParts[otherStartIdx:]))
           copy(otherRelevantParts, otherParts[otherStartIdx:])
           otherLastPart := otherRelevantParts[len(otherRelevantParts)-1]
-          otherLastPart = strings.TrimSuffix(otherLastPart, filepath.Ext(otherLastPart))
-          otherRelevantParts[len(otherRelevantParts)-1] = otherLastPart
+          otherRelevantParts[len(otherRelevantParts)-1] = trimExt(otherLastPart)
 
           var otherCandidate string
           if naming == "snake" {
             var prefixParts []string
             for j := 0; j < len(otherRelevantParts)-1; j++ {
-              prefixParts = append(prefixParts, strings.Title(otherRelevantParts[j]))
+              prefixParts = append(prefixParts, strings.Title(strings.TrimLeft(otherRelevantParts[j], ".")))
             }
             basePart := otherRelevantParts[len(otherRelevantParts)-1]
             basePart = strings.ReplaceAll(basePart, "-", "_")
@@ -518,5 +1621,6 @@ func resolveUniqueVarNames(paths []string, naming string) []string {
     result[i] = varName
   }
 
+  numberCollisions(result, paths)
   return result
 }
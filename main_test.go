@@ -1,12 +1,18 @@
 package main
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -338,6 +344,30 @@ func TestResolveUniqueVarNames(t *testing.T) {
 				"Settings_session_tokens",
 			},
 		},
+		{
+			name: "snake naming with dotted dirs",
+			paths: []string{
+				".schemas/visitors.json",
+				"./.indices/visitors.json",
+			},
+			naming: "snake",
+			expected: []string{
+				"Schemas_visitors",
+				"Indices_visitors",
+			},
+		},
+		{
+			name: "dot-only segments",
+			paths: []string{
+				"./config.json",
+				"../shared/config.json",
+			},
+			naming: "pascal",
+			expected: []string{
+				"Config",
+				"SharedConfig",
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -367,6 +397,7 @@ func TestToPascalCase(t *testing.T) {
 		{"hello/world", "HelloWorld"},
 		{"mapping/session_tokens", "MappingSessionTokens"},
 		{"a/b/c", "ABC"},
+		{"my file (1)", "MyFile1"},
 	}
 
 	for _, tt := range tests {
@@ -445,3 +476,1269 @@ func TestResolveUniquePaths(t *testing.T) {
 		})
 	}
 }
+
+func TestResolveUniquePathsOrderIndependent(t *testing.T) {
+	files := []fileInfo{
+		{expandedURL: "https://a.example.com/x/config.json", sourcePath: "x/config.json", shortName: "config.json"},
+		{expandedURL: "https://b.example.com/x/config.json", sourcePath: "x/config.json", shortName: "config.json"},
+		{expandedURL: "https://c.example.com/x/config.json", sourcePath: "x/config.json", shortName: "config.json"},
+		{expandedURL: "https://a.example.com/config_1.json", sourcePath: "config_1.json", shortName: "config_1.json"},
+		{expandedURL: "https://a.example.com/a/y/file.txt", sourcePath: "a/y/file.txt", shortName: "file.txt"},
+		{expandedURL: "https://a.example.com/b/y/file.txt", sourcePath: "b/y/file.txt", shortName: "file.txt"},
+		{expandedURL: "https://a.example.com/y/file.txt", sourcePath: "y/file.txt", shortName: "file.txt"},
+	}
+	want := map[string]string{
+		"https://a.example.com/x/config.json": "x/config.json",
+		"https://b.example.com/x/config.json": "x/config_1.json",
+		"https://c.example.com/x/config.json": "x/config_2.json",
+		"https://a.example.com/config_1.json": "config_1.json",
+		"https://a.example.com/a/y/file.txt":  "a/y/file.txt",
+		"https://a.example.com/b/y/file.txt":  "b/y/file.txt",
+		"https://a.example.com/y/file.txt":    "y/file.txt",
+	}
+	for _, order := range [][]int{{0, 1, 2, 3, 4, 5, 6}, {6, 5, 4, 3, 2, 1, 0}, {2, 0, 6, 1, 4, 3, 5}, {3, 6, 1, 5, 0, 2, 4}} {
+		shuffled := make([]fileInfo, len(order))
+		for i, j := range order {
+			shuffled[i] = files[j]
+		}
+		for i, got := range resolveUniquePaths(shuffled) {
+			if url := shuffled[i].expandedURL; got != want[url] {
+				t.Errorf("order %v: %s resolved to %q, want %q", order, url, got, want[url])
+			}
+		}
+	}
+}
+
+func TestResolveUniqueVarNamesOrderIndependent(t *testing.T) {
+	paths := []string{
+		"assets/mapping/users.json",
+		"assets/settings/users.json",
+		"assets/api-v1.json",
+		"assets/api_v1.json",
+		"assets/config.xml",
+	}
+	want := map[string]string{}
+	for i, name := range resolveUniqueVarNames(paths, "") {
+		want[paths[i]] = name
+	}
+	// Names that would collide are still told apart
+	if want["assets/api-v1.json"] == want["assets/api_v1.json"] {
+		t.Fatalf("api-v1.json and api_v1.json share the name %s", want["assets/api-v1.json"])
+	}
+	for _, order := range [][]int{{4, 3, 2, 1, 0}, {1, 3, 0, 4, 2}, {3, 2, 4, 0, 1}} {
+		shuffled := make([]string, len(order))
+		for i, j := range order {
+			shuffled[i] = paths[j]
+		}
+		for i, got := range resolveUniqueVarNames(shuffled, "") {
+			if got != want[shuffled[i]] {
+				t.Errorf("order %v: %s named %s, want %s", order, shuffled[i], got, want[shuffled[i]])
+			}
+		}
+	}
+}
+
+func TestPlanAssetsVarNameCollisions(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"src/api-v1.json": "{}", "src/api_v1.json": "{}"})
+	want := map[string]string{"src/api-v1.json": "ApiV1", "src/api_v1.json": "ApiV1_1"}
+	// Paths that convert to the same name are numbered, whatever their order in files
+	for _, sources := range [][]string{{"src/api-v1.json", "src/api_v1.json"}, {"src/api_v1.json", "src/api-v1.json"}} {
+		cfg := &EmbedConfig{Output: "assets", GoOutput: "embed.go"}
+		for _, source := range sources {
+			cfg.Files = append(cfg.Files, FileEntry{Source: source})
+		}
+		assets, err := planAssets(tmpDir, cfg)
+		if err != nil {
+			t.Fatalf("planAssets() error: %v", err)
+		}
+		for i, a := range assets {
+			if a.varName != want[sources[i]] {
+				t.Errorf("order %v: %s named %s, want %s", sources, sources[i], a.varName, want[sources[i]])
+			}
+		}
+	}
+
+	// An alias keeps its name and the generated one collides with nothing
+	cfg := &EmbedConfig{
+		Output:   "assets",
+		GoOutput: "embed.go",
+		Aliases:  map[string]string{"src/api-v1.json": "Legacy"},
+		Files:    []FileEntry{{Source: "src/api-v1.json"}, {Source: "src/api_v1.json"}},
+	}
+	assets, err := planAssets(tmpDir, cfg)
+	if err != nil {
+		t.Fatalf("planAssets() error: %v", err)
+	}
+	if assets[0].varName != "Legacy" || assets[1].varName != "ApiV1" {
+		t.Errorf("names = %s, %s; want Legacy, ApiV1", assets[0].varName, assets[1].varName)
+	}
+}
+
+func TestResolveSuffixedPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []fileInfo
+		expected []string
+	}{
+		{
+			name: "duplicates are numbered in config order",
+			files: []fileInfo{
+				{sourcePath: "prod/config.xml", shortName: "config.xml"},
+				{sourcePath: "staging/config.xml", shortName: "config.xml"},
+				{sourcePath: "src/users.sql", shortName: "users.sql"},
+				{sourcePath: "dev/config.xml", shortName: "config.xml"},
+			},
+			expected: []string{"config.xml", "config_1.xml", "users.sql", "config_2.xml"},
+		},
+		{
+			name: "existing numbered names are skipped",
+			files: []fileInfo{
+				{sourcePath: "a/config.xml", shortName: "config.xml"},
+				{sourcePath: "b/config.xml", shortName: "config.xml"},
+				{sourcePath: "c/config_1.xml", shortName: "config_1.xml"},
+			},
+			expected: []string{"config.xml", "config_2.xml", "config_1.xml"},
+		},
+		{
+			name: "dotfiles and names without extension",
+			files: []fileInfo{
+				{sourcePath: "a/.env", shortName: ".env"},
+				{sourcePath: "b/.env", shortName: ".env"},
+				{sourcePath: "a/LICENSE", shortName: "LICENSE"},
+				{sourcePath: "b/LICENSE", shortName: "LICENSE"},
+			},
+			expected: []string{".env", ".env_1", "LICENSE", "LICENSE_1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resolveSuffixedPaths(tt.files)
+			if len(result) != len(tt.expected) {
+				t.Fatalf("length mismatch: got %d, want %d", len(result), len(tt.expected))
+			}
+			for i, r := range result {
+				if r != tt.expected[i] {
+					t.Errorf("result[%d] = %q, want %q", i, r, tt.expected[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanAssetsSuffixStrategy(t *testing.T) {
+	cfg := &EmbedConfig{
+		Output:            "assets",
+		GoOutput:          "embed.go",
+		CollisionStrategy: "suffix",
+		Files: []FileEntry{
+			{Source: "https://example.com/prod/config.xml"},
+			{Source: "https://example.com/staging/config.xml"},
+			{Source: "mapping/users.json"},
+		},
+	}
+	expected := []struct{ embedPath, varName string }{
+		{"assets/config.xml", "Config"},
+		{"assets/config_1.xml", "Config1"},
+		{"assets/users.json", "Users"},
+	}
+	// Names only depend on the config, so every run produces the same ones
+	for range 2 {
+		assets, err := planAssets(t.TempDir(), cfg)
+		if err != nil {
+			t.Fatalf("planAssets() error: %v", err)
+		}
+		for i, want := range expected {
+			if a := assets[i]; a.relEmbedPath != want.embedPath || a.varName != want.varName {
+				t.Errorf("asset %d = {%q, %q}, want {%q, %q}", i, a.relEmbedPath, a.varName, want.embedPath, want.varName)
+			}
+		}
+	}
+}
+
+func TestFetchURLMaxRedirects(t *testing.T) {
+	var hits int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits++
+		switch r.URL.Path {
+		case "/ok.txt":
+			w.Write([]byte("ok"))
+		case "/hop":
+			http.Redirect(w, r, "/ok.txt", http.StatusFound)
+		case "/cycle-a":
+			http.Redirect(w, r, "/cycle-b", http.StatusFound)
+		case "/cycle-b":
+			http.Redirect(w, r, "/cycle-a", http.StatusFound)
+		case "/no-location":
+			// A malformed redirect: its page must not be embedded
+			w.WriteHeader(http.StatusMovedPermanently)
+			w.Write([]byte("<html>Moved</html>"))
+		case "/choices":
+			w.Header().Set("Location", "/ok.txt")
+			w.WriteHeader(http.StatusMultipleChoices)
+		default:
+			// Endless chain of distinct URLs
+			http.Redirect(w, r, fmt.Sprintf("/endless/%d", hits), http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		path         string
+		maxRedirects int
+		wantErr      string
+	}{
+		{"within cap", "/hop", 1, ""},
+		{"endless chain hits cap", "/endless/0", 3, "stopped after 3 redirects"},
+		{"loop rejected", "/cycle-a", 10, "redirect loop detected"},
+		{"redirects disabled", "/hop", 0, "redirects are disabled"},
+		{"missing location", "/no-location", 10, "/no-location: 301 Moved Permanently without a Location header: malformed redirect"},
+		{"redirect not followed", "/choices", 10, "300 Multiple Choices to /ok.txt was not followed"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits = 0
+			data, err := fetchURL(newHTTPClient(tt.maxRedirects, nil, 0), server.URL+tt.path, fetchOptions{})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				if string(data) != "ok" {
+					t.Errorf("downloaded content = %q, want %q", string(data), "ok")
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to contain %q", err, tt.wantErr)
+			}
+			if tt.name == "endless chain hits cap" && hits != tt.maxRedirects+1 {
+				t.Errorf("server hits = %d, want %d", hits, tt.maxRedirects+1)
+			}
+		})
+	}
+}
+
+func TestResolveFileURL(t *testing.T) {
+	gh := &GitHubSource{Owner: "myorg", Repo: "schemas", Ref: "v1.2.0"}
+
+	tests := []struct {
+		name     string
+		file     string
+		gh       *GitHubSource
+		expected string
+	}{
+		{"repo path", "indices/users.json", gh, "https://raw.githubusercontent.com/myorg/schemas/v1.2.0/indices/users.json"},
+		{"default ref", "users.json", &GitHubSource{Owner: "myorg", Repo: "schemas"}, "https://raw.githubusercontent.com/myorg/schemas/HEAD/users.json"},
+		{"absolute URL bypasses", "https://example.com/users.json", gh, "https://example.com/users.json"},
+		{"explicit local path", "./local/users.json", gh, "./local/users.json"},
+		{"no github config", "local/users.json", nil, "local/users.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := resolveFileURL(tt.file, tt.gh)
+			if result != tt.expected {
+				t.Errorf("resolveFileURL(%q) = %q, want %q", tt.file, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGitHubConfigRefSubstitution(t *testing.T) {
+	configContent := `github:
+  owner: myorg
+  repo: schemas
+  ref: ${SCHEMAS_REF}
+files:
+  - users.json
+`
+	var cfg EmbedConfig
+	if err := yaml.Unmarshal([]byte(configContent), &cfg); err != nil {
+		t.Fatalf("failed to parse config: %v", err)
+	}
+	t.Setenv("SCHEMAS_REF", "release-2024")
+
+	ref := expandEnvVars(cfg.GitHub.Ref)
+	result := resolveFileURL(cfg.Files[0].Source, &GitHubSource{Owner: cfg.GitHub.Owner, Repo: cfg.GitHub.Repo, Ref: ref})
+	expected := "https://raw.githubusercontent.com/myorg/schemas/release-2024/users.json"
+	if result != expected {
+		t.Errorf("resolved URL = %q, want %q", result, expected)
+	}
+}
+
+// writeTestFiles creates files (relative path -> content) under dir
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("failed to create dir for %s: %v", name, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a",
+		"src/b.txt": "b",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	before, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b.txt
+`,
+	})
+	var out bytes.Buffer
+	if err := run(tmpDir, options{diff: true}, &out); err != nil {
+		t.Fatalf("run() with diff error: %v", err)
+	}
+
+	diff := out.String()
+	for _, want := range []string{"--- a/embed.go", "+++ b/embed.go", "+//go:embed assets/b.txt", "+var B string"} {
+		if !strings.Contains(diff, want) {
+			t.Errorf("diff output missing %q:\n%s", want, diff)
+		}
+	}
+	if strings.Contains(diff, "-var A string") {
+		t.Errorf("diff output should not remove unchanged var A:\n%s", diff)
+	}
+
+	// Diff mode must not write anything
+	after, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if string(after) != string(before) {
+		t.Errorf("embed.go changed in diff mode")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets", "b.txt")); !os.IsNotExist(err) {
+		t.Errorf("assets/b.txt should not be copied in diff mode")
+	}
+}
+
+func TestRunNormalizesText(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/unix.txt":    "a\nb",
+		"src/windows.txt": "a\r\nb\r\n",
+		"embed.yaml": `output: assets
+go-mod: main
+line-endings: lf
+ensure-trailing-newline: true
+files:
+  - src/unix.txt
+  - source: src/windows.txt
+    line-endings: keep
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	unix, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "unix.txt"))
+	if string(unix) != "a\nb\n" {
+		t.Errorf("unix.txt = %q, want %q", unix, "a\nb\n")
+	}
+	windows, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "windows.txt"))
+	if string(windows) != "a\r\nb\r\n" {
+		t.Errorf("windows.txt = %q, want %q", windows, "a\r\nb\r\n")
+	}
+}
+
+func TestRunReportsChanges(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a",
+		"src/b.txt": "b",
+		"src/c.txt": "c",
+		"src/d.txt": "d",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b.txt
+  - src/d.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a2",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/c.txt
+  - src/d.txt
+`,
+	})
+	var out bytes.Buffer
+	if err := run(tmpDir, options{changes: true}, &out); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	expected := "changed: assets/a.txt\nnew:     assets/c.txt\nremoved: assets/b.txt\n"
+	if out.String() != expected {
+		t.Errorf("report =\n%s\nwant\n%s", out.String(), expected)
+	}
+
+	out.Reset()
+	if err := run(tmpDir, options{changes: true}, &out); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if out.String() != "no files changed\n" {
+		t.Errorf("report = %q, want %q", out.String(), "no files changed\n")
+	}
+}
+
+func TestRunReproducible(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt":    "a",
+		"src/b-c.json": "{}",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b-c.json
+`,
+	})
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	first, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	second, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if !bytes.Equal(first, second) {
+		t.Errorf("embed.go differs between runs:\n%s\n---\n%s", first, second)
+	}
+
+	for _, name := range []string{"embed.go", "assets/a.txt", "assets/b-c.json"} {
+		info, err := os.Stat(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.ModTime().Unix() != 1700000000 {
+			t.Errorf("%s mtime = %d, want 1700000000", name, info.ModTime().Unix())
+		}
+	}
+}
+
+func TestSourceDateEpochInvalid(t *testing.T) {
+	t.Setenv("SOURCE_DATE_EPOCH", "yesterday")
+	if _, _, err := sourceDateEpoch(); err == nil {
+		t.Error("expected an error for a non-numeric SOURCE_DATE_EPOCH")
+	}
+}
+
+func TestRunRecursiveDirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"schemas/users.json":          "users",
+		"schemas/sub/orders.json":     "orders",
+		"schemas/sub/deep/items.json": "items",
+		"schemas/notes.bak":           "ignored",
+		"schemas/drafts/wip.json":     "ignored",
+		"other/orders.json":           "other orders",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: schemas
+    recursive: true
+    ignore:
+      - "*.bak"
+      - drafts
+  - other/orders.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	expectedFiles := map[string]string{
+		"assets/users.json":          "users",
+		"assets/sub/orders.json":     "orders",
+		"assets/sub/deep/items.json": "items",
+		"assets/other/orders.json":   "other orders",
+	}
+	for name, content := range expectedFiles {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Errorf("%s not written: %v", name, err)
+			continue
+		}
+		if string(data) != content {
+			t.Errorf("%s = %q, want %q", name, data, content)
+		}
+	}
+	for _, name := range []string{"assets/notes.bak", "assets/drafts/wip.json", "assets/wip.json"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("ignored file %s was embedded", name)
+		}
+	}
+
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	expected := `//go:embed assets/sub/deep/items.json
+var SubDeepItems string
+
+//go:embed assets/sub/orders.json
+var SubOrders string
+
+//go:embed assets/users.json
+var Users string
+
+//go:embed assets/other/orders.json
+var OtherOrders string
+`
+	if !strings.Contains(string(embedGo), expected) {
+		t.Errorf("embed.go does not embed the tree in lexical order:\n%s", embedGo)
+	}
+}
+
+func TestGoOutputInsideOutput(t *testing.T) {
+	t.Run("recursive directory skips the generated file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"assets/logo.svg": "<svg/>",
+			// Left by a previous run
+			"assets/embed.go": "package assets\n",
+			"embed.yaml": `output: assets
+go-output: assets/embed.go
+fs-func: Files
+files:
+  - source: assets
+    recursive: true
+`,
+		})
+		for i := 0; i < 2; i++ {
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+		}
+		embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "embed.go"))
+		if !strings.Contains(string(embedGo), "//go:embed logo.svg\n") {
+			t.Errorf("embed.go does not embed logo.svg:\n%s", embedGo)
+		}
+		if strings.Contains(string(embedGo), "//go:embed embed.go") {
+			t.Errorf("embed.go embeds itself:\n%s", embedGo)
+		}
+	})
+
+	t.Run("asset over the generated file", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"src/embed.go": "package src\n",
+			"embed.yaml": `output: assets
+go-output: assets/embed.go
+fs-func: Files
+files:
+  - src/embed.go
+`,
+		})
+		err := run(tmpDir, options{}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "assets/embed.go, which is also generated by remoteembed") {
+			t.Errorf("run() error = %v, want the overlap to be rejected", err)
+		}
+	})
+}
+
+func TestOutputDirOverride(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    string
+		outputDir string
+		wantFile  string
+		wantEmbed string
+	}{
+		{"plain", "embed.yaml", "build/assets", "build/assets/data.txt", "//go:embed build/assets/data.txt"},
+		{"short name", "embed.yaml", "build/<short_name>", "build/data/data.txt", "//go:embed build/data/data.txt"},
+		// The flag is relative to the working directory, not to the config
+		{"config in subdirectory", "pkg/embed.yaml", "pkg/generated", "pkg/generated/data.txt", "//go:embed generated/data.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			configDir := filepath.Dir(tt.config)
+			writeTestFiles(t, tmpDir, map[string]string{
+				filepath.Join(configDir, "src/data.txt"): "data",
+				tt.config:                                "output: assets\ngo-mod: main\nfiles:\n  - src/data.txt\n",
+			})
+			if err := run(tmpDir, options{config: tt.config, outputDir: tt.outputDir}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(tmpDir, tt.wantFile)); err != nil || string(data) != "data" {
+				t.Errorf("%s = %q (%v), want the asset there", tt.wantFile, data, err)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, configDir, "assets")); !os.IsNotExist(err) {
+				t.Error("the configured output should not be used")
+			}
+			embedGo, _ := os.ReadFile(filepath.Join(tmpDir, configDir, "embed.go"))
+			if !strings.Contains(string(embedGo), tt.wantEmbed+"\n") {
+				t.Errorf("embed.go does not contain %q:\n%s", tt.wantEmbed, embedGo)
+			}
+		})
+	}
+}
+
+func TestEnvFileList(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   string
+		value    string
+		expected []string
+	}{
+		{"comma", "$FILE_LIST", "a.txt, https://example.com/b.txt,,c.txt", []string{"a.txt", "https://example.com/b.txt", "c.txt"}},
+		{"newline", "${FILE_LIST}", "a.txt\r\nb.txt\n\n  c.txt  \n", []string{"a.txt", "b.txt", "c.txt"}},
+		{"single", "$FILE_LIST", "a.txt", []string{"a.txt"}},
+		{"empty", "$FILE_LIST", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FILE_LIST", tt.value)
+			if got := envFileList(tt.source); !slices.Equal(got, tt.expected) {
+				t.Errorf("envFileList(%q) = %q, want %q", tt.source, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandEnvVarsDefault(t *testing.T) {
+	t.Setenv("EMBED_TEST_REF", "")
+	t.Setenv("EMBED_TEST_FALLBACK", "dev")
+	t.Setenv("EMBED_TEST_UNSET", "")
+	tests := []struct {
+		name     string
+		ref      string
+		input    string
+		expected string
+	}{
+		{"unset uses default", "", "https://example.com/${EMBED_TEST_REF:-main}/a.txt", "https://example.com/main/a.txt"},
+		{"set ignores default", "v2", "https://example.com/${EMBED_TEST_REF:-main}/a.txt", "https://example.com/v2/a.txt"},
+		{"default expands variables", "", "${EMBED_TEST_REF:-$EMBED_TEST_FALLBACK}", "dev"},
+		{"empty default", "", "a${EMBED_TEST_REF:-}b", "ab"},
+		{"required set", "v2", "${EMBED_TEST_REF:?missing}", "v2"},
+		{"plain", "v2", "$EMBED_TEST_REF", "v2"},
+		{"nested default", "", "x/${EMBED_TEST_REF:-${EMBED_TEST_FALLBACK}}/y", "x/dev/y"},
+		{"nested default unused", "v2", "x/${EMBED_TEST_REF:-${EMBED_TEST_FALLBACK}}/y", "x/v2/y"},
+		{"doubly nested default", "", "${EMBED_TEST_REF:-${EMBED_TEST_UNSET:-${EMBED_TEST_FALLBACK}}}", "dev"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMBED_TEST_REF", tt.ref)
+			if got := expandEnvVars(tt.input); got != tt.expected {
+				t.Errorf("expandEnvVars(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestExpandRefsMatchesOSExpand(t *testing.T) {
+	// Without nested references, expandRefs behaves exactly like os.Expand
+	mapping := func(ref string) string { return "<" + ref + ">" }
+	for _, s := range []string{"", "plain", "$", "a$", "$A", "${A}", "x$A/y", "${A:-b}c", "${}", "${A", "$$", "$1x", "$*", "${*}", "a$-b", "$/", "${A}${B}"} {
+		if got, want := expandRefs(s, mapping), os.Expand(s, mapping); got != want {
+			t.Errorf("expandRefs(%q) = %q, want %q", s, got, want)
+		}
+	}
+	if got := expandRefs("x/${A:-${B}}/y", mapping); got != "x/<A:-${B}>/y" {
+		t.Errorf("expandRefs() = %q, want the nested reference in the default", got)
+	}
+}
+
+func TestRequiredEnvVar(t *testing.T) {
+	t.Setenv("EMBED_TEST_HOST", "")
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+files:
+  - https://${EMBED_TEST_HOST:?set EMBED_TEST_HOST to the asset server}/a.txt
+`,
+	})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	want := "EMBED_TEST_HOST: set EMBED_TEST_HOST to the asset server"
+	if err == nil || err.Error() != want {
+		t.Errorf("loadConfig() error = %v, want %q", err, want)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+files:
+  - https://${EMBED_TEST_HOST:?}/a.txt
+`,
+	})
+	_, err = loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	want = "EMBED_TEST_HOST: not set"
+	if err == nil || err.Error() != want {
+		t.Errorf("loadConfig() error = %v, want %q", err, want)
+	}
+
+	t.Setenv("EMBED_TEST_HOST", "example.com")
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	if got := expandEnvVars(cfg.Files[0].Source); got != "https://example.com/a.txt" {
+		t.Errorf("source = %q, want https://example.com/a.txt", got)
+	}
+}
+
+func TestRunEnvFileList(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	for name, separator := range map[string]string{"comma": ",", "newline": "\n"} {
+		t.Run(name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"src/local.txt": "local",
+				"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: $FILE_LIST
+    list: true
+  - src/other.txt
+`,
+				"src/other.txt": "other",
+			})
+			t.Setenv("FILE_LIST", server.URL+"/first.txt"+separator+"src/local.txt"+separator+server.URL+"/second.txt")
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+			for _, want := range []string{"var First string", "var Local string", "var Second string", "var Other string"} {
+				if !strings.Contains(string(embedGo), want) {
+					t.Errorf("embed.go does not contain %q:\n%s", want, embedGo)
+				}
+			}
+			if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "second.txt")); string(data) != "remote /second.txt" {
+				t.Errorf("second.txt = %q", data)
+			}
+		})
+	}
+
+	// Without list, a variable is a single source even when its value has commas
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a,b.txt": "one file",
+		"embed.yaml":  "output: assets\ngo-mod: main\nfiles:\n  - $FILE_LIST\n",
+	})
+	t.Setenv("FILE_LIST", "src/a,b.txt")
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "a,b.txt")); string(data) != "one file" {
+		t.Errorf("a,b.txt = %q, want the single file", data)
+	}
+
+	// An empty list is an error rather than no files
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: ${FILE_LIST}\n    list: true\n",
+	})
+	t.Setenv("FILE_LIST", " , ")
+	if err := run(tmpDir, options{}, io.Discard); err == nil || !strings.Contains(err.Error(), "${FILE_LIST} lists no files") {
+		t.Errorf("run() error = %v, want the empty list reported", err)
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "files:\n  - source: $BASE/a.txt\n    list: true\n",
+	})
+	if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "files[0]: list requires source to be a single $VAR") {
+		t.Errorf("loadConfig() error = %v, want list rejected", err)
+	}
+}
+
+func TestRunConfigInSubdirectory(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"a.txt":              "wrong base",
+		"configs/data/a.txt": "config base",
+		"configs/.env":       "DATA_DIR=data\n",
+		"configs/embed.yaml": `output: assets
+go-mod: main
+files:
+  - $DATA_DIR/a.txt
+`,
+	})
+	if err := run(tmpDir, options{config: filepath.Join("configs", "embed.yaml")}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(tmpDir, "configs", "assets", "a.txt"))
+	if err != nil || string(data) != "config base" {
+		t.Errorf("configs/assets/a.txt = (%q, %v), want %q", data, err, "config base")
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "configs", "embed.go")); err != nil {
+		t.Errorf("embed.go not generated next to the config: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets")); !os.IsNotExist(err) {
+		t.Errorf("assets written relative to the working directory instead of the config")
+	}
+}
+
+func TestRunFailureCommitsNothing(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a1",
+		"src/b.txt": "b1",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	embedBefore, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+
+	// The first file changes but a newly added later file fails
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "a2",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b.txt
+  - src/missing.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err == nil {
+		t.Fatal("expected run() to fail for a missing source")
+	}
+
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "a.txt")); string(data) != "a1" {
+		t.Errorf("assets/a.txt = %q, want the previous content %q", data, "a1")
+	}
+	if embedAfter, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go")); !bytes.Equal(embedAfter, embedBefore) {
+		t.Errorf("embed.go changed after a failed run:\n%s", embedAfter)
+	}
+	entries, _ := os.ReadDir(tmpDir)
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".remoteembed-staging-") {
+			t.Errorf("staging dir %s left behind", e.Name())
+		}
+	}
+}
+
+func TestRunOverallTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fast.txt" {
+			w.Write([]byte("fast"))
+			return
+		}
+		// Stall mid-body until the client gives up
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+overall-timeout: 200ms
+files:
+  - ` + server.URL + `/fast.txt
+  - ` + server.URL + `/slow.txt
+`,
+	})
+
+	start := time.Now()
+	err := run(tmpDir, options{}, io.Discard)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("run() took %s, the download was not cancelled", elapsed)
+	}
+	if !errors.Is(err, errOverallTimeout) {
+		t.Fatalf("run() error = %v, want overall timeout", err)
+	}
+	if code := exitCode(err); code != exitTimeout {
+		t.Errorf("exitCode() = %d, want %d", code, exitTimeout)
+	}
+
+	// Nothing is committed and the staging dir is cleaned up
+	entries, err := os.ReadDir(tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if e.Name() != "embed.yaml" {
+			t.Errorf("unexpected %s left after timeout", e.Name())
+		}
+	}
+}
+
+func TestRunWerror(t *testing.T) {
+	tests := []struct {
+		name    string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "sanitized name",
+			files:   map[string]string{"src/a:b.txt": "data"},
+			wantErr: `renamed "a:b.txt" to "a_b.txt"`,
+		},
+		{
+			name:    "empty file",
+			files:   map[string]string{"src/empty.txt": ""},
+			wantErr: "src/empty.txt is empty",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			var source string
+			for name := range tt.files {
+				source = name
+			}
+			tt.files["embed.yaml"] = "output: assets\ngo-mod: main\nfiles:\n  - " + source + "\n"
+			writeTestFiles(t, tmpDir, tt.files)
+
+			if err := run(tmpDir, options{werror: true}, io.Discard); err == nil || !strings.Contains(err.Error(), tt.wantErr) || !strings.HasSuffix(err.Error(), "(-Werror)") {
+				t.Fatalf("run() with -Werror error = %v, want %q", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "embed.go")); !os.IsNotExist(err) {
+				t.Errorf("embed.go was written under -Werror")
+			}
+
+			// Without -Werror the same config only warns
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+		})
+	}
+}
+
+func TestHiddenEmbedNames(t *testing.T) {
+	files := map[string]string{
+		"src/web/.schemas/user.json": "schema",
+		"src/web/_partial.html":      "partial",
+		"src/web/index.html":         "index",
+	}
+	config := "output: assets\ngo-mod: main\nfiles:\n  - source: src/web\n    recursive: true\n"
+
+	// The generated directives name each file, so hidden names are embedded as well
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, files)
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": config,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(SchemasUser, Partial, Index)
+}
+`,
+	})
+	if err := run(tmpDir, options{werror: true}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if out := runGenerated(t, tmpDir); out != "schema partial index\n" {
+		t.Errorf("output = %q, want the hidden files embedded", out)
+	}
+
+	// A hand-written //go:embed of the output directory would skip them without all:
+	tmpDir = t.TempDir()
+	writeTestFiles(t, tmpDir, files)
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "go-output: none\n" + config})
+	err := run(tmpDir, options{werror: true}, io.Discard)
+	want := ".schemas/user.json, _partial.html: a //go:embed of the output directory skips names starting with . or _, embed it with the all: prefix (//go:embed all:assets)"
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Errorf("run() with -Werror error = %v, want %q", err, want)
+	}
+}
+
+func TestPlanAssetsDotSegments(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &EmbedConfig{
+		Output:   "assets",
+		GoOutput: "embed.go",
+		Files: []FileEntry{
+			{Source: "./config.json"},
+			{Source: "sub/config.json"},
+			{Source: ".hidden/file.txt"},
+			{Source: "../shared/.env"},
+		},
+	}
+	for _, naming := range []string{"pascal", "snake"} {
+		t.Run(naming, func(t *testing.T) {
+			cfg.VarNaming = naming
+			assets, err := planAssets(tmpDir, cfg)
+			if err != nil {
+				t.Fatalf("planAssets() error: %v", err)
+			}
+			expected := []struct{ uniquePath, embedPath, varName string }{
+				{"config.json", "assets/config.json", "Config"},
+				{"sub/config.json", "assets/sub/config.json", "SubConfig"},
+				{"file.txt", "assets/file.txt", "File"},
+				{".env", "assets/.env", "Env"},
+			}
+			if naming == "snake" {
+				expected[1].varName = "Sub_config"
+			}
+			for i, want := range expected {
+				a := assets[i]
+				if a.uniquePath != want.uniquePath || a.relEmbedPath != want.embedPath || a.varName != want.varName {
+					t.Errorf("asset %d = {%q, %q, %q}, want {%q, %q, %q}", i, a.uniquePath, a.relEmbedPath, a.varName, want.uniquePath, want.embedPath, want.varName)
+				}
+			}
+		})
+	}
+}
+
+func TestPlanAssetsAliases(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("CDN", "https://cdn.example.com")
+	newConfig := func(aliases map[string]string) *EmbedConfig {
+		return &EmbedConfig{
+			Output:   "assets",
+			GoOutput: "embed.go",
+			Aliases:  aliases,
+			Files: []FileEntry{
+				{Source: "$CDN/v2/config.xml"},
+				{Source: "data/users.json"},
+				{Source: "https://example.com/logo.svg"},
+				{Source: "notes.txt"},
+				{Source: "templates/index.html"},
+			},
+		}
+	}
+
+	assets, err := planAssets(tmpDir, newConfig(map[string]string{
+		"$CDN/v2/config.xml":   "RemoteConfig",
+		"templates/index.html": "IndexPage",
+	}))
+	if err != nil {
+		t.Fatalf("planAssets() error: %v", err)
+	}
+	expected := []string{"RemoteConfig", "Users", "Logo", "Notes", "IndexPage"}
+	for i, want := range expected {
+		if assets[i].varName != want {
+			t.Errorf("asset %d varName = %q, want %q", i, assets[i].varName, want)
+		}
+	}
+
+	// The expanded source matches as well
+	assets, err = planAssets(tmpDir, newConfig(map[string]string{"https://cdn.example.com/v2/config.xml": "RemoteConfig"}))
+	if err != nil {
+		t.Fatalf("planAssets() error: %v", err)
+	}
+	if assets[0].varName != "RemoteConfig" {
+		t.Errorf("varName = %q, want RemoteConfig", assets[0].varName)
+	}
+
+	errorTests := []struct {
+		name    string
+		aliases map[string]string
+		wantErr string
+	}{
+		{"collides with auto name", map[string]string{"notes.txt": "Users"}, "would both be named Users"},
+		{"duplicate alias", map[string]string{"notes.txt": "Text", "data/users.json": "Text"}, "would both be named Text"},
+		{"unmatched", map[string]string{"missing.txt": "Missing"}, "aliases do not match any file: missing.txt"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := planAssets(tmpDir, newConfig(tt.aliases))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("planAssets() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunAssetsOnly(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name   string
+		config string
+		opts   options
+	}{
+		{"go-output none", "go-output: none\noutput: ../shared\n", options{}},
+		{"flag", "output: ../shared\n", options{assetsOnly: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			tmpDir := filepath.Join(root, "project")
+			writeTestFiles(t, tmpDir, map[string]string{
+				"src/local.txt": "local",
+				// Files outside the package are fine, since nothing embeds them
+				"embed.yaml": tt.config + "files:\n  - " + server.URL + "/a/data.txt\n  - src/local.txt\n",
+			})
+			if err := run(tmpDir, tt.opts, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			for name, want := range map[string]string{"data.txt": "remote", "local.txt": "local"} {
+				got, err := os.ReadFile(filepath.Join(root, "shared", name))
+				if err != nil || string(got) != want {
+					t.Errorf("shared/%s = %q, %v; want %q", name, got, err, want)
+				}
+			}
+			for _, name := range []string{"embed.go", "none"} {
+				if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+					t.Errorf("%s was written: %v", name, err)
+				}
+			}
+		})
+	}
+
+	errorTests := []struct {
+		name    string
+		config  string
+		opts    options
+		wantErr string
+	}{
+		{"literal", "go-output: none\nfiles:\n  - source: local.txt\n    literal: true\n", options{}, "literal assets are rendered into go-output"},
+		{"diff", "files:\n  - local.txt\n", options{assetsOnly: true, diff: true}, "-diff compares go-output"},
+		{"manifest", "go-output: none\nmanifest-go: manifest.go\nfiles:\n  - local.txt\n", options{}, "manifest-go is written next to go-output"},
+	}
+	for _, tt := range errorTests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"local.txt": "local", "embed.yaml": tt.config})
+			err := run(tmpDir, tt.opts, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("run() error = %v, want it to contain %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunStrictEnv(t *testing.T) {
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte("content"))
+	}))
+	defer server.Close()
+	t.Setenv("EMBED_TEST_HOST", strings.TrimPrefix(server.URL, "http://"))
+	t.Setenv("EMBED_TEST_EMPTY", "")
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+github-token: $EMBED_TEST_TOKEN
+files:
+  - http://${EMBED_TEST_HOST}/$EMBED_TEST_DIR/config.xml
+  - source: http://$EMBED_TEST_HOST/users.json
+    mirrors:
+      - http://$EMBED_TEST_HOST/$EMBED_TEST_EMPTY/users.json
+  - source: "data:text/plain,$NOT_A_VARIABLE"
+    name: note.txt
+  - http://$EMBED_TEST_HOST/${EMBED_TEST_OPTIONAL:-optional.json}
+`,
+	})
+	err := run(tmpDir, options{strictEnv: true}, io.Discard)
+	want := "environment variables referenced in embed.yaml are not set: EMBED_TEST_TOKEN, EMBED_TEST_DIR, EMBED_TEST_EMPTY"
+	if err == nil || err.Error() != want {
+		t.Errorf("run() error = %v, want %q", err, want)
+	}
+	if requests != 0 {
+		t.Errorf("requests = %d, want none before the unset variables are reported", requests)
+	}
+
+	// Variables from .env count as set
+	writeTestFiles(t, tmpDir, map[string]string{".env": "EMBED_TEST_TOKEN=secret\nEMBED_TEST_DIR=v1\nEMBED_TEST_EMPTY=mirror\n"})
+	if err := run(tmpDir, options{strictEnv: true}, io.Discard); err != nil {
+		t.Errorf("run() error: %v", err)
+	}
+}
+
+func TestRunSourceType(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("remote " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	t.Run("forced local", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"schemas/local.json": "local",
+			"embed.yaml": `output: assets
+go-mod: main
+github:
+  owner: owner
+  repo: repo
+files:
+  - source: schemas/local.json
+    type: local
+  - schemas/remote.json
+`,
+		})
+		// Without type the path would be a file of the github repository
+		var out bytes.Buffer
+		if err := run(tmpDir, options{list: true}, &out); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if !strings.Contains(out.String(), "assets/local.json   schemas/local.json\n") || !strings.Contains(out.String(), "https://raw.githubusercontent.com/owner/repo/HEAD/schemas/remote.json") {
+			t.Errorf("output =\n%s\nwant only remote.json resolved to the repository", out.String())
+		}
+	})
+
+	t.Run("forced url", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{
+			"users.json": "local",
+			"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: $USERS_URL\n    type: url\n",
+		})
+		t.Setenv("USERS_URL", server.URL+"/users.json")
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		if data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "users.json")); err != nil || string(data) != "remote /users.json" {
+			t.Errorf("users.json = %q, %v; want the download", data, err)
+		}
+
+		// A variable pointing at a local file is not silently read
+		t.Setenv("USERS_URL", "users.json")
+		err := run(tmpDir, options{}, io.Discard)
+		if err == nil || !strings.Contains(err.Error(), "type url needs an http(s) URL, got users.json") {
+			t.Errorf("run() error = %v, want the local path rejected", err)
+		}
+	})
+
+	t.Run("unsupported", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\nfiles:\n  - source: s3://bucket/a.json\n    type: s3\n"})
+		_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+		if err == nil || !strings.Contains(err.Error(), `files[0]: invalid type "s3": must be url or local`) {
+			t.Errorf("loadConfig() error = %v, want the type rejected", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+  "encoding/json"
+  "fmt"
+  "go/format"
+  "path/filepath"
+  "runtime/debug"
+  "strings"
+  "time"
+)
+
+// toolVersion returns the module version of this tool, or "devel" for a local build
+func toolVersion() string {
+  if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+    return info.Main.Version
+  }
+  return "devel"
+}
+
+// generateManifestGo renders the manifest-go file: the tool version, the generation time
+// and the (redacted) source of every embedded asset as runtime-queryable values
+func generateManifestGo(pkgName string, assets []asset, generatedAt time.Time) (string, error) {
+  var b strings.Builder
+  fmt.Fprintf(&b, "%s\n\npackage %s\n\nimport \"time\"\n\n", generatedMarker, pkgName)
+  b.WriteString("// AssetManifest describes how the embedded assets were generated.\n")
+  b.WriteString("var AssetManifest = struct {\n\t// ToolVersion is the version of remoteembed that generated the assets\n\tToolVersion string\n")
+  b.WriteString("\t// GeneratedAt is the generation time, or SOURCE_DATE_EPOCH when set\n\tGeneratedAt time.Time\n")
+  b.WriteString("\t// Sources lists the source URL or local path of every embedded asset, in config order\n\tSources []string\n}{\n")
+  fmt.Fprintf(&b, "\tToolVersion: %q,\n", toolVersion())
+  fmt.Fprintf(&b, "\tGeneratedAt: time.Unix(%d, 0).UTC(),\n", generatedAt.Unix())
+  b.WriteString("\tSources: []string{\n")
+  for _, a := range assets {
+    fmt.Fprintf(&b, "\t\t%q,\n", redactURL(a.expandedURL))
+  }
+  b.WriteString("\t},\n}\n")
+
+  src, err := format.Source([]byte(b.String()))
+  if err != nil {
+    return "", fmt.Errorf("failed to format generated manifest: %v", err)
+  }
+  return string(src), nil
+}
+
+// manifestEntry describes an embedded asset in a -manifest file
+type manifestEntry struct {
+  Var    string `json:"var"`
+  Path   string `json:"path,omitempty"` // embed path relative to go-output; empty for literal assets
+  Source string `json:"source"`
+  Doc    string `json:"doc,omitempty"`
+}
+
+// manifestFormat returns the format of a -manifest file by its extension, "json" or "markdown", or "" when unsupported
+func manifestFormat(path string) string {
+  switch strings.ToLower(filepath.Ext(path)) {
+  case ".json":
+    return "json"
+  case ".md", ".markdown":
+    return "markdown"
+  }
+  return ""
+}
+
+// renderAssetManifest renders the -manifest file listing the variable name, embed path, (redacted) source
+// and doc of every asset in config order, as JSON or as a Markdown table
+func renderAssetManifest(format string, assets []asset) ([]byte, error) {
+  entries := make([]manifestEntry, len(assets))
+  for i, a := range assets {
+    entries[i] = manifestEntry{Var: a.varName, Source: redactURL(a.expandedURL), Doc: strings.TrimSpace(a.entry.Doc)}
+    if !a.entry.Literal {
+      entries[i].Path = a.relEmbedPath
+    }
+  }
+  if format == "json" {
+    data, err := json.MarshalIndent(entries, "", "  ")
+    if err != nil {
+      return nil, fmt.Errorf("failed to encode manifest: %v", err)
+    }
+    return append(data, '\n'), nil
+  }
+  var b strings.Builder
+  b.WriteString("| Variable | Embed path | Source | Description |\n|----------|------------|--------|-------------|\n")
+  for _, e := range entries {
+    path := e.Path
+    if path == "" {
+      path = "(literal)"
+    }
+    fmt.Fprintf(&b, "| `%s` | `%s` | %s | %s |\n", e.Var, path, markdownCell(e.Source), markdownCell(e.Doc))
+  }
+  return []byte(b.String()), nil
+}
+
+// markdownCell escapes text for a Markdown table cell, which has to stay on one line
+func markdownCell(s string) string {
+  s = strings.ReplaceAll(s, "|", `\|`)
+  return strings.Join(strings.Fields(s), " ")
+}
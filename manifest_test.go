@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestManifestGo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/local.txt": "local",
+		"embed.yaml": `output: assets
+go-mod: main
+manifest-go: assets_manifest.go
+files:
+  - ` + server.URL + `/config.json?token=$API_TOKEN
+  - src/local.txt
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Println(AssetManifest.ToolVersion)
+	fmt.Println(AssetManifest.GeneratedAt.Unix())
+	for _, s := range AssetManifest.Sources {
+		fmt.Println(s)
+	}
+}
+`,
+	})
+	t.Setenv("API_TOKEN", "s3cret")
+	t.Setenv("SOURCE_DATE_EPOCH", "1700000000")
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	manifest, err := os.ReadFile(filepath.Join(tmpDir, "assets_manifest.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(manifest), generatedMarker+"\n") {
+		t.Errorf("manifest does not start with the generated marker:\n%s", manifest)
+	}
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if strings.Contains(string(embedGo), "AssetManifest") {
+		t.Error("the manifest should be kept out of embed.go")
+	}
+
+	expected := "devel\n1700000000\n" + server.URL + "/config.json?token=xxxxx\nsrc/local.txt\n"
+	if out := runGenerated(t, tmpDir); out != expected {
+		t.Errorf("program output =\n%s\nwant\n%s", out, expected)
+	}
+}
+
+func TestManifestGoMustBeAFileName(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "manifest-go: other/manifest.go\nfiles:\n  - a.txt\n"})
+	if _, err := loadConfig(filepath.Join(tmpDir, "embed.yaml")); err == nil || !strings.Contains(err.Error(), "invalid manifest-go") {
+		t.Errorf("loadConfig() error = %v, want manifest-go to be rejected", err)
+	}
+}
+
+func TestAssetManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json": "[]",
+		"src/notes.txt":  "notes",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/users.json
+    doc: Users seeded | on first start.
+  - source: src/notes.txt
+    literal: true
+`,
+	})
+	jsonPath := filepath.Join(tmpDir, "docs", "assets.json")
+	if err := run(tmpDir, options{manifest: jsonPath}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(jsonPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []manifestEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatalf("invalid JSON manifest: %v\n%s", err, data)
+	}
+	want := []manifestEntry{
+		{Var: "Users", Path: "assets/users.json", Source: "src/users.json", Doc: "Users seeded | on first start."},
+		{Var: "Notes", Source: "src/notes.txt"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("manifest = %+v, want %+v", entries, want)
+	}
+
+	mdPath := filepath.Join(tmpDir, "ASSETS.md")
+	if err := run(tmpDir, options{manifest: mdPath}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err = os.ReadFile(mdPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantMD := "| Variable | Embed path | Source | Description |\n|----------|------------|--------|-------------|\n" +
+		"| `Users` | `assets/users.json` | src/users.json | Users seeded \\| on first start. |\n" +
+		"| `Notes` | `(literal)` | src/notes.txt |  |\n"
+	if string(data) != wantMD {
+		t.Errorf("Markdown manifest =\n%s\nwant\n%s", data, wantMD)
+	}
+}
+
+func TestManifestFormat(t *testing.T) {
+	for path, want := range map[string]string{"assets.json": "json", "docs/ASSETS.MD": "markdown", "assets.markdown": "markdown", "assets.yaml": ""} {
+		if got := manifestFormat(path); got != want {
+			t.Errorf("manifestFormat(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
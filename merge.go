@@ -0,0 +1,152 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "errors"
+  "fmt"
+  "io"
+  "net/http"
+  "os"
+  "path"
+  "strings"
+  "time"
+)
+
+// MergeEntry deep-merges the JSON objects of Files, in order, into one file named Into
+type MergeEntry struct {
+  Into  string   `yaml:"into"`
+  Files []string `yaml:"files"`
+}
+
+// validateMergeEntry checks a merge entry and the options that do not apply to it
+func validateMergeEntry(f FileEntry) error {
+  switch {
+  case len(pathSegments(f.Merge.Into)) == 0:
+    return fmt.Errorf("merge into is required")
+  case len(f.Merge.Files) == 0:
+    return fmt.Errorf("merge needs at least one file")
+  case f.Recursive:
+    return fmt.Errorf("recursive is only supported for local directories")
+  case f.API:
+    return fmt.Errorf("api requires a github file")
+  case len(f.Mirrors) > 0:
+    return fmt.Errorf("mirrors are not supported for merge entries")
+  case f.Range != "":
+    return fmt.Errorf("range is not supported for merge entries")
+  }
+  for _, file := range f.Merge.Files {
+    if isDataURI(file) {
+      return fmt.Errorf("merge files cannot be data: sources")
+    }
+  }
+  return nil
+}
+
+// expandMerge returns the single file a merge entry produces. Its source is reported as merge:<into>
+func expandMerge(entry *FileEntry) []fileInfo {
+  name := strings.Join(pathSegments(entry.Merge.Into), "/")
+  source := "merge:" + name
+  return []fileInfo{{originalURL: source, expandedURL: source, sourcePath: name, shortName: path.Base(name), entry: entry}}
+}
+
+// mergeSources returns the files of a merge entry with environment variables expanded and paths in the github repository resolved
+func mergeSources(entry *FileEntry, gh *GitHubSource) []string {
+  sources := make([]string, len(entry.Merge.Files))
+  for i, file := range entry.Merge.Files {
+    sources[i] = resolveFileURL(expandEnvVars(file), gh)
+  }
+  return sources
+}
+
+// fetchMerged reads or downloads the files of a merge entry and deep-merges them. The merged document
+// is transformed and verified like any other content, and its modification time is that of the newest file
+func fetchMerged(client *http.Client, cfg *EmbedConfig, opts options, baseDir string, a asset) (sourceFile, error) {
+  fetchOpts, err := assetFetchOptions(cfg, a)
+  if err != nil {
+    return sourceFile{}, err
+  }
+  var merged map[string]any
+  var modTime time.Time
+  for _, file := range mergeSources(a.entry, cfg.GitHub) {
+    var f sourceFile
+    if isRemoteURL(file) {
+      var ok bool
+      if f, ok = opts.remoteCache.get(file); !ok {
+        if f, err = fetchRemote(client, file, fetchOpts); err != nil {
+          return sourceFile{}, err
+        }
+        opts.remoteCache.put(file, f)
+      }
+    } else {
+      local := resolvePath(baseDir, file)
+      if f.data, err = readLocalFile(local); err != nil {
+        return sourceFile{}, err
+      }
+      if info, err := os.Stat(local); err == nil {
+        f.modTime = info.ModTime()
+      }
+    }
+    doc, err := parseJSONObject(f.data)
+    if err != nil {
+      return sourceFile{}, fmt.Errorf("%s: failed to merge %s: %v", a.expandedURL, file, err)
+    }
+    merged = mergeJSON(merged, doc)
+    if f.modTime.After(modTime) {
+      modTime = f.modTime
+    }
+  }
+  var buf bytes.Buffer
+  enc := json.NewEncoder(&buf)
+  enc.SetEscapeHTML(false)
+  enc.SetIndent("", "  ")
+  if err := enc.Encode(merged); err != nil {
+    return sourceFile{}, fmt.Errorf("%s: %v", a.expandedURL, err)
+  }
+  data, err := transformContent(cfg, baseDir, a, buf.Bytes())
+  if err != nil {
+    return sourceFile{}, err
+  }
+  if a.entry.checksum != nil {
+    if err := a.entry.checksum.verify(data); err != nil {
+      return sourceFile{}, fmt.Errorf("%s: %v", a.expandedURL, err)
+    }
+  }
+  return sourceFile{data: data, modTime: modTime}, nil
+}
+
+// parseJSONObject decodes data, which has to be a single JSON object. Numbers keep their exact text
+func parseJSONObject(data []byte) (map[string]any, error) {
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.UseNumber()
+  var doc any
+  if err := dec.Decode(&doc); err != nil {
+    return nil, fmt.Errorf("invalid JSON: %v", err)
+  }
+  if err := dec.Decode(new(any)); !errors.Is(err, io.EOF) {
+    return nil, fmt.Errorf("invalid JSON: data after the top-level value")
+  }
+  obj, ok := doc.(map[string]any)
+  if !ok {
+    return nil, fmt.Errorf("the top-level value is not an object")
+  }
+  return obj, nil
+}
+
+// mergeJSON merges src into dst and returns dst. Objects present in both are merged recursively;
+// any other value of src, including arrays and null, replaces the one in dst
+func mergeJSON(dst, src map[string]any) map[string]any {
+  if dst == nil {
+    return src
+  }
+  for key, value := range src {
+    if srcObj, ok := value.(map[string]any); ok {
+      if dstObj, ok := dst[key].(map[string]any); ok {
+        dst[key] = mergeJSON(dstObj, srcObj)
+        continue
+      }
+    }
+    dst[key] = value
+  }
+  return dst
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeJSON(t *testing.T) {
+	base, err := parseJSONObject([]byte(`{"name": "app", "db": {"host": "localhost", "port": 5432, "options": {"ssl": false}}, "tags": ["a", "b"], "debug": true}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	override, err := parseJSONObject([]byte(`{"db": {"host": "db.internal", "options": {"timeout": 30}}, "tags": ["c"], "debug": null, "region": "eu"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged, err := json.Marshal(mergeJSON(base, override))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Objects merge recursively; scalars, arrays and null from the later document win
+	want := `{"db":{"host":"db.internal","options":{"ssl":false,"timeout":30},"port":5432},"debug":null,"name":"app","region":"eu","tags":["c"]}`
+	if string(merged) != want {
+		t.Errorf("merged = %s, want %s", merged, want)
+	}
+}
+
+func TestParseJSONObject(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"object", `{"a": 1}`, ""},
+		{"array", `[1, 2]`, "the top-level value is not an object"},
+		{"invalid", `{"a": }`, "invalid JSON"},
+		{"trailing data", `{"a": 1} {"b": 2}`, "data after the top-level value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseJSONObject([]byte(tt.data))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("parseJSONObject(%q) error = %v, want %q", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunMerge(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"features": {"beta": true}, "url": "https://example.com/?a=1&b=<2>"}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"config/base.json":  `{"features": {"beta": false, "search": true}, "limits": {"rate": 10}}`,
+		"config/local.json": `{"limits": {"rate": 100}}`,
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - merge:
+      into: config.json
+      files:
+        - config/base.json
+        - ` + server.URL + `/remote.json
+        - config/local.json
+`,
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	fmt.Print(Config)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	want := `{
+  "features": {
+    "beta": true,
+    "search": true
+  },
+  "limits": {
+    "rate": 100
+  },
+  "url": "https://example.com/?a=1&b=<2>"
+}
+`
+	data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "config.json"))
+	if err != nil || string(data) != want {
+		t.Errorf("config.json = %s, %v; want %s", data, err, want)
+	}
+	if out := runGenerated(t, tmpDir); out != want {
+		t.Errorf("Config = %s, want %s", out, want)
+	}
+
+	// A file that is not an object cannot be merged
+	writeTestFiles(t, tmpDir, map[string]string{"config/local.json": `["rate"]`})
+	err = run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "merge:config.json: failed to merge config/local.json: the top-level value is not an object") {
+		t.Errorf("run() error = %v, want the non-object file reported", err)
+	}
+}
+
+func TestMergeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr string
+	}{
+		{"with source", "  - source: a.json\n    merge:\n      into: c.json\n      files: [a.json]\n", "merge cannot be combined with source, github or index"},
+		{"no into", "  - merge:\n      files: [a.json]\n", "merge into is required"},
+		{"no files", "  - merge:\n      into: c.json\n", "merge needs at least one file"},
+		{"mirrors", "  - merge:\n      into: c.json\n      files: [a.json]\n    mirrors: [https://example.com/c.json]\n", "mirrors are not supported for merge entries"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\nfiles:\n" + tt.entry})
+			_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
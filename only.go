@@ -0,0 +1,108 @@
+package main
+
+import (
+  "fmt"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+)
+
+// onlyNames splits the value of -only into the variable names and sources it lists
+func onlyNames(value string) []string {
+  var names []string
+  for _, name := range strings.Split(value, ",") {
+    if name = strings.TrimSpace(name); name != "" {
+      names = append(names, name)
+    }
+  }
+  return names
+}
+
+// matchesOnly reports whether a is named by one of names: by variable name, unique path,
+// source as written in the config, or expanded source
+func matchesOnly(a asset, names []string) bool {
+  for _, name := range names {
+    if name == a.varName || name == a.uniquePath || name == a.originalURL || name == filepath.ToSlash(a.expandedURL) {
+      return true
+    }
+  }
+  return false
+}
+
+// checkOnly fails when a name given to -only matches none of the assets
+func checkOnly(assets []asset, names []string) error {
+  for _, name := range names {
+    found := false
+    for _, a := range assets {
+      if matchesOnly(a, []string{name}) {
+        found = true
+        break
+      }
+    }
+    if !found {
+      return fmt.Errorf("-only %s matches no file: use a variable name or a source from the config", name)
+    }
+  }
+  return nil
+}
+
+// previousOutput is what an earlier run generated, used by -only to keep the files it does not refresh
+type previousOutput struct {
+  literals map[string][]byte    // content of literal assets by variable name
+  modTimes map[string]time.Time // <Var>ModTime values by variable name
+  lock     *lockFile            // previous lockfile; nil when there is none
+}
+
+// readPreviousOutput reads the resolved URLs, literals and modification times recorded in the existing lockfile.
+// A missing lockfile leaves them empty
+func readPreviousOutput(lockPath string) (*previousOutput, error) {
+  prev := &previousOutput{literals: map[string][]byte{}, modTimes: map[string]time.Time{}}
+  if _, err := os.Stat(lockPath); err != nil {
+    return prev, nil
+  }
+  lock, err := readLockFile(lockPath)
+  if err != nil {
+    return nil, err
+  }
+  prev.lock = lock
+  for _, e := range lock.Assets {
+    if e.Literal != "" {
+      data, err := decompressLiteral(e.Literal)
+      if err != nil {
+        return nil, fmt.Errorf("failed to read literal %s from %s: %v", e.Var, lockPath, err)
+      }
+      prev.literals[e.Var] = data
+    }
+    if !e.ModTime.IsZero() {
+      prev.modTimes[e.Var] = e.ModTime.UTC()
+    }
+  }
+  return prev, nil
+}
+
+// keptFile returns the content an earlier run generated for a, instead of fetching it again
+func (prev *previousOutput) keptFile(cfg *EmbedConfig, a asset) (sourceFile, error) {
+  var f sourceFile
+  if a.entry.Literal {
+    data, ok := prev.literals[a.varName]
+    if !ok {
+      return sourceFile{}, fmt.Errorf("%s is not in the lockfile: run without -only to generate it", a.varName)
+    }
+    f.data = data
+  } else {
+    data, err := os.ReadFile(a.localFile)
+    if err != nil {
+      return sourceFile{}, fmt.Errorf("%s has not been generated yet: run without -only to generate it", a.localFile)
+    }
+    f.data = data
+  }
+  f.modTime = prev.modTimes[a.varName]
+  f.resolved = a.expandedURL
+  if prev.lock != nil {
+    if e, ok := prev.lock.lookup(a.expandedURL, cfg.lockTransform(a.entry)); ok && e.Resolved != "" {
+      f.resolved = e.Resolved
+    }
+  }
+  return f, nil
+}
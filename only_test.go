@@ -0,0 +1,145 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunOnly(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	version := "v1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits[r.URL.Path]++
+		w.Header().Set("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		w.Write([]byte(r.URL.Path + " " + version))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+mod-times: true
+lockfile: embed.lock
+files:
+  - ` + server.URL + `/config.xml
+  - ` + server.URL + `/users.json
+  - source: ` + server.URL + `/notes.txt
+    literal: true
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	read := func(name string) string {
+		data, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+	firstEmbedGo := read("embed.go")
+	firstLock := read("embed.lock")
+
+	tests := []struct {
+		name    string
+		only    string
+		fetched string
+	}{
+		{"by variable name", "Config", "/config.xml"},
+		{"by source", server.URL + "/users.json", "/users.json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mu.Lock()
+			version = "v2 " + tt.name
+			clear(hits)
+			mu.Unlock()
+			if err := run(tmpDir, options{only: tt.only}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if len(hits) != 1 || hits[tt.fetched] != 1 {
+				t.Errorf("requests = %v, want only %s", hits, tt.fetched)
+			}
+			name := strings.TrimPrefix(tt.fetched, "/")
+			if got, want := read("assets/"+name), tt.fetched+" "+version; got != want {
+				t.Errorf("%s = %q, want %q", name, got, want)
+			}
+		})
+	}
+
+	// Each refresh left the other files alone: users.json keeps the content of the second run
+	if got := read("assets/users.json"); got == "/users.json v1" {
+		t.Errorf("users.json was not refreshed")
+	}
+	// The literal was never re-fetched, so embed.go still carries it along with the previous modification times
+	embedGo := read("embed.go")
+	for _, line := range strings.Split(firstEmbedGo, "\n") {
+		if strings.Contains(line, "decodeAsset(\"") || strings.Contains(line, "ModTime =") {
+			if !strings.Contains(embedGo, line) {
+				t.Errorf("embed.go lost %q", line)
+			}
+		}
+	}
+	if lock := read("embed.lock"); lock == firstLock || !strings.Contains(lock, "/notes.txt") {
+		t.Errorf("embed.lock =\n%s\nwant the refreshed checksums and the kept notes.txt entry", lock)
+	}
+
+	err := run(tmpDir, options{only: "Missing"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "-only Missing matches no file") {
+		t.Errorf("run() error = %v, want an unknown name to be rejected", err)
+	}
+}
+
+func TestRunOnlyRequiresLockfile(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"notes.txt":  "notes",
+		"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - notes.txt\n",
+	})
+	err := run(tmpDir, options{only: "Notes"}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "-only requires a lockfile") {
+		t.Errorf("run() error = %v, want a lockfile to be required", err)
+	}
+}
+
+func TestReadPreviousOutputBytesLiteral(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"logo.bin":  "\x00\x01binary",
+		"notes.txt": "notes",
+		"embed.yaml": `output: assets
+go-mod: main
+var-type: auto
+lockfile: embed.lock
+files:
+  - source: logo.bin
+    literal: true
+  - source: notes.txt
+    literal: true
+    type-name: Note
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	prev, err := readPreviousOutput(filepath.Join(tmpDir, "embed.lock"))
+	if err != nil {
+		t.Fatalf("readPreviousOutput() error: %v", err)
+	}
+	if got := string(prev.literals["Logo"]); got != "\x00\x01binary" {
+		t.Errorf("literal Logo = %q, want the []byte literal to be read back", got)
+	}
+	if got := string(prev.literals["Notes"]); got != "notes" {
+		t.Errorf("literal Notes = %q, want the literal converted to its type-name to be read back", got)
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+  "fmt"
+  "go/build"
+  "go/token"
+  "os"
+  "path/filepath"
+  "strings"
+  "unicode"
+)
+
+// detectPackageName returns the package clause for the generated file. With strict-package it
+// fails instead of falling back to a guessed name
+func detectPackageName(baseDir string, cfg *EmbedConfig) (string, error) {
+  pkgName, confident := inferPackageName(baseDir, cfg)
+  if !confident && cfg.StrictPackage {
+    return "", fmt.Errorf("failed to detect the package of %s: set go-mod, add a go.mod or a .go file next to it (strict-package is enabled)", cfg.GoOutput)
+  }
+  if !strings.HasSuffix(pkgName, cfg.PackageSuffix) {
+    pkgName += cfg.PackageSuffix
+  }
+  return pkgName, nil
+}
+
+// inferPackageName returns the package name and whether it came from the config, go.mod or
+// existing Go files rather than a fallback
+func inferPackageName(baseDir string, cfg *EmbedConfig) (string, bool) {
+  if strings.TrimSpace(cfg.GoMod) != "" {
+    return strings.TrimSpace(cfg.GoMod), true
+  }
+
+  // A generated file in a subdirectory belongs to that directory's package,
+  // named after the directory when it has no Go files yet
+  targetDir := filepath.Dir(filepath.Join(baseDir, cfg.GoOutput))
+  if targetDir != filepath.Clean(baseDir) {
+    if name, consistent := scanPackageName(targetDir, filepath.Base(cfg.GoOutput)); name != "" {
+      return name, consistent
+    }
+    return sanitizePackageName(filepath.Base(targetDir)), false
+  }
+
+  // Try go.mod first
+  gomodPath := filepath.Join(baseDir, "go.mod")
+  if data, err := os.ReadFile(gomodPath); err == nil {
+    lines := strings.Split(string(data), "\n")
+    for _, l := range lines {
+      l = strings.TrimSpace(l)
+      if strings.HasPrefix(l, "module ") {
+        parts := strings.Split(l, "/")
+        pkgName := parts[len(parts)-1]
+        return strings.ReplaceAll(pkgName, "-", "_"), true
+      }
+    }
+    return "main", false
+  }
+  // Scan all .go files in baseDir for package name
+  if name, consistent := scanPackageName(baseDir, cfg.GoOutput); name != "" {
+    return name, consistent
+  }
+  return "main", false
+}
+
+// scanPackageName returns the most common package clause of the .go files in dir, the alphabetically first
+// on a tie, ignoring the generated file, or "" when there are none. Files excluded from the current build by their
+// //go:build constraints or GOOS/GOARCH name suffixes, such as a //go:build ignore generator, are skipped like go build does.
+// External _test packages only count, without their suffix, when the directory has no other package.
+// consistent reports whether all files agree on it
+func scanPackageName(dir, goOutputName string) (pkgName string, consistent bool) {
+  entries, err := os.ReadDir(dir)
+  if err != nil {
+    return "", false
+  }
+  pkgCount := map[string]int{}
+  testPkgCount := map[string]int{}
+  for _, entry := range entries {
+    // Only consider .go files that are not embed.go and not generated (e.g., only main.go)
+    if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), ".go") && entry.Name() != goOutputName && entry.Name() != "embed.go" {
+      if match, err := build.Default.MatchFile(dir, entry.Name()); err == nil && !match {
+        continue
+      }
+      filePath := filepath.Join(dir, entry.Name())
+      data, err := os.ReadFile(filePath)
+      if err == nil {
+        lines := strings.Split(string(data), "\n")
+        for _, l := range lines {
+          l = strings.TrimSpace(l)
+          if strings.HasPrefix(l, "package ") {
+            name := strings.TrimPrefix(l, "package ")
+            name = strings.Fields(name)[0]
+            // External test packages name the package they test
+            if base, ok := strings.CutSuffix(name, "_test"); ok {
+              testPkgCount[base]++
+            } else {
+              pkgCount[name]++
+            }
+            break
+          }
+        }
+      }
+    }
+  }
+  if len(pkgCount) == 0 {
+    pkgCount = testPkgCount
+  }
+  // Use the most common package name; a tie goes to the alphabetically first one, so every run picks the same
+  maxCount := 0
+  for name, count := range pkgCount {
+    if count > maxCount || count == maxCount && name < pkgName {
+      pkgName = name
+      maxCount = count
+    }
+  }
+  return pkgName, len(pkgCount) == 1
+}
+
+// sanitizePackageName turns a directory name into a valid package identifier
+func sanitizePackageName(name string) string {
+  var b strings.Builder
+  for _, r := range strings.ToLower(name) {
+    if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+      b.WriteRune(r)
+    } else {
+      b.WriteRune('_')
+    }
+  }
+  pkgName := strings.Trim(b.String(), "_")
+  if pkgName == "" {
+    return "main"
+  }
+  if unicode.IsDigit(rune(pkgName[0])) {
+    pkgName = "_" + pkgName
+  }
+  if token.IsKeyword(pkgName) {
+    pkgName += "_"
+  }
+  return pkgName
+}
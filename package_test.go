@@ -0,0 +1,206 @@
+package main
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetectPackageNameEmptyNestedTarget(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"go.mod":  "module github.com/example/my-app\n",
+		"main.go": "package main\n",
+	})
+
+	cfg := &EmbedConfig{GoOutput: filepath.Join("internal", "assets", "embed.go")}
+	if name, _ := detectPackageName(tmpDir, cfg); name != "assets" {
+		t.Errorf("detectPackageName() = %q, want %q", name, "assets")
+	}
+
+	// Existing Go files in the target directory win over its name
+	writeTestFiles(t, tmpDir, map[string]string{
+		"internal/assets/doc.go": "// Package static holds assets.\npackage static\n",
+	})
+	if name, _ := detectPackageName(tmpDir, cfg); name != "static" {
+		t.Errorf("detectPackageName() = %q, want %q", name, "static")
+	}
+
+	// The module root still uses go.mod
+	if name, _ := detectPackageName(tmpDir, &EmbedConfig{GoOutput: "embed.go"}); name != "my_app" {
+		t.Errorf("detectPackageName() = %q, want %q", name, "my_app")
+	}
+}
+
+func TestScanPackageNameTie(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"doc.go":       "// Package zeta holds assets.\npackage zeta\n",
+		"helpers.go":   "package zeta\n",
+		"legacy.go":    "package alpha\n",
+		"legacy2.go":   "package alpha\n",
+		"other.go":     "package mid\n",
+		"doc_test.go":  "package aaa_test\n",
+		"embed.go":     "package aaa\n",
+		"generated.go": "package aaa\n",
+	})
+	// Map iteration order differs between runs, so a single run could pick the right name by chance
+	for range 20 {
+		name, consistent := scanPackageName(tmpDir, "generated.go")
+		if name != "alpha" || consistent {
+			t.Fatalf("scanPackageName() = %q, %v, want \"alpha\", false", name, consistent)
+		}
+	}
+}
+
+func TestScanPackageNameBuildConstraints(t *testing.T) {
+	otherOS := "windows"
+	if runtime.GOOS == otherOS {
+		otherOS = "linux"
+	}
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"assets.go":                 "package assets\n",
+		"gen.go":                    "//go:build ignore\n\npackage main\n",
+		"gen_data.go":               "//go:build ignore\n\npackage main\n",
+		"gen_tool.go":               "//go:build tools\n\npackage main\n",
+		"legacy.go":                 "// +build " + otherOS + "\n\npackage legacy\n",
+		"legacy_" + otherOS + ".go": "package legacy\n",
+		"current.go":                "//go:build " + runtime.GOOS + "\n\npackage assets\n",
+	})
+	// The excluded files would outnumber the package go build sees
+	name, consistent := scanPackageName(tmpDir, "embed.go")
+	if name != "assets" || !consistent {
+		t.Errorf("scanPackageName() = %q, %v, want \"assets\", true", name, consistent)
+	}
+}
+
+func TestDetectPackageNameStrict(t *testing.T) {
+	tmpDir := t.TempDir()
+	cfg := &EmbedConfig{GoOutput: "embed.go", StrictPackage: true}
+
+	// An empty directory has nothing to detect the package from
+	if _, err := detectPackageName(tmpDir, cfg); err == nil || !strings.Contains(err.Error(), "strict-package") {
+		t.Fatalf("detectPackageName() error = %v, want strict-package error", err)
+	}
+	cfg.StrictPackage = false
+	if name, err := detectPackageName(tmpDir, cfg); err != nil || name != "main" {
+		t.Errorf("detectPackageName() = %q, %v, want %q", name, err, "main")
+	}
+
+	// Go files disagreeing on the package are not a confident answer either
+	cfg.StrictPackage = true
+	writeTestFiles(t, tmpDir, map[string]string{
+		"a.go":      "package foo\n",
+		"a_test.go": "package foo_test\n",
+		"b.go":      "package bar\n",
+	})
+	if _, err := detectPackageName(tmpDir, cfg); err == nil {
+		t.Error("detectPackageName() should fail on inconsistent package clauses")
+	}
+	if err := os.Remove(filepath.Join(tmpDir, "b.go")); err != nil {
+		t.Fatal(err)
+	}
+	if name, err := detectPackageName(tmpDir, cfg); err != nil || name != "foo" {
+		t.Errorf("detectPackageName() = %q, %v, want %q", name, err, "foo")
+	}
+
+	// A nested target named only after its directory is a guess
+	cfg.GoOutput = filepath.Join("internal", "assets", "embed.go")
+	if _, err := detectPackageName(tmpDir, cfg); err == nil {
+		t.Error("detectPackageName() should fail for a nested target without Go files")
+	}
+	cfg.GoMod = "assets"
+	if name, err := detectPackageName(tmpDir, cfg); err != nil || name != "assets" {
+		t.Errorf("detectPackageName() = %q, %v, want %q", name, err, "assets")
+	}
+}
+
+func TestScanPackageNameExternalTests(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"assets.go":       "package assets\n",
+		"assets_test.go":  "package assets_test\n",
+		"example_test.go": "package assets_test\n",
+	})
+	// External test packages outnumbering the package must not win, nor make it inconsistent
+	name, consistent := scanPackageName(tmpDir, "embed.go")
+	if name != "assets" || !consistent {
+		t.Errorf("scanPackageName() = %q, %v, want \"assets\", true", name, consistent)
+	}
+}
+
+func TestSanitizePackageName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"assets", "assets"},
+		{"web-assets", "web_assets"},
+		{"My.Assets", "my_assets"},
+		{"3d", "_3d"},
+		{"type", "type_"},
+		{"---", "main"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if result := sanitizePackageName(tt.input); result != tt.expected {
+				t.Errorf("sanitizePackageName(%q) = %q, want %q", tt.input, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestPackageSuffixTest(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"go.mod":                "module example.com/lib\n\ngo 1.24\n",
+		"lib.go":                "package lib\n",
+		"testdata/fixture.json": `{"ok":true}`,
+		"embed.yaml": `output: fixtures
+package-suffix: _test
+files:
+  - testdata/fixture.json
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	// The default go-output is a test file, so the production build does not see the embeds
+	data, err := os.ReadFile(filepath.Join(tmpDir, "embed_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "\npackage lib_test\n") {
+		t.Errorf("embed_test.go does not declare package lib_test:\n%s", data)
+	}
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not available")
+	}
+	writeTestFiles(t, tmpDir, map[string]string{
+		"lib_test.go": "package lib_test\n\nimport \"testing\"\n\nfunc TestFixture(t *testing.T) {\n\tif Fixture != `{\"ok\":true}` {\n\t\tt.Fatal(Fixture)\n\t}\n}\n",
+	})
+	cmd := exec.Command(goBin, "test", ".")
+	cmd.Dir = tmpDir
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("go test failed: %v\n%s", err, out)
+	}
+}
+
+func TestPackageSuffixGoOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "go-output: embed.go\ngo-mod: lib_test\nfiles:\n  - a.txt\n",
+	})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err == nil || !strings.Contains(err.Error(), "must end in _test.go") {
+		t.Errorf("loadConfig() error = %v, want go-output to require _test.go", err)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+  "context"
+  "net/http"
+  "sync"
+  "sync/atomic"
+)
+
+// defaultConcurrency fetches one file at a time
+const defaultConcurrency = 1
+
+// fetchAll fetches assets with up to cfg.concurrency() workers. Local copies and downloads go through
+// the same pool, so a mixed config reads and downloads in parallel. Results are in the order of assets.
+// After a failure no new fetches start, and the error of the first asset (in config order) that failed is returned
+func fetchAll(ctx context.Context, client *http.Client, cfg *EmbedConfig, opts options, baseDir string, assets []asset) ([]sourceFile, error) {
+  files := make([]sourceFile, len(assets))
+  errs := make([]error, len(assets))
+  jobs := make(chan int)
+  var failed atomic.Bool
+  var wg sync.WaitGroup
+  for range min(cfg.concurrency(), len(assets)) {
+    wg.Add(1)
+    go func() {
+      defer wg.Done()
+      for i := range jobs {
+        if failed.Load() {
+          continue
+        }
+        if errs[i] = ctx.Err(); errs[i] == nil {
+          files[i], errs[i] = fetchAsset(client, cfg, opts, baseDir, assets[i])
+        }
+        if errs[i] != nil {
+          failed.Store(true)
+        }
+      }
+    }()
+  }
+  for i := range assets {
+    jobs <- i
+  }
+  close(jobs)
+  wg.Wait()
+  for _, err := range errs {
+    if err != nil {
+      return nil, err
+    }
+  }
+  return files, nil
+}
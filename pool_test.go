@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConcurrentFetchMixedSources(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			m := maxInFlight.Load()
+			if n <= m || maxInFlight.CompareAndSwap(m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.Write([]byte("remote " + r.URL.Path))
+	}))
+	defer server.Close()
+
+	files := map[string]string{}
+	var entries strings.Builder
+	for i := 0; i < 12; i++ {
+		if i%2 == 0 {
+			fmt.Fprintf(&entries, "  - %s/remote/file%02d.txt\n", server.URL, i)
+		} else {
+			name := fmt.Sprintf("local/file%02d.txt", i)
+			files["src/"+name] = "local " + name
+			fmt.Fprintf(&entries, "  - src/%s\n", name)
+		}
+	}
+	generate := func(concurrency int) string {
+		t.Helper()
+		tmpDir := t.TempDir()
+		files["embed.yaml"] = fmt.Sprintf("output: assets\ngo-mod: main\nconcurrency: %d\nlockfile: embed.lock\nfiles:\n%s", concurrency, entries.String())
+		writeTestFiles(t, tmpDir, files)
+		if err := run(tmpDir, options{}, io.Discard); err != nil {
+			t.Fatalf("run() error: %v", err)
+		}
+		for i := 0; i < 12; i++ {
+			want := fmt.Sprintf("remote /remote/file%02d.txt", i)
+			if i%2 == 1 {
+				want = fmt.Sprintf("local local/file%02d.txt", i)
+			}
+			data, err := os.ReadFile(filepath.Join(tmpDir, "assets", fmt.Sprintf("file%02d.txt", i)))
+			if err != nil || string(data) != want {
+				t.Errorf("file%02d.txt = %q (%v), want %q", i, data, err, want)
+			}
+		}
+		embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+		lock, _ := os.ReadFile(filepath.Join(tmpDir, "embed.lock"))
+		return string(embedGo) + strings.ReplaceAll(string(lock), server.URL, "")
+	}
+
+	sequential := generate(1)
+	if got := maxInFlight.Load(); got != 1 {
+		t.Errorf("concurrency 1 had %d downloads in flight", got)
+	}
+	maxInFlight.Store(0)
+	parallel := generate(8)
+	if got := maxInFlight.Load(); got < 2 {
+		t.Errorf("concurrency 8 had at most %d download in flight, want parallel downloads", got)
+	}
+	if parallel != sequential {
+		t.Errorf("parallel output differs from sequential output:\n%s\nwant\n%s", parallel, sequential)
+	}
+}
+
+func TestConcurrentFetchFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nconcurrency: 4\nfiles:\n" +
+		"  - " + server.URL + "/ok.txt\n  - " + server.URL + "/missing.txt\n  - " + server.URL + "/other.txt\n"})
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "missing.txt: 404") {
+		t.Errorf("run() error = %v, want the failing file", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "assets")); !os.IsNotExist(err) {
+		t.Error("a failed run should not write any asset")
+	}
+}
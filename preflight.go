@@ -0,0 +1,63 @@
+package main
+
+import (
+  "fmt"
+  "net/http"
+  "strings"
+)
+
+// preflight checks that every remote asset is reachable before anything is downloaded.
+// Each URL gets a HEAD request, falling back to a single-byte ranged GET when the server
+// does not allow HEAD. All broken URLs are reported together.
+func preflight(client *http.Client, cfg *EmbedConfig, assets []asset) error {
+  var broken []string
+  for _, a := range assets {
+    if !a.entry.isRemote(a.expandedURL) {
+      continue
+    }
+    opts, err := assetFetchOptions(cfg, a)
+    if err != nil {
+      return err
+    }
+    u := a.expandedURL
+    if a.githubAPI != nil {
+      u = a.githubAPI.contentsURL()
+    }
+    if err := checkURL(client, u, opts); err != nil {
+      broken = append(broken, fmt.Sprintf("  %s: %v", u, err))
+    }
+  }
+  if len(broken) > 0 {
+    return fmt.Errorf("preflight failed for %d URL(s):\n%s", len(broken), strings.Join(broken, "\n"))
+  }
+  return nil
+}
+
+// checkURL verifies that url answers with a 2xx status without downloading its body
+func checkURL(client *http.Client, url string, opts fetchOptions) error {
+  req, err := newRequest("HEAD", url, opts)
+  if err != nil {
+    return err
+  }
+  resp, err := client.Do(req)
+  if err != nil {
+    return err
+  }
+  resp.Body.Close()
+  if resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+    req, err = newRequest("GET", url, opts)
+    if err != nil {
+      return err
+    }
+    req.Header.Set("Range", "bytes=0-0")
+    resp, err = client.Do(req)
+    if err != nil {
+      return err
+    }
+    resp.Body.Close()
+  }
+  if resp.StatusCode < 200 || resp.StatusCode > 299 {
+    return fmt.Errorf("%s", describeStatus(resp))
+  }
+  return nil
+}
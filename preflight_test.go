@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRunPreflight(t *testing.T) {
+	var gets []string
+	var headAuth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "HEAD" {
+			headAuth = append(headAuth, r.Header.Get("X-Token"))
+		}
+		switch r.URL.Path {
+		case "/ok.txt":
+		case "/no-head.txt":
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			if r.Header.Get("Range") != "bytes=0-0" {
+				t.Errorf("fallback GET Range = %q, want %q", r.Header.Get("Range"), "bytes=0-0")
+			}
+			w.WriteHeader(http.StatusPartialContent)
+		case "/missing.txt", "/gone.txt":
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == "GET" && r.Header.Get("Range") == "" {
+			gets = append(gets, r.URL.Path)
+		}
+		w.Write([]byte("x"))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+preflight: true
+files:
+  - source: ` + server.URL + `/ok.txt
+    headers:
+      X-Token: secret
+  - ` + server.URL + `/no-head.txt
+  - ` + server.URL + `/missing.txt
+  - ` + server.URL + `/gone.txt
+`,
+	})
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil {
+		t.Fatal("expected preflight to fail")
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "2 URL(s)") || !strings.Contains(msg, "/missing.txt: 404") || !strings.Contains(msg, "/gone.txt: 404") {
+		t.Errorf("error does not report all broken URLs: %v", err)
+	}
+	if strings.Contains(msg, "/ok.txt") || strings.Contains(msg, "/no-head.txt") {
+		t.Errorf("error reports healthy URLs: %v", err)
+	}
+	if len(gets) != 0 {
+		t.Errorf("files were downloaded despite preflight failure: %v", gets)
+	}
+	if len(headAuth) == 0 || headAuth[0] != "secret" {
+		t.Errorf("HEAD request headers = %v, want configured X-Token", headAuth)
+	}
+}
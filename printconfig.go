@@ -0,0 +1,135 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "strings"
+
+  "gopkg.in/yaml.v3"
+)
+
+// keepZeroKeys are config keys printed even when zero, because zero is not their default
+// or overrides a default; they are only left out when unset
+var keepZeroKeys = map[string]bool{"max-redirects": true, "ensure-trailing-newline": true, "strip-bom": true}
+
+// printConfig writes the effective config as YAML: defaults applied and environment variables expanded,
+// with tokens, credentials in URLs and the values of secret headers redacted. Unset options are left out
+func printConfig(w io.Writer, cfg *EmbedConfig) error {
+  eff := *cfg
+  // The included configs are already merged in
+  eff.Includes = nil
+  if eff.Output == "" {
+    eff.Output = "."
+  }
+  maxRedirects := cfg.maxRedirects()
+  eff.MaxRedirects = &maxRedirects
+  eff.Concurrency = cfg.concurrency()
+  for _, token := range []*string{&eff.GithubToken, &eff.GitLabToken, &eff.BitbucketToken} {
+    *token = redactToken(*token)
+  }
+  if cfg.Tokens != nil {
+    eff.Tokens = map[string]string{}
+    for host, token := range cfg.Tokens {
+      eff.Tokens[host] = redactToken(token)
+    }
+  }
+  eff.Files = make([]FileEntry, len(cfg.Files))
+  for i, f := range cfg.Files {
+    if !f.isDataURI(f.Source) {
+      f.Source = redactURL(expandEnvVars(f.Source))
+    }
+    f.GitHub = expandEnvVars(f.GitHub)
+    f.Index = redactURL(expandEnvVars(f.Index))
+    if f.Mirrors != nil {
+      f.Mirrors = make([]string, len(cfg.Files[i].Mirrors))
+      for j, m := range cfg.Files[i].Mirrors {
+        f.Mirrors[j] = redactURL(expandEnvVars(m))
+      }
+    }
+    if f.Headers != nil {
+      f.Headers = make(map[string]string, len(cfg.Files[i].Headers))
+      for name, value := range cfg.Files[i].Headers {
+        if isSecretHeader(name) {
+          value = redactToken(value)
+        }
+        f.Headers[name] = value
+      }
+    }
+    eff.Files[i] = f
+  }
+
+  var doc yaml.Node
+  if err := doc.Encode(&eff); err != nil {
+    return fmt.Errorf("failed to encode config: %v", err)
+  }
+  dropUnset(&doc)
+  enc := yaml.NewEncoder(w)
+  enc.SetIndent(2)
+  if err := enc.Encode(&doc); err != nil {
+    return fmt.Errorf("failed to encode config: %v", err)
+  }
+  return enc.Close()
+}
+
+// redactToken hides a configured token, keeping whether one is set visible
+func redactToken(token string) string {
+  if token == "" {
+    return ""
+  }
+  return "xxxxx"
+}
+
+// isSecretHeader reports whether the value of the request header name is a credential, such as
+// Authorization, Cookie, PRIVATE-TOKEN or X-Api-Key
+func isSecretHeader(name string) bool {
+  lower := strings.ToLower(name)
+  if strings.Contains(lower, "cookie") {
+    return true
+  }
+  for _, secret := range secretParams {
+    if strings.Contains(lower, secret) {
+      return true
+    }
+  }
+  return false
+}
+
+// dropUnset removes the keys of mapping nodes whose values are null, empty, false or zero, recursively.
+// A mapping left empty is removed as well
+func dropUnset(n *yaml.Node) {
+  for _, c := range n.Content {
+    dropUnset(c)
+  }
+  if n.Kind != yaml.MappingNode {
+    return
+  }
+  var kept []*yaml.Node
+  for i := 0; i+1 < len(n.Content); i += 2 {
+    key, value := n.Content[i], n.Content[i+1]
+    if isUnsetNode(value) && (!keepZeroKeys[key.Value] || value.Tag == "!!null") {
+      continue
+    }
+    kept = append(kept, key, value)
+  }
+  n.Content = kept
+}
+
+// isUnsetNode reports whether n holds the zero value of its type
+func isUnsetNode(n *yaml.Node) bool {
+  switch n.Kind {
+  case yaml.ScalarNode:
+    switch n.Tag {
+    case "!!null":
+      return true
+    case "!!str":
+      return n.Value == "" || n.Value == "0s"
+    case "!!bool":
+      return n.Value == "false"
+    case "!!int":
+      return n.Value == "0"
+    }
+  case yaml.SequenceNode, yaml.MappingNode:
+    return len(n.Content) == 0
+  }
+  return false
+}
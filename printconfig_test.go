@@ -0,0 +1,102 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestRunPrintConfig(t *testing.T) {
+	t.Setenv("EMBED_TEST_HOST", "cdn.example.com")
+	t.Setenv("EMBED_TEST_TOKEN", "ghp_secret")
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		".env": "EMBED_TEST_VERSION=v2\n",
+		"embed.yaml": `output: assets
+github-token: $EMBED_TEST_TOKEN
+max-redirects: 0
+files:
+  - https://${EMBED_TEST_HOST}/$EMBED_TEST_VERSION/config.xml?signature=abc
+  - source: local.txt
+    ensure-trailing-newline: false
+`,
+	})
+	var out strings.Builder
+	if err := run(tmpDir, options{printConfig: true}, &out); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	printed := out.String()
+	if strings.Contains(printed, "ghp_secret") || strings.Contains(printed, "abc") {
+		t.Errorf("printed config leaks a secret:\n%s", printed)
+	}
+
+	var cfg EmbedConfig
+	if err := yaml.Unmarshal([]byte(printed), &cfg); err != nil {
+		t.Fatalf("printed config is not valid YAML: %v\n%s", err, printed)
+	}
+	if got, want := cfg.Files[0].Source, "https://cdn.example.com/v2/config.xml?signature=xxxxx"; got != want {
+		t.Errorf("files[0] = %q, want %q", got, want)
+	}
+	if cfg.GithubToken != "xxxxx" {
+		t.Errorf("github-token = %q, want it redacted", cfg.GithubToken)
+	}
+	if cfg.GoOutput != "embed.go" || cfg.Concurrency != 1 || cfg.MaxRedirects == nil || *cfg.MaxRedirects != 0 {
+		t.Errorf("printed config misses defaults or explicit zeros:\n%s", printed)
+	}
+	if p := cfg.Files[1].EnsureTrailingNewline; p == nil || *p {
+		t.Errorf("files[1].ensure-trailing-newline = %v, want an explicit false", p)
+	}
+	// Unset options are left out
+	for _, key := range []string{"lockfile:", "registry:", "literal:", "checksum:"} {
+		if strings.Contains(printed, key) {
+			t.Errorf("printed config shows unset %s\n%s", key, printed)
+		}
+	}
+}
+
+func TestPrintConfigRedactsHeaders(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `files:
+  - source: https://cdn.example.com/a.json
+    headers:
+      Authorization: Bearer secret-bearer
+      PRIVATE-TOKEN: secret-gitlab
+      Cookie: session=secret-cookie
+      X-Api-Key: secret-key
+      Accept: application/json
+`,
+	})
+	cfg, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err != nil {
+		t.Fatalf("loadConfig() error: %v", err)
+	}
+	var out strings.Builder
+	if err := printConfig(&out, cfg); err != nil {
+		t.Fatalf("printConfig() error: %v", err)
+	}
+	if strings.Contains(out.String(), "secret") {
+		t.Errorf("printed config leaks a header value:\n%s", out.String())
+	}
+	var printed EmbedConfig
+	if err := yaml.Unmarshal([]byte(out.String()), &printed); err != nil {
+		t.Fatalf("printed config is not valid YAML: %v", err)
+	}
+	want := map[string]string{
+		"Authorization": "xxxxx",
+		"PRIVATE-TOKEN": "xxxxx",
+		"Cookie":        "xxxxx",
+		"X-Api-Key":     "xxxxx",
+		"Accept":        "application/json",
+	}
+	if !reflect.DeepEqual(printed.Files[0].Headers, want) {
+		t.Errorf("headers = %v, want %v", printed.Files[0].Headers, want)
+	}
+	// The config itself keeps the values
+	if cfg.Files[0].Headers["Authorization"] != "Bearer secret-bearer" {
+		t.Errorf("printConfig() changed the config's headers: %v", cfg.Files[0].Headers)
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "math/rand/v2"
+  "net/http"
+  "strconv"
+  "strings"
+  "text/tabwriter"
+)
+
+// sampleSize parses the value of -sample, a number of files or a percentage of total, rounded up
+func sampleSize(value string, total int) (int, error) {
+  percent, isPercent := strings.CutSuffix(value, "%")
+  n, err := strconv.Atoi(percent)
+  if err != nil || n <= 0 || isPercent && n > 100 {
+    return 0, fmt.Errorf("invalid -sample %s: must be a positive number of files or a percentage such as 10%%", value)
+  }
+  if isPercent {
+    return (total*n + 99) / 100, nil
+  }
+  return n, nil
+}
+
+// sampleAssets checks that randomly chosen remote assets are reachable, the same way preflight does,
+// and prints the result of each. Nothing is downloaded or written. sample is the value of -sample;
+// all remote assets are checked when there are no more than it asks for
+func sampleAssets(stdout io.Writer, client *http.Client, cfg *EmbedConfig, assets []asset, sample string) error {
+  var remote []asset
+  for _, a := range assets {
+    if a.entry.isRemote(a.expandedURL) {
+      remote = append(remote, a)
+    }
+  }
+  n, err := sampleSize(sample, len(remote))
+  if err != nil {
+    return err
+  }
+  rand.Shuffle(len(remote), func(i, j int) { remote[i], remote[j] = remote[j], remote[i] })
+  picked := remote[:min(n, len(remote))]
+
+  fmt.Fprintf(stdout, "checking %d of %d remote files\n", len(picked), len(remote))
+  tw := tabwriter.NewWriter(stdout, 0, 4, 2, ' ', 0)
+  failed := 0
+  for _, a := range picked {
+    opts, err := assetFetchOptions(cfg, a)
+    if err != nil {
+      return err
+    }
+    u := a.expandedURL
+    if a.githubAPI != nil {
+      u = a.githubAPI.contentsURL()
+    }
+    if err := checkURL(client, u, opts); err != nil {
+      failed++
+      fmt.Fprintf(tw, "FAIL\t%s\t%v\n", redactURL(u), err)
+      continue
+    }
+    fmt.Fprintf(tw, "ok\t%s\t\n", redactURL(u))
+  }
+  if err := tw.Flush(); err != nil {
+    return err
+  }
+  if failed > 0 {
+    return fmt.Errorf("%d of %d sampled URL(s) failed", failed, len(picked))
+  }
+  return nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestRunSample(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/broken.json" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := "output: assets\ngo-mod: main\nfiles:\n  - local.txt\n"
+	for i := range 10 {
+		config += fmt.Sprintf("  - %s/file%d.json\n", server.URL, i)
+	}
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": config, "local.txt": "local"})
+
+	tests := []struct {
+		sample string
+		want   int
+	}{
+		{"3", 3},
+		{"25%", 3},
+		{"50", 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sample, func(t *testing.T) {
+			requests = nil
+			var out strings.Builder
+			if err := run(tmpDir, options{sample: tt.sample}, &out); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if len(requests) != tt.want {
+				t.Errorf("requests = %v, want %d", requests, tt.want)
+			}
+			for _, r := range requests {
+				if !strings.HasPrefix(r, "HEAD ") {
+					t.Errorf("request %s, want only HEAD requests", r)
+				}
+			}
+			if !strings.Contains(out.String(), fmt.Sprintf("checking %d of 10 remote files", tt.want)) || strings.Count(out.String(), "\nok ") != tt.want {
+				t.Errorf("output =\n%s", out.String())
+			}
+		})
+	}
+	for _, name := range []string{"embed.go", "assets"} {
+		if _, err := os.Stat(filepath.Join(tmpDir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written by -sample", name)
+		}
+	}
+
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - " + server.URL + "/broken.json\n"})
+	var out strings.Builder
+	err := run(tmpDir, options{sample: "1"}, &out)
+	if err == nil || !strings.Contains(err.Error(), "1 of 1 sampled URL(s) failed") || !strings.Contains(out.String(), "FAIL  "+server.URL+"/broken.json  404 Not Found") {
+		t.Errorf("run() error = %v, output =\n%s\nwant the broken URL to be reported", err, out.String())
+	}
+
+	if err := run(tmpDir, options{sample: "0"}, &out); err == nil || !strings.Contains(err.Error(), "invalid -sample 0") {
+		t.Errorf("run() error = %v, want an invalid -sample", err)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "fmt"
+  "io"
+  "net/http"
+  "net/url"
+  "path"
+  "path/filepath"
+  "strings"
+
+  "github.com/santhosh-tekuri/jsonschema/v5"
+  "gopkg.in/yaml.v3"
+)
+
+// schemaValidator validates downloaded documents against the JSON Schemas referenced by file entries.
+// Each schema is compiled once; remote schemas (and their $refs) are fetched with the download client
+type schemaValidator struct {
+  baseDir  string
+  compiler *jsonschema.Compiler
+  schemas  map[string]*jsonschema.Schema
+}
+
+// newSchemaValidator returns a validator resolving local schema paths against baseDir
+func newSchemaValidator(client *http.Client, cfg *EmbedConfig, baseDir string) *schemaValidator {
+  compiler := jsonschema.NewCompiler()
+  compiler.LoadURL = func(s string) (io.ReadCloser, error) {
+    if !isRemoteURL(s) {
+      return jsonschema.LoadURL(s)
+    }
+    if err := checkAllowedHost(s, cfg.AllowedHosts); err != nil {
+      return nil, err
+    }
+    data, err := fetchURL(client, s, fetchOptions{auth: cfg.authTokens()})
+    if err != nil {
+      return nil, err
+    }
+    return io.NopCloser(bytes.NewReader(data)), nil
+  }
+  return &schemaValidator{baseDir: baseDir, compiler: compiler, schemas: make(map[string]*jsonschema.Schema)}
+}
+
+// validate checks data, named name, against the schema at ref (a URL or a path relative to the config).
+// Files ending in .yaml or .yml are parsed as YAML, everything else as JSON
+func (v *schemaValidator) validate(ref, name string, data []byte) error {
+  schema, err := v.compile(ref)
+  if err != nil {
+    return err
+  }
+  doc, err := decodeDocument(name, data)
+  if err != nil {
+    return fmt.Errorf("failed to validate %s against %s: %v", name, ref, err)
+  }
+  err = schema.Validate(doc)
+  if verr, ok := err.(*jsonschema.ValidationError); ok {
+    return fmt.Errorf("%s does not match schema %s:%s", name, ref, validationMessages(verr))
+  }
+  if err != nil {
+    return fmt.Errorf("failed to validate %s against %s: %v", name, ref, err)
+  }
+  return nil
+}
+
+// compile returns the compiled schema for ref
+func (v *schemaValidator) compile(ref string) (*jsonschema.Schema, error) {
+  if schema, ok := v.schemas[ref]; ok {
+    return schema, nil
+  }
+  schemaURL := ref
+  if !isRemoteURL(ref) {
+    schemaURL = (&url.URL{Scheme: "file", Path: filepath.ToSlash(resolvePath(v.baseDir, ref))}).String()
+  }
+  schema, err := v.compiler.Compile(schemaURL)
+  if err != nil {
+    return nil, fmt.Errorf("failed to load schema %s: %v", ref, err)
+  }
+  v.schemas[ref] = schema
+  return schema, nil
+}
+
+// decodeDocument parses data into the generic JSON value the validator expects
+func decodeDocument(name string, data []byte) (any, error) {
+  switch strings.ToLower(path.Ext(name)) {
+  case ".yaml", ".yml":
+    var doc any
+    if err := yaml.Unmarshal(data, &doc); err != nil {
+      return nil, fmt.Errorf("invalid YAML: %v", err)
+    }
+    // Round-trip through JSON so numbers and maps have the types the validator expects
+    var err error
+    if data, err = json.Marshal(doc); err != nil {
+      return nil, fmt.Errorf("YAML document cannot be represented as JSON: %v", err)
+    }
+  }
+  dec := json.NewDecoder(bytes.NewReader(data))
+  dec.UseNumber()
+  var doc any
+  if err := dec.Decode(&doc); err != nil {
+    return nil, fmt.Errorf("invalid JSON: %v", err)
+  }
+  return doc, nil
+}
+
+// validationMessages lists the leaf errors of a validation failure, one indented line each
+func validationMessages(err *jsonschema.ValidationError) string {
+  if len(err.Causes) == 0 {
+    location := err.InstanceLocation
+    if location == "" {
+      location = "/"
+    }
+    return "\n  " + location + ": " + err.Message
+  }
+  var b strings.Builder
+  for _, cause := range err.Causes {
+    b.WriteString(validationMessages(cause))
+  }
+  return b.String()
+}
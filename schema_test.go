@@ -0,0 +1,79 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testSchema = `{
+  "type": "object",
+  "required": ["name", "port"],
+  "properties": {
+    "name": {"type": "string"},
+    "port": {"type": "integer", "minimum": 1}
+  }
+}`
+
+func TestSchemaValidation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testSchema))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		file    string
+		content string
+		schema  string
+		wantErr []string
+	}{
+		{"valid json", "config.json", `{"name": "api", "port": 8080}`, "schema.json", nil},
+		{"valid yaml", "config.yaml", "name: api\nport: 8080\n", "schema.json", nil},
+		{"remote schema", "config.json", `{"name": "api", "port": 8080}`, server.URL + "/schema.json", nil},
+		{
+			"invalid json", "config.json", `{"name": 1, "port": 0}`, "schema.json",
+			[]string{"config.json does not match schema schema.json:", "/name: expected string, but got number", "/port: must be >= 1 but found 0"},
+		},
+		{"invalid yaml", "config.yml", "name: api\n", "schema.json", []string{"/: missing properties: 'port'"}},
+		{"not json", "config.json", "name: api", "schema.json", []string{"invalid JSON"}},
+		{"missing schema", "config.json", "{}", "missing.json", []string{"failed to load schema missing.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"schema.json":    testSchema,
+				"src/" + tt.file: tt.content,
+				"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/` + tt.file + `
+    schema: ` + tt.schema + `
+`,
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("run() error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("run() should fail")
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("run() error = %v, want it to contain %q", err, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(tmpDir, "assets", tt.file)); !os.IsNotExist(err) {
+				t.Errorf("invalid file was written (stat error: %v)", err)
+			}
+		})
+	}
+}
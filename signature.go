@@ -0,0 +1,121 @@
+package main
+
+import (
+  "bytes"
+  "crypto/ed25519"
+  "encoding/base64"
+  "fmt"
+  "net/http"
+  "strings"
+
+  "golang.org/x/crypto/blake2b"
+)
+
+// minisignSuffix is appended to the URL of a file to find its signature when signature-url is not set
+const minisignSuffix = ".minisig"
+
+// maxSignatureSize bounds the download of a signature file, which is a few hundred bytes
+const maxSignatureSize = 64 << 10
+
+// minisignKey is a minisign public key: the id that signatures name and the Ed25519 key they verify against
+type minisignKey struct {
+  id  [8]byte
+  key ed25519.PublicKey
+}
+
+// parseMinisignKey parses a minisign public key, either the base64 line printed by minisign -G or the whole
+// content of a .pub file including its untrusted comment
+func parseMinisignKey(s string) (*minisignKey, error) {
+  lines := nonEmptyLines(s)
+  if len(lines) == 0 {
+    return nil, fmt.Errorf("invalid public-key: empty")
+  }
+  raw, err := base64.StdEncoding.DecodeString(lines[len(lines)-1])
+  if err != nil || len(raw) != 2+8+ed25519.PublicKeySize || string(raw[:2]) != "Ed" {
+    return nil, fmt.Errorf("invalid public-key %q: not a minisign public key", lines[len(lines)-1])
+  }
+  k := &minisignKey{key: ed25519.PublicKey(raw[10:])}
+  copy(k.id[:], raw[2:10])
+  return k, nil
+}
+
+// verify checks a minisign signature file over data: the signature of the content, made with this key,
+// and the global signature covering it together with the trusted comment. Both the legacy (Ed) and the
+// prehashed (ED, BLAKE2b-512) formats are accepted
+func (k *minisignKey) verify(data, signature []byte) error {
+  lines := nonEmptyLines(string(signature))
+  if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment:") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+    return fmt.Errorf("invalid minisign signature: want 4 lines with an untrusted and a trusted comment")
+  }
+  sig, err := base64.StdEncoding.DecodeString(lines[1])
+  if err != nil || len(sig) != 2+8+ed25519.SignatureSize {
+    return fmt.Errorf("invalid minisign signature: malformed signature line")
+  }
+  if !bytes.Equal(sig[2:10], k.id[:]) {
+    return fmt.Errorf("signature was made with key %X, not the public-key %X", reverse(sig[2:10]), reverse(k.id[:]))
+  }
+  message := data
+  switch string(sig[:2]) {
+  case "Ed":
+  case "ED":
+    sum := blake2b.Sum512(data)
+    message = sum[:]
+  default:
+    return fmt.Errorf("invalid minisign signature: unsupported algorithm %q", sig[:2])
+  }
+  if !ed25519.Verify(k.key, message, sig[10:]) {
+    return fmt.Errorf("signature verification failed: the content does not match its signature")
+  }
+  global, err := base64.StdEncoding.DecodeString(lines[3])
+  if err != nil || len(global) != ed25519.SignatureSize {
+    return fmt.Errorf("invalid minisign signature: malformed global signature")
+  }
+  trusted := strings.TrimPrefix(lines[2], "trusted comment: ")
+  if !ed25519.Verify(k.key, append(bytes.Clone(sig[10:]), trusted...), global) {
+    return fmt.Errorf("signature verification failed: the trusted comment does not match its signature")
+  }
+  return nil
+}
+
+// signatureURL returns the URL of the detached signature of a: signature-url, or the URL of the file with .minisig appended
+func (a asset) signatureURL() string {
+  if a.entry.SignatureURL != "" {
+    return expandEnvVars(a.entry.SignatureURL)
+  }
+  return a.expandedURL + minisignSuffix
+}
+
+// verifySignature downloads the signature of a and verifies data, the content as downloaded, against it.
+// The request carries the headers and tokens of the file, but none of the checks that only apply to the file itself
+func verifySignature(client *http.Client, a asset, data []byte, fetchOpts fetchOptions) error {
+  sigURL := a.signatureURL()
+  sigOpts := fetchOptions{auth: fetchOpts.auth, headers: fetchOpts.headers, timeout: fetchOpts.timeout, stall: fetchOpts.stall, maxSize: maxSignatureSize}
+  signature, err := fetchURL(client, sigURL, sigOpts)
+  if err != nil {
+    return fmt.Errorf("failed to download the signature of %s: %v", a.expandedURL, err)
+  }
+  if err := a.entry.publicKey.verify(data, signature); err != nil {
+    return fmt.Errorf("%s: %v (%s)", a.expandedURL, err, sigURL)
+  }
+  return nil
+}
+
+// nonEmptyLines returns the lines of s without surrounding whitespace, skipping empty ones
+func nonEmptyLines(s string) []string {
+  var lines []string
+  for _, l := range strings.Split(s, "\n") {
+    if l = strings.TrimSpace(l); l != "" {
+      lines = append(lines, l)
+    }
+  }
+  return lines
+}
+
+// reverse returns b in reverse order; minisign prints key ids as little-endian numbers
+func reverse(b []byte) []byte {
+  r := make([]byte, len(b))
+  for i := range b {
+    r[len(b)-1-i] = b[i]
+  }
+  return r
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// testMinisigner signs like minisign does, with a fixed key id
+type testMinisigner struct {
+	id   []byte
+	priv ed25519.PrivateKey
+	pub  ed25519.PublicKey
+}
+
+func newTestMinisigner(t *testing.T) *testMinisigner {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testMinisigner{id: []byte{1, 2, 3, 4, 5, 6, 7, 8}, priv: priv, pub: pub}
+}
+
+// publicKey returns the key as the base64 line of a .pub file
+func (s *testMinisigner) publicKey() string {
+	return base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.id...), s.pub...))
+}
+
+// sign returns a .minisig file for data, prehashed with BLAKE2b-512 like minisign 0.10 and later
+func (s *testMinisigner) sign(data []byte, trusted string) string {
+	sum := blake2b.Sum512(data)
+	sig := ed25519.Sign(s.priv, sum[:])
+	global := ed25519.Sign(s.priv, append(append([]byte{}, sig...), trusted...))
+	return "untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte("ED"), s.id...), sig...)) + "\n" +
+		"trusted comment: " + trusted + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n"
+}
+
+func TestParseMinisignKey(t *testing.T) {
+	signer := newTestMinisigner(t)
+	for _, input := range []string{signer.publicKey(), "untrusted comment: minisign public key 0807060504030201\n" + signer.publicKey() + "\n"} {
+		key, err := parseMinisignKey(input)
+		if err != nil || !key.key.Equal(signer.pub) {
+			t.Errorf("parseMinisignKey(%q) = %v, %v; want the public key", input, key, err)
+		}
+	}
+	if _, err := parseMinisignKey("RWQBAgMEBQYHCA=="); err == nil || !strings.Contains(err.Error(), "not a minisign public key") {
+		t.Errorf("parseMinisignKey() error = %v, want a truncated key rejected", err)
+	}
+}
+
+func TestMinisignVerify(t *testing.T) {
+	signer := newTestMinisigner(t)
+	key, err := parseMinisignKey(signer.publicKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte(`{"version": 2}`)
+	signature := signer.sign(data, "timestamp:1700000000\tfile:config.json")
+
+	// The legacy format signs the content itself
+	legacy := ed25519.Sign(signer.priv, data)
+	legacySig := "untrusted comment: legacy\n" + base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), signer.id...), legacy...)) +
+		"\ntrusted comment: old\n" + base64.StdEncoding.EncodeToString(ed25519.Sign(signer.priv, append(append([]byte{}, legacy...), "old"...))) + "\n"
+
+	other := newTestMinisigner(t)
+	other.id = []byte{9, 9, 9, 9, 9, 9, 9, 9}
+
+	tests := []struct {
+		name      string
+		data      []byte
+		signature string
+		wantErr   string
+	}{
+		{"valid", data, signature, ""},
+		{"legacy", data, legacySig, ""},
+		{"tampered content", []byte(`{"version": 3}`), signature, "the content does not match its signature"},
+		{"tampered trusted comment", data, strings.Replace(signature, "file:config.json", "file:other.json", 1), "the trusted comment does not match its signature"},
+		{"other key", data, other.sign(data, "x"), "signature was made with key 0909090909090909, not the public-key 0807060504030201"},
+		{"not a signature", data, "<html>Not Found</html>", "invalid minisign signature"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := key.verify(tt.data, []byte(tt.signature))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("verify() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunSignature(t *testing.T) {
+	signer := newTestMinisigner(t)
+	content := "release notes\n"
+	signature := signer.sign([]byte(content), "notes")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/notes.txt":
+			w.Write([]byte(content))
+		case "/notes.txt.minisig", "/sigs/notes.sig":
+			w.Write([]byte(signature))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, tt := range []struct {
+		name, entry string
+	}{
+		{"default url", "    public-key: " + signer.publicKey() + "\n"},
+		{"signature-url", "    public-key: $NOTES_KEY\n    signature-url: " + server.URL + "/sigs/notes.sig\n"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NOTES_KEY", signer.publicKey())
+			content = "release notes\n"
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "output: assets\ngo-mod: main\nline-endings: crlf\nfiles:\n  - source: " + server.URL + "/notes.txt\n" + tt.entry,
+			})
+			if err := run(tmpDir, options{}, io.Discard); err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			// The signature covers the file as downloaded, before line endings are normalized
+			if data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "notes.txt")); err != nil || string(data) != "release notes\r\n" {
+				t.Errorf("notes.txt = %q, %v", data, err)
+			}
+
+			content = "release notes, tampered\n"
+			err := run(tmpDir, options{}, io.Discard)
+			if err == nil || !strings.Contains(err.Error(), "/notes.txt: signature verification failed") {
+				t.Errorf("run() error = %v, want the tampered file rejected", err)
+			}
+		})
+	}
+}
+
+func TestSignatureConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		entry   string
+		wantErr string
+	}{
+		{"no key", "  - source: https://example.com/a.txt\n    signature-url: https://example.com/a.sig\n", "signature-url requires public-key"},
+		{"local", "  - source: a.txt\n    public-key: RWQ=\n", "public-key is only supported for remote files"},
+		{"range", "  - source: https://example.com/a.bin\n    range: bytes=0-9\n    public-key: RWQ=\n", "public-key cannot be combined with range"},
+		{"invalid key", "  - source: https://example.com/a.txt\n    public-key: not-a-key\n", `invalid public-key "not-a-key"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\nfiles:\n" + tt.entry})
+			_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("loadConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strconv"
+  "time"
+)
+
+// sink receives the files a run produces. Asset contents are staged while fetching and only
+// committed once every asset succeeded, followed by the generated files (embed.go, manifest, lockfile)
+type sink interface {
+  // stage keeps the content of the i-th asset until commit
+  stage(i int, a asset, data []byte) error
+  // commit places the staged content of every non-literal asset at its destination
+  commit(assets []asset) error
+  // write stores a generated file
+  write(path string, data []byte) error
+  // remove deletes a file generated by an earlier run
+  remove(path string) error
+  // close discards whatever was staged but not committed
+  close()
+}
+
+// diskSink writes to the filesystem. Assets are staged in a temporary directory next to the config,
+// so a failed run leaves the working tree untouched
+type diskSink struct {
+  staging  string
+  staged   []string
+  store    *contentStore // when set, assets are also written to the content store
+  epoch    time.Time
+  hasEpoch bool // pin the mtime of everything written to epoch
+}
+
+// newDiskSink creates the staging directory in baseDir
+func newDiskSink(baseDir string, store *contentStore, epoch time.Time, hasEpoch bool) (*diskSink, error) {
+  staging, err := os.MkdirTemp(baseDir, ".remoteembed-staging-")
+  if err != nil {
+    return nil, fmt.Errorf("failed to create staging dir: %v", err)
+  }
+  return &diskSink{staging: staging, store: store, epoch: epoch, hasEpoch: hasEpoch}, nil
+}
+
+func (s *diskSink) stage(i int, a asset, data []byte) error {
+  if i >= len(s.staged) {
+    s.staged = append(s.staged, make([]string, i+1-len(s.staged))...)
+  }
+  s.staged[i] = filepath.Join(s.staging, strconv.Itoa(i))
+  if err := os.WriteFile(s.staged[i], data, 0644); err != nil {
+    return fmt.Errorf("failed to stage %s: %v", a.expandedURL, err)
+  }
+  return nil
+}
+
+func (s *diskSink) commit(assets []asset) error {
+  for i, a := range assets {
+    if a.entry.Literal {
+      continue
+    }
+    if err := os.MkdirAll(filepath.Dir(a.localFile), 0755); err != nil {
+      return fmt.Errorf("failed to create dir %s: %v", filepath.Dir(a.localFile), err)
+    }
+    // The output gets its own copy, so editing it cannot change the shared blob
+    if s.store != nil {
+      data, err := os.ReadFile(s.staged[i])
+      if err != nil {
+        return fmt.Errorf("failed to read staged file %s: %v", s.staged[i], err)
+      }
+      if _, _, err := s.store.put(data); err != nil {
+        return err
+      }
+    }
+    if err := os.Rename(s.staged[i], a.localFile); err != nil {
+      return fmt.Errorf("failed to write file %s: %v", a.localFile, err)
+    }
+    if err := s.touch(a.localFile); err != nil {
+      return err
+    }
+  }
+  return nil
+}
+
+func (s *diskSink) write(path string, data []byte) error {
+  // go-output may name a subpackage directory that does not exist yet
+  if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+    return fmt.Errorf("failed to create directory for %s: %v", path, err)
+  }
+  if err := writeFileAtomic(path, data); err != nil {
+    return err
+  }
+  return s.touch(path)
+}
+
+func (s *diskSink) remove(path string) error {
+  if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+    return fmt.Errorf("failed to remove %s: %v", path, err)
+  }
+  return nil
+}
+
+// touch sets the modification time of path to SOURCE_DATE_EPOCH when it is set
+func (s *diskSink) touch(path string) error {
+  if !s.hasEpoch {
+    return nil
+  }
+  if err := os.Chtimes(path, s.epoch, s.epoch); err != nil {
+    return fmt.Errorf("failed to set modification time of %s: %v", path, err)
+  }
+  return nil
+}
+
+func (s *diskSink) close() {
+  os.RemoveAll(s.staging)
+}
+
+// memorySink keeps everything in memory, keyed by slash-separated path relative to the config directory
+type memorySink struct {
+  baseDir string
+  staged  map[int][]byte
+  files   map[string][]byte
+}
+
+func newMemorySink(baseDir string) *memorySink {
+  return &memorySink{baseDir: baseDir, staged: map[int][]byte{}, files: map[string][]byte{}}
+}
+
+func (s *memorySink) stage(i int, a asset, data []byte) error {
+  s.staged[i] = data
+  return nil
+}
+
+func (s *memorySink) commit(assets []asset) error {
+  for i, a := range assets {
+    if !a.entry.Literal {
+      s.files[s.key(a.localFile)] = s.staged[i]
+    }
+  }
+  return nil
+}
+
+func (s *memorySink) write(path string, data []byte) error {
+  s.files[s.key(path)] = data
+  return nil
+}
+
+func (s *memorySink) remove(path string) error {
+  delete(s.files, s.key(path))
+  return nil
+}
+
+func (s *memorySink) close() {}
+
+// key returns the map key of an absolute path
+func (s *memorySink) key(path string) string {
+  rel, err := filepath.Rel(s.baseDir, path)
+  if err != nil {
+    return filepath.ToSlash(path)
+  }
+  return filepath.ToSlash(rel)
+}
+
+// generateInMemory runs the generation described by the config without writing to the filesystem.
+// It returns the generated embed.go and the asset files, keyed by slash-separated path relative to
+// the config directory. Other generated files, such as the manifest, lockfile or the parts of a
+// go-output split by max-vars-per-file, are in files as well.
+//
+// It is safe to call concurrently: the warning settings and writer come from opts, and every call
+// has its own sink. Variables of a .env file are shared by the process like the environment itself,
+// so concurrent configs in different directories should not define the same variable differently.
+func generateInMemory(cwd string, opts options) (embedGo []byte, files map[string][]byte, err error) {
+  configPath := opts.configFile(cwd)
+  out := newMemorySink(filepath.Dir(configPath))
+  opts.sink = out
+  if err := run(cwd, opts, io.Discard); err != nil {
+    return nil, nil, err
+  }
+  cfg, err := loadConfig(configPath)
+  if err != nil {
+    return nil, nil, err
+  }
+  key := out.key(filepath.Join(out.baseDir, cfg.GoOutput))
+  embedGo = out.files[key]
+  delete(out.files, key)
+  return embedGo, out.files, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// listFiles returns the slash-separated paths of every file below dir
+func listFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	var files []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			rel, _ := filepath.Rel(dir, p)
+			files = append(files, filepath.ToSlash(rel))
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestGenerateInMemory(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"remote":true}`))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/local.txt":  "local",
+		"src/inline.txt": "inline",
+		"embed.yaml": `output: assets
+go-mod: main
+lockfile: embed.lock
+manifest-go: manifest.go
+files:
+  - ` + server.URL + `/data/remote.json
+  - src/local.txt
+  - source: src/inline.txt
+    literal: true
+`,
+	})
+	before := listFiles(t, tmpDir)
+
+	embedGo, files, err := generateInMemory(tmpDir, options{})
+	if err != nil {
+		t.Fatalf("generateInMemory() error: %v", err)
+	}
+	if after := listFiles(t, tmpDir); !slices.Equal(before, after) {
+		t.Fatalf("generateInMemory() changed the filesystem: %v, want %v", after, before)
+	}
+
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	onDisk, err := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(embedGo) != string(onDisk) {
+		t.Errorf("in-memory embed.go =\n%s\nwant\n%s", embedGo, onDisk)
+	}
+	var names []string
+	for name, data := range files {
+		names = append(names, name)
+		onDisk, err := os.ReadFile(filepath.Join(tmpDir, name))
+		if err != nil {
+			t.Errorf("%s is not on disk: %v", name, err)
+			continue
+		}
+		// The manifest records the generation time
+		if name != "manifest.go" && string(data) != string(onDisk) {
+			t.Errorf("in-memory %s = %q, want %q", name, data, onDisk)
+		}
+	}
+	slices.Sort(names)
+	expected := []string{"assets/local.txt", "assets/remote.json", "embed.lock", "manifest.go"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("in-memory files = %v, want %v", names, expected)
+	}
+}
+
+func TestGenerateInMemoryConcurrent(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/empty.txt": "",
+		"src/a.txt":     "a",
+		"embed.yaml":    "output: assets\ngo-mod: main\nfiles:\n  - src/empty.txt\n  - src/a.txt\n",
+	})
+
+	// Half of the calls turn the warning about the empty file into an error, the others only report it
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	warnings := make([]bytes.Buffer, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, _, errs[i] = generateInMemory(tmpDir, options{werror: i%2 == 0, stderr: &warnings[i]})
+		}()
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if i%2 == 0 {
+			if err == nil || !strings.HasSuffix(err.Error(), "is empty (-Werror)") {
+				t.Errorf("call %d with -Werror error = %v, want the empty file warning as error", i, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("call %d error: %v", i, err)
+		}
+		if !strings.Contains(warnings[i].String(), "warning: ") || !strings.Contains(warnings[i].String(), "empty.txt is empty") {
+			t.Errorf("call %d warnings = %q, want the empty file warning", i, warnings[i].String())
+		}
+	}
+}
+
+func BenchmarkGenerateInMemory(b *testing.B) {
+	tmpDir := b.TempDir()
+	files := "output: assets\ngo-mod: main\nfiles:\n"
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt", "e.txt"} {
+		files += "  - src/" + name + "\n"
+		if err := os.MkdirAll(filepath.Join(tmpDir, "src"), 0755); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(tmpDir, "src", name), []byte(name), 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "embed.yaml"), []byte(files), 0644); err != nil {
+		b.Fatal(err)
+	}
+	for b.Loop() {
+		if _, _, err := generateInMemory(tmpDir, options{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
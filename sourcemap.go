@@ -0,0 +1,111 @@
+package main
+
+import (
+  "bytes"
+  "net/url"
+  "path"
+  "path/filepath"
+  "strings"
+)
+
+// sourceMapRef returns the target of the last sourceMappingURL comment in a JavaScript file,
+// or "" when there is none or the map is inlined as a data: URI
+func sourceMapRef(data []byte) string {
+  ref := ""
+  for _, line := range bytes.Split(data, []byte("\n")) {
+    line = bytes.TrimSpace(line)
+    for _, prefix := range []string{"//# sourceMappingURL=", "//@ sourceMappingURL="} {
+      if value, ok := bytes.CutPrefix(line, []byte(prefix)); ok {
+        if fields := strings.Fields(string(value)); len(fields) > 0 {
+          ref = fields[0]
+        }
+      }
+    }
+  }
+  if strings.HasPrefix(ref, "data:") {
+    return ""
+  }
+  return ref
+}
+
+// sourceMapAsset returns the asset for the source map referenced by the JavaScript asset js.
+// The map is written next to the file and named after its variable with a SourceMap suffix
+func sourceMapAsset(js asset, ref string) (asset, error) {
+  var source, shortName string
+  if js.entry.isRemote(js.expandedURL) {
+    base, err := url.Parse(js.expandedURL)
+    if err != nil {
+      return asset{}, err
+    }
+    u, err := base.Parse(ref)
+    if err != nil {
+      return asset{}, err
+    }
+    source, shortName = u.String(), path.Base(u.Path)
+  } else if isRemoteURL(ref) {
+    u, err := url.Parse(ref)
+    if err != nil {
+      return asset{}, err
+    }
+    source, shortName = ref, path.Base(u.Path)
+  } else {
+    rel := strings.SplitN(ref, "?", 2)[0]
+    source = filepath.Join(filepath.Dir(js.expandedURL), filepath.FromSlash(rel))
+    shortName = path.Base(rel)
+  }
+  shortName = sanitizeEmbedPath(shortName)
+
+  // The map is embedded byte for byte, with the request headers of the JavaScript file
+  keep := false
+  entry := &FileEntry{
+    Source:                source,
+    LineEndings:           "keep",
+    EnsureTrailingNewline: &keep,
+    Headers:               js.entry.Headers,
+    Literal:               js.entry.Literal,
+    headerTemplates:       js.entry.headerTemplates,
+  }
+  return asset{
+    fileInfo: fileInfo{
+      originalURL: source,
+      expandedURL: source,
+      sourcePath:  path.Join(path.Dir(js.sourcePath), shortName),
+      shortName:   shortName,
+      entry:       entry,
+    },
+    uniquePath:   path.Join(path.Dir(js.uniquePath), shortName),
+    localFile:    filepath.Join(filepath.Dir(js.localFile), shortName),
+    relEmbedPath: path.Join(path.Dir(js.relEmbedPath), shortName),
+    varName:      js.varName + "SourceMap",
+  }, nil
+}
+
+// followSourceMap adds the source map referenced by the JavaScript asset js with content data
+// to assets, unless it is already embedded
+func followSourceMap(cfg *EmbedConfig, assets []asset, js asset, data []byte) ([]asset, error) {
+  if !strings.EqualFold(path.Ext(js.shortName), ".js") || js.entry.isDataURI(js.expandedURL) {
+    return assets, nil
+  }
+  ref := sourceMapRef(data)
+  if ref == "" {
+    return assets, nil
+  }
+  m, err := sourceMapAsset(js, ref)
+  if err != nil {
+    return assets, cfg.warn.warnf("%s: invalid sourceMappingURL %q: %v", js.expandedURL, ref, err)
+  }
+  if isRemoteURL(m.expandedURL) {
+    if err := checkAllowedHost(m.expandedURL, cfg.AllowedHosts); err != nil {
+      return assets, err
+    }
+  }
+  for _, a := range assets {
+    if a.localFile == m.localFile {
+      return assets, nil
+    }
+    if a.varName == m.varName {
+      return assets, cfg.warn.warnf("not embedding source map %s: variable %s already exists", m.expandedURL, m.varName)
+    }
+  }
+  return append(assets, m), nil
+}
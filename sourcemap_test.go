@@ -0,0 +1,84 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSourceMapRef(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected string
+	}{
+		{"relative", "app();\n//# sourceMappingURL=app.js.map\n", "app.js.map"},
+		{"legacy", "app();\n//@ sourceMappingURL=maps/app.map", "maps/app.map"},
+		{"last wins", "//# sourceMappingURL=old.map\napp();\n//# sourceMappingURL=new.map\n", "new.map"},
+		{"inline", "app();\n//# sourceMappingURL=data:application/json;base64,e30=\n", ""},
+		{"none", "app();\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sourceMapRef([]byte(tt.content)); got != tt.expected {
+				t.Errorf("sourceMapRef() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFollowSourcemaps(t *testing.T) {
+	const appMap = `{"version":3,"sources":["app.ts"],"mappings":"AAAA"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/static/js/app.js":
+			w.Write([]byte("app();\n//# sourceMappingURL=maps/app.js.map\n"))
+		case "/static/js/maps/app.js.map":
+			w.Write([]byte(appMap))
+		case "/static/js/plain.js":
+			w.Write([]byte("plain();\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/local.js":     "local();\n//# sourceMappingURL=local.js.map",
+		"src/local.js.map": `{"version":3}`,
+		"embed.yaml": `output: assets
+go-mod: main
+follow-sourcemaps: true
+files:
+  - ` + server.URL + `/static/js/app.js
+  - ` + server.URL + `/static/js/plain.js
+  - src/local.js
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	for name, want := range map[string]string{"app.js.map": appMap, "local.js.map": `{"version":3}`} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, "assets", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", name, data, want)
+		}
+	}
+	embedGo, _ := os.ReadFile(filepath.Join(tmpDir, "embed.go"))
+	for _, want := range []string{"//go:embed assets/app.js.map\n", "AppSourceMap string", "LocalSourceMap string"} {
+		if !strings.Contains(string(embedGo), want) {
+			t.Errorf("embed.go does not contain %q:\n%s", want, embedGo)
+		}
+	}
+	if strings.Contains(string(embedGo), "PlainSourceMap") {
+		t.Error("a file without sourceMappingURL should not get a source map")
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+  "bytes"
+  "encoding/base64"
+  "encoding/hex"
+  "fmt"
+  "os/exec"
+  "regexp"
+  "runtime"
+  "strings"
+  "unicode/utf8"
+
+  "golang.org/x/text/encoding"
+  "golang.org/x/text/encoding/ianaindex"
+)
+
+// lookupEncoding returns the character encoding registered under an IANA name or alias, e.g. ISO-8859-1
+func lookupEncoding(name string) (encoding.Encoding, error) {
+  enc, err := ianaindex.IANA.Encoding(name)
+  if err != nil || enc == nil {
+    return nil, fmt.Errorf("unsupported encoding %q", name)
+  }
+  return enc, nil
+}
+
+// toUTF8 transcodes data from the named character encoding to UTF-8; an empty name leaves data as-is
+func toUTF8(data []byte, charset string) ([]byte, error) {
+  if charset == "" {
+    return data, nil
+  }
+  enc, err := lookupEncoding(charset)
+  if err != nil {
+    return nil, err
+  }
+  return enc.NewDecoder().Bytes(data)
+}
+
+// byteOrderMarks are the UTF-8, UTF-16 big-endian and UTF-16 little-endian byte order marks
+var byteOrderMarks = [][]byte{{0xEF, 0xBB, 0xBF}, {0xFE, 0xFF}, {0xFF, 0xFE}}
+
+// stripBOM removes a leading byte order mark from data. It runs after transcoding, where a UTF-16 source
+// with a declared encoding has become UTF-8 and its mark the UTF-8 one
+func stripBOM(data []byte) []byte {
+  for _, bom := range byteOrderMarks {
+    if rest, ok := bytes.CutPrefix(data, bom); ok {
+      return rest
+    }
+  }
+  return data
+}
+
+// pipeContent runs command through the shell in dir with data on its stdin and returns its stdout.
+// A non-zero exit is an error carrying what the command wrote to stderr
+func pipeContent(dir, command string, data []byte) ([]byte, error) {
+  var cmd *exec.Cmd
+  if runtime.GOOS == "windows" {
+    cmd = exec.Command("cmd", "/C", command)
+  } else {
+    cmd = exec.Command("sh", "-c", command)
+  }
+  cmd.Dir = dir
+  cmd.Stdin = bytes.NewReader(data)
+  var stdout, stderr bytes.Buffer
+  cmd.Stdout, cmd.Stderr = &stdout, &stderr
+  if err := cmd.Run(); err != nil {
+    if msg := strings.TrimSpace(stderr.String()); msg != "" {
+      return nil, fmt.Errorf("pipe %q failed: %v: %s", command, err, msg)
+    }
+    return nil, fmt.Errorf("pipe %q failed: %v", command, err)
+  }
+  return stdout.Bytes(), nil
+}
+
+// isBinary reports whether data looks like binary rather than text: it contains a NUL byte or is not valid UTF-8
+func isBinary(data []byte) bool {
+  return bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data)
+}
+
+// normalizeText rewrites line endings ("lf" or "crlf"; "keep" or "" leaves them as-is), counting a lone CR
+// as a line ending too, and optionally appends a final newline to non-empty content. Binary content is returned unchanged
+func normalizeText(data []byte, lineEndings string, trailingNewline bool) []byte {
+  if isBinary(data) {
+    return data
+  }
+  switch lineEndings {
+  case "lf":
+    data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+    data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+  case "crlf":
+    data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+    data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+    data = bytes.ReplaceAll(data, []byte("\n"), []byte("\r\n"))
+  }
+  if trailingNewline && len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+    if lineEndings == "crlf" {
+      data = append(data, '\r', '\n')
+    } else {
+      data = append(data, '\n')
+    }
+  }
+  return data
+}
+
+// urlReferencePattern matches src="..." and href='...' attributes and CSS url(...) references;
+// the first non-empty group among 1-4 is the referenced URL
+var urlReferencePattern = regexp.MustCompile(`(?i)\b(?:src|href)\s*=\s*(?:"([^"]*)"|'([^']*)')|\burl\(\s*(?:["']([^"')]*)["']|([^"'()\s]*))\s*\)`)
+
+// rewriteURLs replaces the longest matching prefix of every src, href and url() reference
+// according to prefixes (old prefix to new prefix); other references are left untouched
+func rewriteURLs(data []byte, prefixes map[string]string) []byte {
+  if len(prefixes) == 0 {
+    return data
+  }
+  var out bytes.Buffer
+  last := 0
+  for _, m := range urlReferencePattern.FindAllSubmatchIndex(data, -1) {
+    for g := 1; g <= 4; g++ {
+      start, end := m[2*g], m[2*g+1]
+      if start < 0 {
+        continue
+      }
+      ref := string(data[start:end])
+      best := ""
+      for prefix := range prefixes {
+        if strings.HasPrefix(ref, prefix) && len(prefix) > len(best) {
+          best = prefix
+        }
+      }
+      if best != "" {
+        out.Write(data[last:start])
+        out.WriteString(prefixes[best] + strings.TrimPrefix(ref, best))
+        last = end
+      }
+      break
+    }
+  }
+  out.Write(data[last:])
+  return out.Bytes()
+}
+
+// encodeContent returns data as lowercase hex or standard base64 text for those embed-encodings, unchanged otherwise
+func encodeContent(data []byte, embedEncoding string) []byte {
+  switch embedEncoding {
+  case "hex":
+    return []byte(hex.EncodeToString(data))
+  case "base64":
+    return []byte(base64.StdEncoding.EncodeToString(data))
+  }
+  return data
+}
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeText(t *testing.T) {
+	tests := []struct {
+		name            string
+		input           string
+		lineEndings     string
+		trailingNewline bool
+		expected        string
+	}{
+		{"keep", "a\r\nb\nc", "keep", false, "a\r\nb\nc"},
+		{"default keeps", "a\r\nb", "", false, "a\r\nb"},
+		{"lf", "a\r\nb\r\nc\n", "lf", false, "a\nb\nc\n"},
+		{"crlf", "a\nb\r\nc\n", "crlf", false, "a\r\nb\r\nc\r\n"},
+		{"trailing newline added", "a\nb", "", true, "a\nb\n"},
+		{"trailing newline present", "a\nb\n", "", true, "a\nb\n"},
+		{"trailing newline crlf", "a\r\nb", "crlf", true, "a\r\nb\r\n"},
+		{"trailing newline empty file", "", "lf", true, ""},
+		{"lone cr lf", "a\rb\r\nc\r", "lf", false, "a\nb\nc\n"},
+		{"lone cr crlf", "a\rb\nc", "crlf", false, "a\r\nb\r\nc"},
+		{"binary untouched", "\x00a\r\nb", "lf", true, "\x00a\r\nb"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := string(normalizeText([]byte(tt.input), tt.lineEndings, tt.trailingNewline))
+			if result != tt.expected {
+				t.Errorf("normalizeText(%q, %q, %v) = %q, want %q", tt.input, tt.lineEndings, tt.trailingNewline, result, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizeSkipsBinary(t *testing.T) {
+	tmpDir := t.TempDir()
+	binary := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/image.png": binary,
+		"src/notes.txt": "a\r\nb",
+		"embed.yaml": `output: assets
+go-mod: main
+line-endings: lf
+ensure-trailing-newline: true
+files:
+  - src/image.png
+  - src/notes.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "image.png")); string(data) != binary {
+		t.Errorf("image.png = %q, want it byte-identical", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "notes.txt")); string(data) != "a\nb\n" {
+		t.Errorf("notes.txt = %q, want %q", data, "a\nb\n")
+	}
+}
+
+func TestEncodingLatin1(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		// "Grüße, café" in ISO-8859-1 with CRLF line endings
+		"src/legacy.txt": "Gr\xfc\xdfe,\r\ncaf\xe9",
+		"src/utf8.txt":   "Gr\xfc\xdfe",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/legacy.txt
+    encoding: ISO-8859-1
+    line-endings: lf
+  - src/utf8.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "legacy.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "Grüße,\ncafé" {
+		t.Errorf("legacy.txt = %q, want %q", data, "Grüße,\ncafé")
+	}
+	// Files without an encoding are embedded byte for byte
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "utf8.txt")); string(data) != "Gr\xfc\xdfe" {
+		t.Errorf("utf8.txt = %q, want it unchanged", data)
+	}
+}
+
+func TestEncodingUnsupported(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": "files:\n  - source: a.txt\n    encoding: klingon\n",
+	})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err == nil || !strings.Contains(err.Error(), `files[0]: unsupported encoding "klingon"`) {
+		t.Errorf("loadConfig() error = %v, want unsupported encoding", err)
+	}
+}
+
+func TestRewriteURLs(t *testing.T) {
+	prefixes := map[string]string{
+		"https://cdn.example.com/":       "./static/",
+		"https://cdn.example.com/fonts/": "./fonts/",
+		"//cdn.example.com/img/":         "img/",
+	}
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{
+			"html attributes",
+			`<script src="https://cdn.example.com/app.js"></script><link HREF='https://cdn.example.com/fonts/a.css'>`,
+			`<script src="./static/app.js"></script><link HREF='./fonts/a.css'>`,
+		},
+		{
+			"css url references",
+			`body { background: url(//cdn.example.com/img/bg.png) } @font-face { src: url( "https://cdn.example.com/fonts/a.woff2" ) }`,
+			`body { background: url(img/bg.png) } @font-face { src: url( "./fonts/a.woff2" ) }`,
+		},
+		{
+			"non-matching references untouched",
+			`<a href="https://example.org/">x</a><img src="https://cdn.example.com.evil/x.png"> https://cdn.example.com/in-text.js`,
+			`<a href="https://example.org/">x</a><img src="https://cdn.example.com.evil/x.png"> https://cdn.example.com/in-text.js`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(rewriteURLs([]byte(tt.input), prefixes)); got != tt.expected {
+				t.Errorf("rewriteURLs() =\n%s\nwant\n%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRewriteURLsConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/index.html": `<img src="https://cdn.example.com/logo.png"><img src="/local.png">`,
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/index.html
+    rewrite-urls:
+      "https://cdn.example.com/": "static/"
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "index.html"))
+	if want := `<img src="static/logo.png"><img src="/local.png">`; string(data) != want {
+		t.Errorf("index.html = %q, want %q", data, want)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	if _, err := exec.LookPath("tr"); err != nil {
+		t.Skip("tr not available")
+	}
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/greeting.txt": "hello, world",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: src/greeting.txt
+    pipe: tr a-z A-Z
+    ensure-trailing-newline: true
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	// Normalization runs on the output of the command
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "greeting.txt"))
+	if want := "HELLO, WORLD\n"; string(data) != want {
+		t.Errorf("greeting.txt = %q, want %q", data, want)
+	}
+}
+
+func TestPipeFailure(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh syntax")
+	}
+	_, err := pipeContent(t.TempDir(), "cat >/dev/null; echo 'bad input' >&2; exit 3", []byte("x"))
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: bad input") {
+		t.Errorf("pipeContent() error = %v, want exit status and stderr", err)
+	}
+}
+
+func TestStripBOM(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/users.json": "\xef\xbb\xbf{\"users\":[]}",
+		"src/keep.txt":   "\xef\xbb\xbfkeep",
+		// "hi" in UTF-16LE with a byte order mark
+		"src/wide.txt": "\xff\xfeh\x00i\x00",
+		"embed.yaml": `output: assets
+go-mod: main
+strip-bom: true
+files:
+  - src/users.json
+  - source: src/keep.txt
+    strip-bom: false
+  - source: src/wide.txt
+    encoding: UTF-16
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "users.json"))
+	if string(data) != `{"users":[]}` {
+		t.Errorf("users.json = %q, want the BOM removed", data)
+	}
+	var users map[string]any
+	if err := json.Unmarshal(data, &users); err != nil {
+		t.Errorf("users.json does not parse as JSON: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "keep.txt")); string(data) != "\xef\xbb\xbfkeep" {
+		t.Errorf("keep.txt = %q, want the BOM kept by strip-bom: false", data)
+	}
+	if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "wide.txt")); string(data) != "hi" {
+		t.Errorf("wide.txt = %q, want %q", data, "hi")
+	}
+}
+
+func TestStripBOMChecksum(t *testing.T) {
+	// The checksum is computed over the content without the BOM
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.json": "\xef\xbb\xbf{}",
+		"embed.yaml": "output: assets\ngo-mod: main\nfiles:\n  - source: src/a.json\n    strip-bom: true\n    checksum: sha256:" + sha256Hex([]byte("{}")) + "\n",
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+}
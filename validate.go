@@ -0,0 +1,132 @@
+package main
+
+import (
+  "bytes"
+  "encoding/json"
+  "encoding/xml"
+  "errors"
+  "fmt"
+  "io"
+
+  "gopkg.in/yaml.v3"
+)
+
+// checkFormat parses data as format ("json", "yaml", "xml" or "sql") and returns the first syntax error,
+// with its line and column when the parser reports them. An empty format checks nothing
+func checkFormat(format string, data []byte) error {
+  switch format {
+  case "json":
+    return checkJSON(data)
+  case "yaml":
+    return checkYAML(data)
+  case "xml":
+    return checkXML(data)
+  case "sql":
+    return checkSQL(data)
+  }
+  return nil
+}
+
+// checkJSON requires data to hold exactly one JSON value
+func checkJSON(data []byte) error {
+  dec := json.NewDecoder(bytes.NewReader(data))
+  var v any
+  if err := dec.Decode(&v); err != nil {
+    var serr *json.SyntaxError
+    if errors.As(err, &serr) {
+      // Offset counts the byte the error was found at
+      line, col := position(data, int(serr.Offset)-1)
+      return fmt.Errorf("invalid JSON at line %d, column %d: %v", line, col, err)
+    }
+    return fmt.Errorf("invalid JSON: %v", err)
+  }
+  if rest := bytes.TrimLeft(data[dec.InputOffset():], " \t\r\n"); len(rest) > 0 {
+    line, col := position(data, len(data)-len(rest))
+    return fmt.Errorf("invalid JSON at line %d, column %d: data after the top-level value", line, col)
+  }
+  return nil
+}
+
+// checkYAML parses every document of a YAML stream. yaml.v3 includes the line in its errors
+func checkYAML(data []byte) error {
+  dec := yaml.NewDecoder(bytes.NewReader(data))
+  for {
+    var v any
+    err := dec.Decode(&v)
+    if err == io.EOF {
+      return nil
+    }
+    if err != nil {
+      return fmt.Errorf("invalid YAML: %v", err)
+    }
+  }
+}
+
+// checkXML reads every token of an XML document, which the decoder only returns for well-formed input
+func checkXML(data []byte) error {
+  dec := xml.NewDecoder(bytes.NewReader(data))
+  for {
+    _, err := dec.Token()
+    if err == io.EOF {
+      return nil
+    }
+    if err != nil {
+      line, col := dec.InputPos()
+      return fmt.Errorf("invalid XML at line %d, column %d: %v", line, col, err)
+    }
+  }
+}
+
+// checkSQL is a lightweight check that every statement of a SQL script is terminated with a semicolon
+// and that no string, quoted identifier or block comment is left open. The statements themselves are not parsed
+func checkSQL(data []byte) error {
+  pending := -1 // offset of the first character of an unterminated statement
+  for i := 0; i < len(data); i++ {
+    c := data[i]
+    switch {
+    case c == '-' && i+1 < len(data) && data[i+1] == '-':
+      for i < len(data) && data[i] != '\n' {
+        i++
+      }
+    case c == '/' && i+1 < len(data) && data[i+1] == '*':
+      end := bytes.Index(data[i+2:], []byte("*/"))
+      if end < 0 {
+        line, col := position(data, i)
+        return fmt.Errorf("invalid SQL at line %d, column %d: unterminated comment", line, col)
+      }
+      i += end + 3
+    case c == '\'' || c == '"' || c == '`':
+      if pending < 0 {
+        pending = i
+      }
+      // A doubled quote is an escaped one and simply reopens the literal
+      end := bytes.IndexByte(data[i+1:], c)
+      if end < 0 {
+        line, col := position(data, i)
+        return fmt.Errorf("invalid SQL at line %d, column %d: unterminated quoted string", line, col)
+      }
+      i += end + 1
+    case c == ';':
+      pending = -1
+    case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+    default:
+      if pending < 0 {
+        pending = i
+      }
+    }
+  }
+  if pending >= 0 {
+    line, col := position(data, pending)
+    return fmt.Errorf("invalid SQL at line %d, column %d: statement is not terminated with ;", line, col)
+  }
+  return nil
+}
+
+// position returns the 1-based line and column of the byte at offset in data
+func position(data []byte, offset int) (line, col int) {
+  offset = max(0, min(offset, len(data)))
+  before := data[:offset]
+  line = bytes.Count(before, []byte("\n")) + 1
+  col = offset - bytes.LastIndexByte(before, '\n')
+  return line, col
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckFormat(t *testing.T) {
+	tests := []struct {
+		format  string
+		data    string
+		wantErr string
+	}{
+		{"json", `{"a": [1, 2]}` + "\n", ""},
+		{"json", "{\n  \"a\": }", "invalid JSON at line 2, column 8"},
+		{"json", `{"a": 1`, "invalid JSON: unexpected EOF"},
+		{"json", "{}\n{}", "invalid JSON at line 2, column 1: data after the top-level value"},
+		{"yaml", "a: 1\n---\nb: [2, 3]\n", ""},
+		{"yaml", "a: 1\nb: [2, 3\n", "invalid YAML: yaml: line 1: did not find expected"},
+		{"xml", "<?xml version=\"1.0\"?>\n<a><b/></a>", ""},
+		{"xml", "<a>\n  <b></a>", "invalid XML at line 2, column 10: XML syntax error on line 2: element <b> closed by </a>"},
+		{"sql", "-- schema; v1\nCREATE TABLE t (s TEXT DEFAULT 'a;b');\n/* done; */\nINSERT INTO t VALUES ('it''s');\n", ""},
+		{"sql", "CREATE TABLE t (id INT);\nINSERT INTO t VALUES (1)\n", "invalid SQL at line 2, column 1: statement is not terminated with ;"},
+		{"sql", "SELECT 'open;\n", "invalid SQL at line 1, column 8: unterminated quoted string"},
+		{"sql", "SELECT 1; /* open", "invalid SQL at line 1, column 11: unterminated comment"},
+		{"", "anything {", ""},
+	}
+	for _, tt := range tests {
+		err := checkFormat(tt.format, []byte(tt.data))
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("checkFormat(%s, %q) error = %v, want %q", tt.format, tt.data, err, tt.wantErr)
+		}
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	body := `{"users": []}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - source: ` + server.URL + `/users.json
+    validate: json
+    embed-encoding: base64
+  - source: ` + server.URL + `/notes.txt
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+
+	// Files without validate are embedded whatever they contain
+	body = `{"users": [}`
+	err := run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "/users.json: invalid JSON at line 1, column 12") {
+		t.Errorf("run() error = %v, want the broken document reported", err)
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{"embed.yaml": "output: assets\nfiles:\n  - source: a.csv\n    validate: csv\n"})
+	_, err := loadConfig(filepath.Join(tmpDir, "embed.yaml"))
+	if err == nil || !strings.Contains(err.Error(), `files[0]: invalid validate "csv"`) {
+		t.Errorf("loadConfig() error = %v, want the unknown format rejected", err)
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+  "bytes"
+  "encoding/binary"
+  "fmt"
+  "path"
+  "strings"
+)
+
+// wasmMagic starts every WebAssembly binary module, followed by a little-endian uint32 version
+var wasmMagic = []byte("\x00asm")
+
+// isWasmName reports whether a file name is that of a WebAssembly module
+func isWasmName(name string) bool {
+  return strings.EqualFold(path.Ext(name), ".wasm")
+}
+
+// checkWasm verifies that data is a WebAssembly binary module of version 1, the only one there is.
+// A server answering a .wasm URL with an error page is caught here rather than when the module is instantiated
+func checkWasm(data []byte) error {
+  if len(data) < 8 || !bytes.Equal(data[:4], wasmMagic) {
+    return fmt.Errorf("not a WebAssembly module: the content does not start with the \\0asm magic bytes")
+  }
+  if version := binary.LittleEndian.Uint32(data[4:8]); version != 1 {
+    return fmt.Errorf("unsupported WebAssembly version %d", version)
+  }
+  return nil
+}
+
+// checkWasmEntry rejects options of a module's entry that only make sense for text, rather than running them
+// on the binary or ignoring them. Config-wide defaults such as line-endings or strip-bom simply do not apply to modules
+func checkWasmEntry(entry *FileEntry, encoded bool) error {
+  switch {
+  case entry.VarType == "string" && !encoded:
+    return fmt.Errorf("var-type string cannot be used for a WebAssembly module, which is declared as []byte: set embed-encoding to embed it as text")
+  case entry.Encoding != "":
+    return fmt.Errorf("encoding is not supported for a WebAssembly module, which is binary")
+  case entry.Pipe != "":
+    return fmt.Errorf("pipe is not supported for a WebAssembly module, which is binary")
+  case len(entry.RewriteURLs) > 0:
+    return fmt.Errorf("rewrite-urls is not supported for a WebAssembly module, which is binary")
+  case entry.Validate != "":
+    return fmt.Errorf("validate %s is not supported for a WebAssembly module, which is binary", entry.Validate)
+  }
+  return nil
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheckWasm(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"minimal module", "\x00asm\x01\x00\x00\x00", ""},
+		{"with sections", "\x00asm\x01\x00\x00\x00\x01\x04\x01\x60\x00\x00", ""},
+		{"html", "<!DOCTYPE html><title>Not Found</title>", "not a WebAssembly module"},
+		{"truncated", "\x00asm", "not a WebAssembly module"},
+		{"version", "\x00asm\x02\x00\x00\x00", "unsupported WebAssembly version 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkWasm([]byte(tt.data))
+			if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("checkWasm(%q) error = %v, want %q", tt.data, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunWasm(t *testing.T) {
+	body := "\x00asm\x01\x00\x00\x00"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		// Line endings of a module are left alone even when normalized globally
+		"embed.yaml": "output: assets\ngo-mod: main\nline-endings: lf\nfiles:\n  - " + server.URL + "/plugin.wasm\n",
+		"main.go": `package main
+
+import "fmt"
+
+func main() {
+	var module []byte = Plugin
+	fmt.Print(len(module), " ", PluginSize)
+}
+`,
+	})
+	if err := run(tmpDir, options{}, io.Discard); err != nil {
+		t.Fatalf("run() error: %v", err)
+	}
+	if out := runGenerated(t, tmpDir); out != "8 8" {
+		t.Errorf("output = %q, want a []byte and its size", out)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "assets", "plugin.wasm"))
+	if err != nil || string(data) != body {
+		t.Errorf("plugin.wasm = %q, %v; want the module unchanged", data, err)
+	}
+
+	// An error page served for the module fails generation
+	body = "<html>Not Found</html>"
+	err = run(tmpDir, options{}, io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "/plugin.wasm: not a WebAssembly module") {
+		t.Errorf("run() error = %v, want the non-wasm body rejected", err)
+	}
+}
+
+func TestWasmTextOptions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Bytes that look like a line ending are left alone by the config-wide line-endings
+		w.Write([]byte("\x00asm\x01\x00\x00\x00\r\n"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		options string
+		wantErr string
+	}{
+		{"config defaults", "", ""},
+		{"explicit string", "\n    var-type: string", "var-type string cannot be used for a WebAssembly module"},
+		{"string with embed-encoding", "\n    var-type: string\n    embed-encoding: base64", ""},
+		{"pipe", "\n    pipe: cat", "pipe is not supported for a WebAssembly module"},
+		{"rewrite-urls", "\n    rewrite-urls:\n      http://a/: http://b/", "rewrite-urls is not supported for a WebAssembly module"},
+		{"encoding", "\n    encoding: latin1", "encoding is not supported for a WebAssembly module"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			writeTestFiles(t, tmpDir, map[string]string{
+				"embed.yaml": "output: assets\ngo-mod: main\nline-endings: lf\nfiles:\n  - source: " + server.URL + "/plugin.wasm" + tt.options + "\n",
+			})
+			err := run(tmpDir, options{}, io.Discard)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("run() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("run() error: %v", err)
+			}
+			if tt.name == "config defaults" {
+				if data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", "plugin.wasm")); len(data) != 10 {
+					t.Errorf("plugin.wasm = %q, want the module unchanged", data)
+				}
+			}
+		})
+	}
+}
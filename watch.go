@@ -0,0 +1,168 @@
+package main
+
+import (
+  "context"
+  "fmt"
+  "io"
+  "os"
+  "path/filepath"
+  "strings"
+  "time"
+
+  "github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce is how long watch mode waits for changes to settle before regenerating
+var watchDebounce = 300 * time.Millisecond
+
+// watchSet is what watch mode reacts to: exact files and whole local directory trees
+type watchSet struct {
+  files    map[string]bool
+  trees    []string
+  dirs     map[string]bool // directories registered with the watcher
+  included map[string]bool // configs merged through includes, which invalidate remote content like the config itself
+}
+
+// matches reports whether a change to path should trigger a regeneration
+func (w *watchSet) matches(path string) bool {
+  if w.files[path] {
+    return true
+  }
+  for _, root := range w.trees {
+    if strings.HasPrefix(path, root+string(filepath.Separator)) {
+      return true
+    }
+  }
+  return false
+}
+
+// watch regenerates on every change to the config, .env or local source files until ctx is cancelled.
+// Remote content is kept in memory and only re-fetched when the config or .env changes.
+func watch(ctx context.Context, cwd string, opts options, stdout, stderr io.Writer) error {
+  watcher, err := fsnotify.NewWatcher()
+  if err != nil {
+    return fmt.Errorf("failed to start watcher: %v", err)
+  }
+  defer watcher.Close()
+
+  configPath := opts.configFile(cwd)
+  baseDir := filepath.Dir(configPath)
+  envPath := filepath.Join(baseDir, ".env")
+  opts.remoteCache = newMemoryCache()
+  // The change report is the per-run summary
+  opts.changes = true
+
+  var set *watchSet
+  regenerate := func() {
+    fmt.Fprintf(stdout, "[%s] regenerating\n", time.Now().Format("15:04:05"))
+    if err := run(cwd, opts, stdout); err != nil {
+      fmt.Fprintf(stderr, "error: %v\n", err)
+    }
+  }
+  update := func() {
+    next := watchedPaths(baseDir, configPath, envPath)
+    for dir := range next.dirs {
+      if set == nil || !set.dirs[dir] {
+        watcher.Add(dir)
+      }
+    }
+    if set != nil {
+      for dir := range set.dirs {
+        if !next.dirs[dir] {
+          watcher.Remove(dir)
+        }
+      }
+    }
+    set = next
+  }
+
+  update()
+  regenerate()
+
+  var timer <-chan time.Time
+  refetch := false
+  for {
+    select {
+    case <-ctx.Done():
+      return nil
+    case event, ok := <-watcher.Events:
+      if !ok {
+        return nil
+      }
+      if event.Op == fsnotify.Chmod || !set.matches(event.Name) {
+        continue
+      }
+      if event.Name == configPath || event.Name == envPath || set.included[event.Name] {
+        refetch = true
+      }
+      timer = time.After(watchDebounce)
+    case err, ok := <-watcher.Errors:
+      if !ok {
+        return nil
+      }
+      fmt.Fprintf(stderr, "watch error: %v\n", err)
+    case <-timer:
+      timer = nil
+      if refetch {
+        opts.remoteCache = newMemoryCache()
+        refetch = false
+      }
+      update()
+      regenerate()
+    }
+  }
+}
+
+// watchedPaths returns the config, .env and local sources of the current config,
+// along with the directories that have to be watched for them
+func watchedPaths(baseDir, configPath, envPath string) *watchSet {
+  set := &watchSet{
+    files: map[string]bool{configPath: true, envPath: true},
+    dirs:  map[string]bool{baseDir: true},
+  }
+  cfg, err := loadConfig(configPath)
+  if err != nil {
+    return set
+  }
+  set.included = map[string]bool{}
+  for _, include := range cfg.included {
+    set.files[include] = true
+    set.dirs[filepath.Dir(include)] = true
+    set.included[include] = true
+  }
+  for i := range cfg.Files {
+    entry := &cfg.Files[i]
+    if entry.github != nil || entry.Index != "" || entry.isDataURI(entry.Source) {
+      continue
+    }
+    sources := []string{expandEnvVars(entry.Source)}
+    if entry.List {
+      sources = envFileList(entry.Source)
+    }
+    if entry.Merge != nil {
+      sources = mergeSources(entry, cfg.GitHub)
+    }
+    for _, source := range sources {
+      if entry.Type != sourceTypeLocal {
+        source = resolveFileURL(source, cfg.GitHub)
+      }
+      if entry.isRemote(source) {
+        continue
+      }
+      local := resolvePath(baseDir, source)
+      if !entry.Recursive {
+        set.files[local] = true
+        set.dirs[filepath.Dir(local)] = true
+        continue
+      }
+      set.trees = append(set.trees, local)
+      filepath.WalkDir(local, func(p string, d os.DirEntry, err error) error {
+        if err == nil && d.IsDir() {
+          set.dirs[p] = true
+        }
+        return nil
+      })
+    }
+  }
+  return set
+}
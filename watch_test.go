@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent use
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchRegeneratesOnChange(t *testing.T) {
+	defer func(d time.Duration) { watchDebounce = d }(watchDebounce)
+	watchDebounce = 50 * time.Millisecond
+	tmpDir := t.TempDir()
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/a.txt": "v1",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+`,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out syncBuffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watch(ctx, tmpDir, options{}, &out, io.Discard)
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s; output:\n%s", what, out.String())
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	readAsset := func(name string) string {
+		data, _ := os.ReadFile(filepath.Join(tmpDir, "assets", name))
+		return string(data)
+	}
+
+	waitFor("initial generation", func() bool { return readAsset("a.txt") == "v1" })
+
+	writeTestFiles(t, tmpDir, map[string]string{"src/a.txt": "v2"})
+	waitFor("local source change", func() bool { return readAsset("a.txt") == "v2" })
+
+	writeTestFiles(t, tmpDir, map[string]string{
+		"src/b.txt": "b",
+		"embed.yaml": `output: assets
+go-mod: main
+files:
+  - src/a.txt
+  - src/b.txt
+`,
+	})
+	waitFor("config change", func() bool { return readAsset("b.txt") == "b" })
+	waitFor("summary", func() bool { return strings.Contains(out.String(), "new:     assets/b.txt") })
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("watch() error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watch did not exit after cancellation")
+	}
+}